@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// pairsFlag collects KEY=VALUE pairs from a repeatable flag. Each occurrence
+// may also hold several comma-separated pairs, so `-f a=1,b=2` and
+// `-f a=1 -f b=2` are equivalent. Order is preserved.
+type pairsFlag []pair
+
+// pair is a single KEY=VALUE entry
+type pair struct {
+	key, value string
+}
+
+// String renders the collected pairs back in flag syntax
+func (p *pairsFlag) String() string {
+	var parts []string
+	for _, kv := range *p {
+		parts = append(parts, kv.key+"="+kv.value)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Set parses one flag occurrence
+func (p *pairsFlag) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(item, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return fmt.Errorf("invalid pair %q: want KEY=VALUE", item)
+		}
+		*p = append(*p, pair{key: key, value: strings.TrimSpace(val)})
+	}
+	return nil
+}
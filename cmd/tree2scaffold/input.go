@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxSpecSize caps how many bytes of a remote spec are read
+const maxSpecSize = 1 << 20
+
+// httpClient fetches remote specs. It is a variable so tests can point it at a
+// local server.
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// fetchSpec downloads a spec from url, rejecting non-2xx responses and bodies
+// larger than maxSpecSize.
+func fetchSpec(client *http.Client, url string) (io.Reader, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("failed to fetch %s: server returned %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSpecSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", url, err)
+	}
+	if len(data) > maxSpecSize {
+		return nil, fmt.Errorf("spec at %s exceeds the %d byte limit", url, maxSpecSize)
+	}
+	return bytes.NewReader(data), nil
+}
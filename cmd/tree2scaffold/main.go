@@ -9,7 +9,11 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/lancekrogers/tree2scaffold/internal/env"
 	"github.com/lancekrogers/tree2scaffold/pkg/parser"
@@ -23,6 +27,67 @@ type options struct {
 	alwaysYes      bool
 	debug          bool
 	forceOverwrite bool
+	onConflict     string
+	renameFiles    bool
+	backup         bool
+	genCmds        pairsFlag
+	url            string
+	writeLock      bool
+	verifyLock     bool
+	noMagicDirs    bool
+	assumeDirs     bool
+	slashOptional  bool
+	noRelocate     bool
+	keepRoot       bool
+	flatten        bool
+	overwriteFiles bool
+	mainEverywhere bool
+	extMap         pairsFlag
+	verifyOnly     bool
+	seedEntry      string
+	commentFromFn  bool
+	collapseDirs   bool
+	rootPackage    string
+	templates      string
+	builtinTmpl    bool
+	templateData   string
+	vars           pairsFlag
+	fromFile       string
+	relativeTo     string
+	watch          bool
+	noComment      bool
+	genTestFiles   bool
+	goSum          string
+	respectIgnore  bool
+	dirComment     string
+	sort           string
+	dirCase        string
+	reportFile     string
+	emptyDirs      string
+	preserveExist  bool
+	failOnSkip     bool
+	dedupComments  bool
+	singleFile     string
+	comment        string
+	buildTags      bool
+	modulePath     string
+	overwriteList  string
+	lowerExts      bool
+	dirPerRoot     bool
+	rootReadme     bool
+	strictGenerate bool
+	requireParents bool
+	validateNames  bool
+	warnValidation bool
+	maxComment     int
+	eol            string
+	stat           bool
+	countOnly      bool
+	commentSyntax  pairsFlag
+	pasteReport    bool
+	replacements   pairsFlag
+	format         string
+	profile        string
 }
 
 // askConfirm prompts the user for confirmation and returns their response
@@ -36,22 +101,31 @@ func askConfirm() bool {
 	return resp == "y" || resp == "yes"
 }
 
+// clipboardTimeout bounds how long getInput waits for the clipboard, so a
+// stuck pbpaste never hangs the tool
+var clipboardTimeout = 5 * time.Second
+
 // getInput returns an io.Reader with the input to process. It prefers piped or
 // redirected stdin and otherwise falls back to the clipboard. Under WASI the
 // clipboard is unavailable and stdin pipe-detection is unreliable, so it reads
 // stdin directly and turns an empty stream into an actionable error.
 func getInput(e env.Environment) (io.Reader, error) {
+	return readInput(os.Stdin, e)
+}
+
+// readInput is getInput reading from stdin
+func readInput(stdin *os.File, e env.Environment) (io.Reader, error) {
 	// Honor piped/redirected stdin when it is detectable.
-	if fi, _ := os.Stdin.Stat(); fi.Mode()&os.ModeCharDevice == 0 {
-		return os.Stdin, nil
+	if isRedirected(stdin) {
+		return stdin, nil
 	}
 
 	// No obvious pipe: try the clipboard where the runtime supports it.
-	out, err := e.Clipboard()
+	out, err := readClipboard(e, clipboardTimeout)
 	if errors.Is(err, env.ErrUnsupported) {
 		// WASI: no clipboard, and char-device detection is unreliable, so read
 		// stdin directly. An empty stream becomes a clear, actionable error.
-		data, rerr := io.ReadAll(os.Stdin)
+		data, rerr := io.ReadAll(stdin)
 		if rerr != nil {
 			return nil, fmt.Errorf("failed to read stdin: %w", rerr)
 		}
@@ -67,6 +141,40 @@ func getInput(e env.Environment) (io.Reader, error) {
 	return bytes.NewReader(out), nil
 }
 
+// isRedirected reports whether f is a pipe, FIFO, socket or regular file
+// rather than a terminal. A file that cannot be stat'ed counts as a terminal.
+func isRedirected(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	mode := fi.Mode()
+	if mode.IsRegular() || mode&(os.ModeNamedPipe|os.ModeSocket) != 0 {
+		return true
+	}
+	return mode&os.ModeCharDevice == 0
+}
+
+// readClipboard reads the clipboard, giving up after timeout
+func readClipboard(e env.Environment, timeout time.Duration) ([]byte, error) {
+	type result struct {
+		data []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		data, err := e.Clipboard()
+		done <- result{data, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.data, r.err
+	case <-time.After(timeout):
+		return nil, fmt.Errorf("no answer after %s; pipe the tree via stdin or use -from-file", timeout)
+	}
+}
+
 // preprocessInput applies any necessary preprocessing to the input
 func preprocessInput(input io.Reader, debug bool) (io.Reader, error) {
 	if !debug {
@@ -86,73 +194,510 @@ func preprocessInput(input io.Reader, debug bool) (io.Reader, error) {
 	return bytes.NewReader(inputBytes), nil
 }
 
-// previewNodes prints a preview of what will be created
-func previewNodes(nodes []parser.Node) {
-	fmt.Println("☑️  Will create:")
-	for _, n := range nodes {
-		if n.IsDir {
-			fmt.Printf("    dir:  %s\n", n.Path)
-		} else {
-			fmt.Printf("    file: %s\n", n.Path)
+// parseInput parses the spec and rejects input that yields no nodes, quoting
+// the start of what was received so clipboard mix-ups are easy to spot
+func parseInput(input io.Reader, opts parser.ParseOptions) (parser.Document, error) {
+	format, input, err := parser.DetectFormat(input)
+	if err != nil {
+		return parser.Document{}, fmt.Errorf("failed to read input: %w", err)
+	}
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return parser.Document{}, fmt.Errorf("failed to read input: %w", err)
+	}
+
+	switch format {
+	case parser.FormatUnknown:
+		return parser.Document{}, errors.New("input is empty: pipe a tree via stdin, copy one to the clipboard, or pass -url")
+	case parser.FormatJSON, parser.FormatYAML:
+		if opts.Format != parser.FormatUnknown {
+			break // -format says what it is
+		}
+		return parser.Document{}, fmt.Errorf("the input looks like %s, which is not supported; expected `tree` output or one path per line. Input began with:\n%s",
+			strings.ToUpper(format.String()), inputExcerpt(data, 5))
+	}
+
+	// A partial parse is not scaffolded: half a tree is rarely what was meant
+	doc, err := parser.ParseDocument(bytes.NewReader(data), opts)
+	if err != nil && len(doc.Nodes) > 0 {
+		return parser.Document{}, fmt.Errorf("parse error: %w (%d paths before it were read; nothing was created)", err, len(doc.Nodes))
+	}
+	if err != nil {
+		return parser.Document{}, fmt.Errorf("parse error: %w", err)
+	}
+	if len(doc.Nodes) == 0 {
+		return parser.Document{}, fmt.Errorf("no files or directories recognized in the input; expected `tree` output or one path per line. Input began with:\n%s",
+			inputExcerpt(data, 5))
+	}
+	return doc, nil
+}
+
+// inputExcerpt returns up to n non-blank lines of data, indented and cut at
+// 80 characters each
+func inputExcerpt(data []byte, n int) string {
+	var b strings.Builder
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if n == 0 {
+			b.WriteString("    ...\n")
+			break
+		}
+		if r := []rune(line); len(r) > 80 {
+			line = string(r[:80]) + "..."
+		}
+		fmt.Fprintf(&b, "    %s\n", line)
+		n--
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// previewNodes writes a preview of what will be created to w, with each
+// node's comment and the package of each Go file as gen infers it
+func previewNodes(w io.Writer, nodes []parser.Node, gen *scaffold.DefaultContentGenerator, opts parser.RenderOptions) {
+	opts.Annotate = func(n parser.Node) string {
+		if pkg := gen.PackageName(n.Path); pkg != "" && !n.IsDir && n.LinkTarget == "" {
+			return "package " + pkg
 		}
+		return ""
+	}
+	fmt.Fprintln(w, "☑️  Will create:")
+	tree := parser.RenderTree(nodes, opts)
+	for _, line := range strings.Split(strings.TrimSuffix(tree, "\n"), "\n") {
+		fmt.Fprintf(w, "    %s\n", line)
 	}
 }
 
-// debugNodes prints detailed node information in debug mode
+// verifyOnly prints a diff between the spec and the tree under root: "-" for
+// paths the spec declares but root lacks, "+" for paths root has but the spec
+// does not. It returns an error when the two differ.
+func verifyOnly(w io.Writer, root string, nodes []parser.Node) error {
+	missing := scaffold.MissingPaths(root, nodes)
+	extra, err := scaffold.ExtraPaths(root, nodes)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range missing {
+		fmt.Fprintf(w, "- %s\n", p)
+	}
+	for _, p := range extra {
+		fmt.Fprintf(w, "+ %s\n", p)
+	}
+
+	if len(missing) > 0 || len(extra) > 0 {
+		return fmt.Errorf("structure mismatch: %d missing, %d extra", len(missing), len(extra))
+	}
+	fmt.Fprintln(w, "✅ Structure matches the spec")
+	return nil
+}
+
+// debugNodes prints the final node set, after every parse and transform
+// pass, in debug mode
 func debugNodes(nodes []parser.Node) {
-	fmt.Println("=== Parsed Nodes ===")
+	fmt.Println("=== Final Nodes ===")
 	for i, n := range nodes {
 		fmt.Printf("%d: Path=%s, IsDir=%v, Comment=%s\n", i, n.Path, n.IsDir, n.Comment)
 	}
-	fmt.Println("=== End Parsed Nodes ===")
+	fmt.Println("=== End Final Nodes ===")
+}
+
+// debugRewrite returns a parser.ParseOptions.OnRewrite hook that prints which
+// pass moved or retyped a node, so a file landing somewhere unexpected can be
+// traced back to the heuristic responsible
+func debugRewrite(w io.Writer) func(pass string, before, after parser.Node) {
+	return func(pass string, before, after parser.Node) {
+		fmt.Fprintf(w, "Rewrite [%s] line %d: %s (IsDir=%v) -> %s (IsDir=%v)\n",
+			pass, before.Line, before.Path, before.IsDir, after.Path, after.IsDir)
+	}
+}
+
+// shortFlags maps each one-letter shortcut to the flag it stands for
+var shortFlags = map[string]string{
+	"d": "dry-run",
 }
 
-// parseFlags parses command-line flags into an options structure
+// parseFlags parses the command line into an options structure, exiting
+// with status 2 on bad flags or a bad -profile, as flag.ExitOnError does
 func parseFlags() options {
+	opts, err := parseArgs(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	return opts
+}
+
+// parseArgs defines every flag on fs and parses args into an options structure
+func parseArgs(fs *flag.FlagSet, args []string) (options, error) {
 	opts := options{}
 
 	// Define standard flags
-	flag.StringVar(&opts.root, "root", ".", "project root directory")
-	flag.BoolVar(&opts.dryRun, "dry-run", false, "show what would be created and ask")
-	flag.BoolVar(&opts.alwaysYes, "yes", false, "skip confirmation prompt")
-	flag.BoolVar(&opts.debug, "debug", false, "output debug information")
-	flag.BoolVar(&opts.forceOverwrite, "force", false, "force overwrite of existing files that conflict with directories")
+	fs.StringVar(&opts.root, "root", ".", "project root directory")
+	fs.StringVar(&opts.relativeTo, "relative-to", "cwd", "directory a relative -root is resolved against: cwd, or spec for the directory of the -from-file spec")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "show what would be created and ask")
+	fs.BoolVar(&opts.alwaysYes, "yes", false, "skip confirmation prompt")
+	fs.BoolVar(&opts.debug, "debug", false, "output debug information")
+	fs.BoolVar(&opts.pasteReport, "paste-report", false, "explain how the input was read: detected format, indent unit and the node each line became")
+	fs.BoolVar(&opts.forceOverwrite, "force", false, "replace existing files that are in the way of a directory (never overwrites file contents; see -force-overwrite-files)")
+	fs.BoolVar(&opts.overwriteFiles, "force-overwrite-files", false, "overwrite the contents of existing files (same as -on-conflict overwrite)")
+	fs.StringVar(&opts.onConflict, "on-conflict", "skip", "what to do with existing files: skip, overwrite or rename")
+	fs.BoolVar(&opts.renameFiles, "on-conflict-rename", false, "write a numbered variant (a.1.go) next to existing files (same as -on-conflict rename)")
+	fs.BoolVar(&opts.warnValidation, "warn-on-validation", false, "print every validation error, such as a file where a directory goes, and continue instead of stopping; such files are removed")
+	fs.StringVar(&opts.overwriteList, "overwrite-list", "", "file listing the paths (one per line) to overwrite; all other existing files are skipped")
+	fs.BoolVar(&opts.preserveExist, "preserve-existing-content", false, "merge into existing files of a known structure (go.mod) instead of skipping or overwriting them")
+	fs.BoolVar(&opts.backup, "backup", false, "rename existing files to <name>.bak before overwriting them")
+	fs.StringVar(&opts.singleFile, "single-file", "", "create just this one file (and its parents) instead of reading a spec")
+	fs.StringVar(&opts.comment, "comment", "", "with -single-file, the comment to generate the file from")
+	fs.StringVar(&opts.fromFile, "from-file", "", "read the tree spec from a file instead of stdin or the clipboard")
+	fs.BoolVar(&opts.watch, "watch", false, "with -from-file, scaffold again whenever the spec file changes")
+	fs.BoolVar(&opts.failOnSkip, "fail-on-skip", false, "exit non-zero if nothing was written because every file already existed")
+	fs.StringVar(&opts.reportFile, "report-file", "", "write every action taken to this file (.json for JSON, otherwise text)")
+	fs.StringVar(&opts.url, "url", "", "fetch the tree spec from a URL instead of stdin or the clipboard")
+	fs.BoolVar(&opts.writeLock, "lock", false, "record file checksums in "+scaffold.LockFileName+" after scaffolding")
+	fs.BoolVar(&opts.verifyLock, "verify", false, "check files under -root against "+scaffold.LockFileName+" and exit")
+	fs.BoolVar(&opts.verifyOnly, "verify-only", false, "report paths missing from or extra to the spec under -root, without creating anything")
+	fs.BoolVar(&opts.countOnly, "count-only", false, "print how many files and directories the spec would create and exit")
+	fs.BoolVar(&opts.stat, "stat", false, "print how many files of each type and how many directories the spec has before creating them")
+	fs.StringVar(&opts.sort, "sort", "type", "order of the preview and of creation: type (directories first), name or input")
+	fs.BoolFunc("create-parents", "create parent directories the spec does not declare; false makes them an error (default true)", func(v string) error {
+		create, err := strconv.ParseBool(v)
+		opts.requireParents = !create
+		return err
+	})
+	fs.BoolVar(&opts.validateNames, "validate-names", false, "refuse names that are invalid on some OS, such as CON, a:b or a trailing dot, so the result also works on Windows")
+	fs.BoolVar(&opts.collapseDirs, "collapse-single-child-dirs", false, "draw chains of single-child directories as one entry in the preview")
+	fs.BoolVar(&opts.dirPerRoot, "output-dir-per-root", false, "split the input on '---' lines and create each document under a directory named after its root line")
+	fs.StringVar(&opts.format, "format", "auto", "read the input as: auto, tree, simple, list or ls-r")
+	fs.BoolVar(&opts.lowerExts, "force-lowercase-extensions", false, "lowercase file extensions, e.g. Main.GO becomes Main.go")
+	fs.BoolVar(&opts.assumeDirs, "assume-dir-if-no-extension", false, "treat every leaf without an extension (bin, LICENSE) as a directory")
+	fs.BoolVar(&opts.slashOptional, "trailing-slash-optional", false, "in a simple list, treat entries without an extension (internal, bin) as directories even without a trailing slash; well-known files like LICENSE stay files")
+	fs.BoolVar(&opts.keepRoot, "keep-root", false, "create the spec's root line as a directory instead of stripping it")
+	fs.BoolVar(&opts.noRelocate, "no-relocate", false, "never move files into directories the spec did not put them in")
+	fs.BoolVar(&opts.noMagicDirs, "no-magic-dirs", false, "deprecated: names are no longer guessed to be directories")
+	fs.StringVar(&opts.dirCase, "dir-case", "preserve", "rename directories: kebab, snake, lower or preserve")
+	fs.StringVar(&opts.emptyDirs, "empty-dirs", "create", "directories with nothing in them: create, error or keepfile (adds .gitkeep)")
+	fs.BoolVar(&opts.flatten, "flatten", false, "create every file directly under -root, ignoring directories")
+	fs.BoolVar(&opts.rootReadme, "create-root-readme", false, "add a README.md named after the project to the root if the spec has none")
+	fs.StringVar(&opts.seedEntry, "seed-entry", "", "add the language's entry file (python, rust, ts, js) to directories without files")
+	fs.BoolVar(&opts.noComment, "no-comment", false, "leave spec comments out of generated files")
+	fs.StringVar(&opts.eol, "eol", "lf", "line endings of generated files: lf or crlf")
+	fs.IntVar(&opts.maxComment, "max-comment-length", 0, "shorten comments written into files to N characters, ending in '…' (0 means no limit)")
+	fs.StringVar(&opts.dirComment, "dir-comment", "inherit", "what a directory's comment becomes: inherit (heads its files) or first-file (also the package doc of its first Go file)")
+	fs.BoolVar(&opts.dedupComments, "dedup-comments", false, "drop a file's comment when it repeats its directory's comment, leaving it without a header; files with no comment still inherit the directory's")
+	fs.BoolVar(&opts.commentFromFn, "comment-from-filename", false, "derive a comment from the file name for files without one")
+	fs.StringVar(&opts.rootPackage, "root-package", "", "package name for root-level .go files other than main.go (default main)")
+	fs.BoolVar(&opts.respectIgnore, "respect-gitignore", false, "skip paths the .gitignore at -root ignores")
+	fs.StringVar(&opts.goSum, "gosum", "empty", "how to create go.sum files: empty, skip (leave them to go mod tidy) or comment")
+	fs.BoolVar(&opts.genTestFiles, "gen-test-files", false, "add a _test.go stub next to every .go file except main.go")
+	fs.BoolVar(&opts.mainEverywhere, "main-everywhere", false, "make every main.go package main, even inside internal/ or pkg/")
+	fs.StringVar(&opts.modulePath, "root-module-path", "", "module path of -root (e.g. github.com/me/app); makes generated _test.go stubs external tests that import their package and sets the module of generated go.mod files")
+	fs.BoolVar(&opts.buildTags, "build-tags", false, "start _GOOS/_GOARCH .go files (main_windows.go, asm_amd64.go) with a matching //go:build line")
+	fs.Var(&opts.commentSyntax, "comment-syntax", "comment style for an extension, e.g. '.lua=--' or '.ml=(*|*)'; '*' sets it for unknown types (repeatable)")
+	fs.Var(&opts.extMap, "ext-map", "treat one extension like another, e.g. '.mjs=.js,.gotmpl=.go' (repeatable)")
+	fs.StringVar(&opts.templates, "templates", "", "directory of *.tmpl files used to render matching files")
+	fs.StringVar(&opts.templateData, "template-data", "", "JSON or YAML file whose values templates see as .Data")
+	fs.BoolVar(&opts.builtinTmpl, "builtin-templates", false, "render doc.go, Python, Dockerfile and Makefile files from the built-in templates instead of a comment header")
+	fs.Var(&opts.replacements, "replace-in-content", "replace a placeholder in generated content, e.g. '__MODULE__=github.com/me/app' (repeatable)")
+	fs.Var(&opts.vars, "var", "template variable available as {{.Vars.KEY}}, e.g. 'author=Jane' (repeatable)")
+	fs.BoolVar(&opts.strictGenerate, "strict-generate", false, "abort when a -gen-cmd command or template fails instead of writing default content")
+	fs.StringVar(&opts.profile, "profile", "", "preset the generators, conventions and module handling of an ecosystem: go, node, python or rust")
+	fs.Var(&opts.genCmds, "gen-cmd", "generate content with an external command, e.g. '.rb=ruby-scaffold-gen' (repeatable)")
 
-	// Add a special shortcut flag for dry-run (abbreviated 'd')
-	dShortcut := flag.Bool("d", false, "shortcut for --dry-run")
+	// Short forms share their long flag's value, so whichever of the two
+	// comes last on the command line wins
+	for short, long := range shortFlags {
+		f := fs.Lookup(long)
+		fs.Var(f.Value, short, "shortcut for -"+long)
+	}
 
-	// Parse flags
-	flag.Parse()
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	return opts, applyProfile(fs, &opts)
+}
 
-	// Apply the shortcut if used
-	if *dShortcut {
-		opts.dryRun = true
+// expandHome replaces a leading "~" or "~/" in path with the user's home
+// directory. Paths like "~user/x" are left untouched.
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot expand %s: %w", path, err)
 	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
 
-	return opts
+// defaultGenerator builds the default content generator configured by opts,
+// which the templates and -gen-cmd commands fall back to
+func defaultGenerator(opts options) (*scaffold.DefaultContentGenerator, error) {
+	gen := scaffold.NewDefaultContentGenerator()
+	gen.MainEverywhere = opts.mainEverywhere
+	gen.CommentFromFilename = opts.commentFromFn
+	gen.RootPackage = opts.rootPackage
+	gen.BuildTags = opts.buildTags
+	gen.ModulePath = opts.modulePath
+	goSum, err := scaffold.ParseGoSumMode(opts.goSum)
+	if err != nil {
+		return nil, err
+	}
+	gen.GoSum = goSum
+
+	// A workspace's go.work knows the intended Go version better than the
+	// toolchain that happens to be installed
+	work, err := scaffold.ReadGoWork(opts.root)
+	if err != nil {
+		return nil, err
+	}
+	gen.GoVersion = work.Go
+	for _, kv := range opts.extMap {
+		gen.AliasExtension(dotExt(kv.key), dotExt(kv.value))
+	}
+	for _, kv := range opts.commentSyntax {
+		prefix, suffix, err := parseCommentSyntax(kv.value)
+		if err != nil {
+			return nil, fmt.Errorf("-comment-syntax %s: %w", kv.key, err)
+		}
+		if kv.key == "*" {
+			gen.SetFallbackCommentSyntax(prefix, suffix)
+			continue
+		}
+		gen.SetCommentSyntax(dotExt(kv.key), prefix, suffix)
+	}
+	if p, ok := profiles[opts.profile]; ok && p.gitignore != "" {
+		gen.RegisterGenerator(".gitignore", scaffold.DotfileGenerator(p.gitignore))
+	}
+	return gen, nil
+}
+
+// newContentGenerator builds the content generator configured by opts
+func newContentGenerator(opts options) (scaffold.ContentGenerator, error) {
+	gen, err := defaultGenerator(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Render files from the built-in templates when asked, overridden by user
+	// templates of the same name, where one matches
+	var out scaffold.ContentGenerator = gen
+	if opts.builtinTmpl || opts.templates != "" {
+		tg := scaffold.NewTemplateGenerator(gen)
+		if opts.builtinTmpl {
+			if err := tg.LoadBuiltinTemplates(); err != nil {
+				return nil, err
+			}
+		}
+		if opts.templates != "" {
+			if err := tg.LoadTemplates(opts.templates); err != nil {
+				return nil, err
+			}
+		}
+		if opts.templateData != "" {
+			data, err := scaffold.LoadTemplateData(opts.templateData)
+			if err != nil {
+				return nil, err
+			}
+			tg.Data = data
+		}
+		for _, kv := range opts.vars {
+			tg.Vars[kv.key] = kv.value
+		}
+		out = tg
+	}
+
+	// Delegate selected extensions to external generator commands
+	if len(opts.genCmds) == 0 {
+		return out, nil
+	}
+	ext := scaffold.NewExternalGenerator(out)
+	for _, kv := range opts.genCmds {
+		if err := ext.RegisterCommand(kv.key, kv.value); err != nil {
+			return nil, err
+		}
+	}
+	return ext, nil
+}
+
+// dotExt returns ext with a leading dot, so ".mjs" and "mjs" are equivalent
+func dotExt(ext string) string {
+	if strings.HasPrefix(ext, ".") {
+		return ext
+	}
+	return "." + ext
+}
+
+// parseCommentSyntax splits a -comment-syntax value, "PREFIX" or
+// "PREFIX|SUFFIX", into a prefix and suffix padded with a space around the
+// comment text
+func parseCommentSyntax(value string) (prefix, suffix string, err error) {
+	prefix, suffix, _ = strings.Cut(value, "|")
+	prefix, suffix = strings.TrimSpace(prefix), strings.TrimSpace(suffix)
+	if prefix == "" {
+		return "", "", errors.New("want PREFIX or PREFIX|SUFFIX, e.g. '--' or '(*|*)'")
+	}
+	if suffix != "" {
+		suffix = " " + suffix
+	}
+	return prefix + " ", suffix, nil
+}
+
+// conflictPolicy resolves the policy for existing files from -on-conflict and
+// its -force-overwrite-files and -on-conflict-rename shorthands
+func conflictPolicy(opts options) (scaffold.ConflictPolicy, error) {
+	switch {
+	case opts.overwriteFiles && opts.renameFiles:
+		return scaffold.ConflictSkip, errors.New("-force-overwrite-files and -on-conflict-rename cannot be combined")
+	case opts.overwriteFiles:
+		return scaffold.ConflictOverwrite, nil
+	case opts.renameFiles:
+		return scaffold.ConflictRename, nil
+	}
+	return scaffold.ParseConflictPolicy(opts.onConflict)
+}
+
+// resolveRoot expands a leading ~ in -root that the shell did not expand for
+// us and, with -relative-to spec, places a relative root beside the spec file
+func resolveRoot(opts options) (string, error) {
+	root, err := expandHome(opts.root)
+	if err != nil {
+		return "", err
+	}
+	switch opts.relativeTo {
+	case "", "cwd":
+		return root, nil
+	case "spec":
+	default:
+		return "", fmt.Errorf("unknown -relative-to %q (want cwd or spec)", opts.relativeTo)
+	}
+	if opts.fromFile == "" {
+		return "", errors.New("-relative-to spec needs -from-file")
+	}
+	if filepath.IsAbs(root) {
+		return root, nil
+	}
+	spec, err := expandHome(opts.fromFile)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(spec), root), nil
 }
 
 // run executes the main program logic
 func run(opts options) error {
-	// Build the host environment once (exec-backed natively, no-op probes on WASI).
-	e := env.New()
+	root, err := resolveRoot(opts)
+	if err != nil {
+		return err
+	}
+	opts.root = root
 
-	// Get the input
-	input, err := getInput(e)
+	// Verify mode only compares the root against its lock file
+	if opts.verifyLock {
+		if err := scaffold.VerifyLockFile(opts.root); err != nil {
+			return err
+		}
+		fmt.Println("✅ Scaffold matches " + scaffold.LockFileName)
+		return nil
+	}
+
+	// Build the nodes from one path on the command line or from a spec
+	var doc parser.Document
+	if opts.singleFile != "" {
+		n, err := singleFileNode(opts.singleFile, opts.comment)
+		if err != nil {
+			return err
+		}
+		doc.Nodes = []parser.Node{n}
+	} else if doc, err = readDocument(opts); err != nil {
+		return err
+	}
+	nodes := doc.Nodes
+
+	// Collapse the layout into the root if requested
+	if opts.flatten {
+		if nodes, err = scaffold.Flatten(nodes); err != nil {
+			return err
+		}
+	}
+
+	// Rename directories to the requested convention
+	dirCase, err := scaffold.ParseDirCase(opts.dirCase)
 	if err != nil {
 		return err
 	}
+	if nodes, err = scaffold.NormalizeDirCase(nodes, dirCase); err != nil {
+		return err
+	}
+	if opts.lowerExts {
+		nodes = scaffold.LowercaseExtensions(nodes)
+	}
 
-	// Preprocess the input if needed
-	input, err = preprocessInput(input, opts.debug)
+	// Give empty package directories their conventional entry file
+	if opts.seedEntry != "" {
+		if nodes, err = scaffold.SeedEntries(nodes, opts.seedEntry); err != nil {
+			return err
+		}
+	}
+
+	// Make sure the project has a README, named after its root directory and
+	// described by the spec's root comment
+	if opts.rootReadme {
+		abs, err := filepath.Abs(opts.root)
+		if err != nil {
+			return err
+		}
+		title := filepath.Base(abs)
+		if doc.Comment != "" {
+			title += ": " + doc.Comment
+		}
+		nodes = scaffold.AddRootReadme(nodes, title)
+	}
+
+	// Leave go.sum to the go command if asked
+	goSum, err := scaffold.ParseGoSumMode(opts.goSum)
+	if err != nil {
+		return err
+	}
+	if goSum == scaffold.GoSumSkip {
+		nodes = scaffold.SkipGoSum(nodes)
+	}
+
+	// Keep out of paths the target repository ignores
+	if opts.respectIgnore {
+		ignore, err := scaffold.LoadGitignore(opts.root)
+		if err != nil {
+			return err
+		}
+		var dropped []string
+		if nodes, dropped = scaffold.FilterGitignored(nodes, ignore); len(dropped) > 0 {
+			fmt.Fprintf(os.Stderr, "Note: skipping %s ignored by .gitignore: %s\n", plural(len(dropped), "path"), strings.Join(dropped, ", "))
+		}
+	}
+
+	// Decide what to do with directories that have nothing in them
+	emptyDirs, err := scaffold.ParseEmptyDirPolicy(opts.emptyDirs)
 	if err != nil {
 		return err
 	}
+	if nodes, err = scaffold.HandleEmptyDirs(nodes, emptyDirs); err != nil {
+		return err
+	}
 
-	// Parse the input into nodes
-	nodes, err := parser.Parse(input)
+	// Document Go packages with their directory's comment
+	dirComment, err := scaffold.ParseDirCommentMode(opts.dirComment)
 	if err != nil {
-		return fmt.Errorf("parse error: %w", err)
+		return err
+	}
+	if dirComment == scaffold.DirCommentFirstFile {
+		nodes = scaffold.PackageDocFromDirs(nodes)
+	}
+
+	// Pair Go sources with test files
+	if opts.genTestFiles {
+		nodes = scaffold.AddTestFiles(nodes)
 	}
 
 	// Debug mode - print the parsed nodes
@@ -160,24 +705,92 @@ func run(opts options) error {
 		debugNodes(nodes)
 	}
 
-	// Preview what will be created
-	previewNodes(nodes)
+	// Verify-only mode compares the root with the spec and stops
+	if opts.verifyOnly {
+		return verifyOnly(os.Stdout, opts.root, nodes)
+	}
+
+	// Count-only mode reports the size of the spec and stops
+	if opts.countOnly {
+		fmt.Println(countSummary(nodes))
+		return nil
+	}
+
+	// Preview what will be created, in the order it will be created
+	order, err := scaffold.ParseSortOrder(opts.sort)
+	if err != nil {
+		return err
+	}
+	gen, err := defaultGenerator(opts)
+	if err != nil {
+		return err
+	}
+	gen.PlanSpec(nodes)
+	previewNodes(os.Stdout, scaffold.SortNodes(nodes, order), gen, parser.RenderOptions{
+		CollapseSingleChildDirs: opts.collapseDirs,
+	})
+	if opts.stat {
+		fmt.Printf("📊 %s\n", statSummary(nodes))
+	}
+
+	// Resolve the file conflict policy
+	policy, err := conflictPolicy(opts)
+	if err != nil {
+		return err
+	}
+	eol, err := scaffold.ParseLineEnding(opts.eol)
+	if err != nil {
+		return err
+	}
 
 	// Create a scaffolder
-	var s scaffold.Scaffolder
+	var s *scaffold.DefaultScaffolder
 	if opts.forceOverwrite {
 		s = scaffold.NewScaffolderWithForce()
 	} else {
 		s = scaffold.NewScaffolder()
 	}
+	s.OnConflict = policy
+	if opts.overwriteList != "" {
+		if s.OverwriteOnly, err = readPathList(opts.overwriteList); err != nil {
+			return err
+		}
+	}
+	s.Backup = opts.backup
+	s.WriteLock = opts.writeLock
+	s.NoComments = opts.noComment
+	s.DedupComments = opts.dedupComments
+	s.StrictGenerate = opts.strictGenerate
+	s.RequireParents = opts.requireParents
+	s.ValidateNames = opts.validateNames
+	s.MaxCommentLength = opts.maxComment
+	s.EOL = eol
+	s.Order = order
+	for _, kv := range opts.replacements {
+		s.Replacements = append(s.Replacements, kv.key, kv.value)
+	}
+	s.MergeExisting = opts.preserveExist
+	if s.ContentProvider, err = newContentGenerator(opts); err != nil {
+		return err
+	}
 
 	// Pre-validate, especially for hidden files
 	if !opts.forceOverwrite {
-		if err := s.Validate(opts.root, nodes); err != nil {
+		err := s.Validate(opts.root, nodes)
+		if err != nil && opts.warnValidation {
+			for _, problem := range s.ValidationProblems(opts.root, nodes) {
+				fmt.Fprintf(os.Stderr, "Warning: validation error, continuing (-warn-on-validation): %v\n", problem)
+			}
+			if conflicts := scaffold.FileConflicts(opts.root, nodes); len(conflicts) > 0 {
+				fmt.Fprintf(os.Stderr, "Warning: %s in the way of directories will be removed: %s\n",
+					plural(len(conflicts), "file"), strings.Join(conflicts, ", "))
+			}
+		} else if err != nil {
 			fmt.Fprintf(os.Stderr, "Validation error: %v\n", err)
 			fmt.Fprintf(os.Stderr, "Options:\n")
 			fmt.Fprintf(os.Stderr, "  1. Remove conflicting files manually before running again\n")
 			fmt.Fprintf(os.Stderr, "  2. Use the -force flag to overwrite conflicting files\n")
+			fmt.Fprintf(os.Stderr, "  3. Use the -warn-on-validation flag to continue and let the scaffolder convert them\n")
 			return err
 		}
 	} else if opts.debug {
@@ -193,28 +806,242 @@ func run(opts options) error {
 	}
 
 	// Apply the scaffold and report progress
-	err = s.Apply(opts.root, nodes, func(path string, isDir bool) {
+	dirs, files := 0, 0
+	res, err := s.ApplyWithResult(opts.root, nodes, func(path string, isDir bool) {
 		if isDir {
+			dirs++
 			fmt.Printf("📁 mkdir %s\n", path)
 		} else {
+			files++
 			fmt.Printf("📝 write %s\n", path)
 		}
 	})
 
+	// Keep an audit trail of every action, including a failed run's
+	if opts.reportFile != "" {
+		if rerr := writeReport(opts.reportFile, res); rerr != nil {
+			fmt.Fprintf(os.Stderr, "Note: cannot write report: %v\n", rerr)
+		}
+	}
+
 	if err != nil {
 		return fmt.Errorf("scaffold error: %w", err)
 	}
 
+	fmt.Printf("✅ Done: %d directories, %d files written\n", dirs, files)
+
+	// A run that wrote nothing usually means a stale spec or the wrong -root
+	if skipped := res.Count(scaffold.ActionSkipped); opts.failOnSkip && skipped > 0 && filesWritten(res) == 0 {
+		return fmt.Errorf("nothing written: all %s already existed under %s (-fail-on-skip)", plural(skipped, "file"), opts.root)
+	}
 	return nil
 }
 
+// filesWritten counts the files a run wrote. Directories do not count: the
+// progress callback also reports ones that were already there.
+func filesWritten(res *scaffold.ApplyResult) int {
+	n := 0
+	for _, e := range res.Entries {
+		if e.IsDir {
+			continue
+		}
+		switch e.Action {
+		case scaffold.ActionCreated, scaffold.ActionOverwritten, scaffold.ActionMerged, scaffold.ActionRenamed:
+			n++
+		}
+	}
+	return n
+}
+
+// readDocument reads the spec from -from-file, -url, stdin or the clipboard
+// and parses it into a document
+func readDocument(opts options) (parser.Document, error) {
+	// Build the host environment once (exec-backed natively, no-op probes on WASI).
+	e := env.New()
+
+	// Get the input
+	var input io.Reader
+	var err error
+	if opts.fromFile != "" {
+		input, err = readSpecFile(opts.fromFile)
+	} else if opts.url != "" {
+		input, err = fetchSpec(httpClient, opts.url)
+	} else {
+		input, err = getInput(e)
+	}
+	if err != nil {
+		return parser.Document{}, err
+	}
+
+	// Preprocess the input if needed
+	input, err = preprocessInput(input, opts.debug)
+	if err != nil {
+		return parser.Document{}, err
+	}
+
+	// Parse the input into nodes, first explaining how if asked
+	format, err := parser.ParseFormat(opts.format)
+	if err != nil {
+		return parser.Document{}, err
+	}
+	popts := parser.ParseOptions{AssumeDirIfNoExtension: opts.assumeDirs, TrailingSlashOptional: opts.slashOptional, NoRelocate: opts.noRelocate, KeepRoot: opts.keepRoot, Format: format}
+	if opts.debug {
+		popts.OnRewrite = debugRewrite(os.Stdout)
+	}
+	if opts.fromFile != "" {
+		// A spec file's @include lines are relative to the file
+		spec, err := expandHome(opts.fromFile)
+		if err != nil {
+			return parser.Document{}, err
+		}
+		popts.IncludeDir = filepath.Dir(spec)
+	}
+	if opts.pasteReport {
+		if input, err = pasteReport(os.Stdout, input, popts); err != nil {
+			return parser.Document{}, err
+		}
+	}
+	if opts.dirPerRoot {
+		nodes, err := parseDocuments(input, popts)
+		return parser.Document{Nodes: nodes}, err
+	}
+	return parseInput(input, popts)
+}
+
+// parseDocuments parses a spec of several "---"-separated documents and puts
+// each document's nodes under a directory named after its root line, so that
+// "api/ ... --- web/ ..." scaffolds api/ and web/ side by side
+func parseDocuments(input io.Reader, popts parser.ParseOptions) ([]parser.Node, error) {
+	popts.KeepRoot = false // every document's root is kept below
+	docs, err := parser.ParseDocuments(input, popts)
+	if err != nil {
+		return nil, fmt.Errorf("parse error: %w", err)
+	}
+	if len(docs) == 0 {
+		return nil, errors.New("input is empty: pipe a tree via stdin, copy one to the clipboard, or pass -url")
+	}
+
+	var nodes []parser.Node
+	roots := make(map[string]int) // root -> document number
+	for i, doc := range docs {
+		if doc.Root == "" || doc.Root == "." {
+			return nil, fmt.Errorf("document %d has no root line to name its directory after; start it with one such as \"app/\"", i+1)
+		}
+		if j, ok := roots[doc.Root]; ok {
+			return nil, fmt.Errorf("documents %d and %d both have the root %q", j, i+1, doc.Root)
+		}
+		roots[doc.Root] = i + 1
+
+		nodes = append(nodes, parser.Node{Path: doc.Root + "/", IsDir: true, Line: doc.RootLine})
+		for _, n := range doc.Nodes {
+			n.Path = doc.Root + "/" + n.Path
+			n.Depth++
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes, nil
+}
+
+// pasteReport writes how the input is parsed to w and returns the input for
+// parsing it for real
+func pasteReport(w io.Writer, input io.Reader, popts parser.ParseOptions) (io.Reader, error) {
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input: %w", err)
+	}
+	fmt.Fprintln(w, "=== Paste Report ===")
+	if err := parser.Explain(w, bytes.NewReader(data), popts); err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(w, "=== End Paste Report ===")
+	return bytes.NewReader(data), nil
+}
+
+// singleFileNode builds the node for -single-file, whose parent directories
+// Apply creates on the way
+func singleFileNode(path, comment string) (parser.Node, error) {
+	clean := filepath.ToSlash(filepath.Clean(path))
+	switch {
+	case strings.HasSuffix(path, "/") || clean == ".":
+		return parser.Node{}, fmt.Errorf("-single-file %q must name a file, not a directory", path)
+	case filepath.IsAbs(path) || clean == ".." || strings.HasPrefix(clean, "../"):
+		return parser.Node{}, fmt.Errorf("-single-file %q must be a path inside -root", path)
+	}
+	return parser.Node{Path: clean, Comment: comment, Depth: parser.PathDepth(clean)}, nil
+}
+
+// readSpecFile reads the spec at path, expanding a leading ~
+func readSpecFile(path string) (io.Reader, error) {
+	path, err := expandHome(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read spec: %w", err)
+	}
+	return bytes.NewReader(data), nil
+}
+
+// readPathList reads one spec path per line from the file at name, ignoring
+// blank lines and # comments. Paths are cleaned the way the parser cleans
+// them: "./cmd/main.go" and "cmd/" become "cmd/main.go" and "cmd".
+func readPathList(name string) ([]string, error) {
+	name, err := expandHome(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read path list: %w", err)
+	}
+	paths := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		paths = append(paths, path.Clean(filepath.ToSlash(line)))
+	}
+	return paths, nil
+}
+
+// watchAndRun scaffolds once and then again after every change to the spec
+// file, until ticks is closed. Runs are additive: existing files are left
+// alone unless the conflict policy says otherwise.
+func watchAndRun(opts options, ticks <-chan time.Time) error {
+	if opts.fromFile == "" {
+		return errors.New("-watch needs -from-file")
+	}
+	if err := run(opts); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	path, err := expandHome(opts.fromFile)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("👀 Watching %s for changes (Ctrl-C to stop)\n", path)
+	return watchFile(path, ticks, func() {
+		fmt.Printf("🔁 %s changed, scaffolding again\n", path)
+		if err := run(opts); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	})
+}
+
 // main is the entry point for the application
 func main() {
 	// Parse command-line flags
 	opts := parseFlags()
 
-	// Run the application
-	err := run(opts)
+	// Run the application, repeatedly in watch mode
+	var err error
+	if opts.watch {
+		err = watchAndRun(opts, time.NewTicker(watchInterval).C)
+	} else {
+		err = run(opts)
+	}
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -0,0 +1,1041 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lancekrogers/tree2scaffold/internal/env"
+	"github.com/lancekrogers/tree2scaffold/pkg/parser"
+	"github.com/lancekrogers/tree2scaffold/pkg/scaffold"
+)
+
+func TestFetchSpec(t *testing.T) {
+	const spec = "app/\n├── cmd/\n│   └── main.go # entry point\n└── README.md\n"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/layout.tree":
+			io.WriteString(w, spec)
+		case "/huge.tree":
+			io.WriteString(w, strings.Repeat("a", maxSpecSize+1))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	r, err := fetchSpec(srv.Client(), srv.URL+"/layout.tree")
+	if err != nil {
+		t.Fatalf("fetchSpec() error = %v", err)
+	}
+	nodes, err := parser.Parse(r)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	found := false
+	for _, n := range nodes {
+		if n.Path == "README.md" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("fetched spec did not parse into the expected nodes: %+v", nodes)
+	}
+
+	if _, err := fetchSpec(srv.Client(), srv.URL+"/missing.tree"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected a 404 error, got %v", err)
+	}
+
+	if _, err := fetchSpec(srv.Client(), srv.URL+"/huge.tree"); err == nil || !strings.Contains(err.Error(), "limit") {
+		t.Errorf("expected a size limit error, got %v", err)
+	}
+}
+
+func TestExpandHome(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skipf("no home directory: %v", err)
+	}
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"~", home},
+		{"~/projects/foo", filepath.Join(home, "projects", "foo")},
+		{"./relative", "./relative"},
+		{"/abs/~/path", "/abs/~/path"},
+		{"~other/foo", "~other/foo"},
+	}
+	for _, tt := range tests {
+		got, err := expandHome(tt.in)
+		if err != nil {
+			t.Errorf("expandHome(%q) error = %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("expandHome(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestConflictPolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		opts options
+		want scaffold.ConflictPolicy
+	}{
+		{"default skips", options{onConflict: "skip"}, scaffold.ConflictSkip},
+		{"-force does not overwrite files", options{onConflict: "skip", forceOverwrite: true}, scaffold.ConflictSkip},
+		{"-force-overwrite-files overwrites", options{onConflict: "skip", overwriteFiles: true}, scaffold.ConflictOverwrite},
+		{"-on-conflict overwrite", options{onConflict: "overwrite"}, scaffold.ConflictOverwrite},
+		{"-on-conflict rename", options{onConflict: "rename"}, scaffold.ConflictRename},
+		{"-on-conflict-rename renames", options{onConflict: "skip", renameFiles: true}, scaffold.ConflictRename},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := conflictPolicy(tt.opts)
+			if err != nil {
+				t.Fatalf("conflictPolicy() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("conflictPolicy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := conflictPolicy(options{onConflict: "clobber"}); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+	if _, err := conflictPolicy(options{overwriteFiles: true, renameFiles: true}); err == nil {
+		t.Error("expected an error for overwriting and renaming at once")
+	}
+}
+
+func TestDryRunShortcut(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{nil, false},
+		{[]string{"-d"}, true},
+		{[]string{"-dry-run"}, true},
+		{[]string{"-d=false"}, false},
+		{[]string{"-dry-run=false", "-d"}, true},
+		{[]string{"-d", "-dry-run=false"}, false},
+		{[]string{"-d", "-dry-run"}, true},
+	}
+
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("tree2scaffold", flag.ContinueOnError)
+		opts, err := parseArgs(fs, tt.args)
+		if err != nil {
+			t.Fatalf("parseArgs(%q) error = %v", tt.args, err)
+		}
+		if opts.dryRun != tt.want {
+			t.Errorf("parseArgs(%q) dryRun = %v, want %v", tt.args, opts.dryRun, tt.want)
+		}
+	}
+}
+
+func TestCreateParentsFlag(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool // requireParents
+	}{
+		{nil, false},
+		{[]string{"-create-parents"}, false},
+		{[]string{"-create-parents=false"}, true},
+		{[]string{"-create-parents=0"}, true},
+	}
+
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("tree2scaffold", flag.ContinueOnError)
+		opts, err := parseArgs(fs, tt.args)
+		if err != nil {
+			t.Fatalf("parseArgs(%q) error = %v", tt.args, err)
+		}
+		if opts.requireParents != tt.want {
+			t.Errorf("parseArgs(%q) requireParents = %v, want %v", tt.args, opts.requireParents, tt.want)
+		}
+	}
+
+	root := t.TempDir()
+	err := run(options{root: root, singleFile: "internal/util/util.go", requireParents: true})
+	if err == nil || !strings.Contains(err.Error(), "does not declare") {
+		t.Errorf("run() error = %v, want an undeclared parent error", err)
+	}
+}
+
+func TestPairsFlag(t *testing.T) {
+	var p pairsFlag
+	if err := p.Set(".mjs=.js,.gotmpl=.go"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := p.Set("cjs=js"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if got := p.String(); got != ".mjs=.js,.gotmpl=.go,cjs=js" {
+		t.Errorf("String() = %q", got)
+	}
+	if err := p.Set("novalue"); err == nil {
+		t.Error("Set() accepted a pair without '='")
+	}
+
+	gen, err := newContentGenerator(options{extMap: p})
+	if err != nil {
+		t.Fatalf("newContentGenerator() error = %v", err)
+	}
+	if got := gen.GenerateContent("lib/a.cjs", "x"); got != "// x\n" {
+		t.Errorf("-ext-map without dots not applied: %q", got)
+	}
+}
+
+func TestCommentSyntaxFlag(t *testing.T) {
+	var p pairsFlag
+	if err := p.Set(".lua=--,el=;;"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := p.Set(".ml=(*|*),*=#"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	gen, err := newContentGenerator(options{commentSyntax: p})
+	if err != nil {
+		t.Fatalf("newContentGenerator() error = %v", err)
+	}
+	for path, want := range map[string]string{
+		"init.lua":  "-- x\n",
+		"init.el":   ";; x\n",
+		"parser.ml": "(* x *)\n",
+		"data.bin":  "# x\n",
+	} {
+		if got := gen.GenerateContent(path, "x"); got != want {
+			t.Errorf("GenerateContent(%q) = %q, want %q", path, got, want)
+		}
+	}
+
+	if _, err := newContentGenerator(options{commentSyntax: pairsFlag{{key: ".lua", value: ""}}}); err == nil {
+		t.Error("newContentGenerator() accepted an empty comment prefix")
+	}
+}
+
+func TestVerifyOnly(t *testing.T) {
+	nodes, err := parser.Parse(strings.NewReader("app/\n├── cmd/\n│   └── main.go\n└── README.md\n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	root := t.TempDir()
+	if err := scaffold.NewScaffolder().Apply(root, nodes, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	var out strings.Builder
+	if err := verifyOnly(&out, root, nodes); err != nil {
+		t.Fatalf("verifyOnly() on a matching tree error = %v\n%s", err, out.String())
+	}
+
+	// Drift: one declared file removed, one undeclared file and dir added
+	if err := os.Remove(filepath.Join(root, "README.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "cmd", "extra.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "tmp", "cache"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	out.Reset()
+	if err := verifyOnly(&out, root, nodes); err == nil {
+		t.Fatal("verifyOnly() did not report drift")
+	}
+	for _, want := range []string{"- README.md\n", "+ cmd/extra.go\n", "+ tmp/\n"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("diff output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestParseInputRejectsJunk(t *testing.T) {
+	junk := "Hi team, notes from today's meeting:\nwe agreed to ship on Friday\n\n- Alice will review\n"
+	_, err := parseInput(strings.NewReader(junk), parser.ParseOptions{})
+	if err == nil {
+		t.Fatal("parseInput() accepted text without any paths")
+	}
+	for _, want := range []string{"no files or directories recognized", "    Hi team, notes from today's meeting:", "    we agreed to ship on Friday"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+
+	if _, err := parseInput(strings.NewReader(" \n\n"), parser.ParseOptions{}); err == nil || !strings.Contains(err.Error(), "input is empty") {
+		t.Errorf("parseInput() on blank input error = %v, want an empty-input error", err)
+	}
+
+	if _, err := parseInput(strings.NewReader("name: app\nfiles: []\n"), parser.ParseOptions{}); err == nil || !strings.Contains(err.Error(), "looks like YAML") {
+		t.Errorf("parseInput() on YAML error = %v, want an unsupported format error", err)
+	}
+
+	doc, err := parseInput(strings.NewReader("cmd/\ncmd/main.go\n"), parser.ParseOptions{})
+	if err != nil || len(doc.Nodes) != 2 {
+		t.Errorf("parseInput() on a path list = %+v, %v", doc.Nodes, err)
+	}
+}
+
+func TestWatchRescaffoldsOnChange(t *testing.T) {
+	root := t.TempDir()
+	spec := filepath.Join(t.TempDir(), "app.tree")
+	if err := os.WriteFile(spec, []byte("first.txt\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ticks := make(chan time.Time)
+	done := make(chan error)
+	go func() {
+		done <- watchAndRun(options{root: root, fromFile: spec}, ticks)
+	}()
+
+	// The first pass runs before watching starts
+	ticks <- time.Now()
+	if _, err := os.Stat(filepath.Join(root, "first.txt")); err != nil {
+		t.Fatalf("first pass did not run: %v", err)
+	}
+
+	// An edit is picked up once the file has been stable for a tick
+	if err := os.WriteFile(spec, []byte("first.txt\nsecond.txt\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ticks <- time.Now() // change noticed
+	if _, err := os.Stat(filepath.Join(root, "second.txt")); err == nil {
+		t.Fatal("scaffolded again before the debounce tick")
+	}
+	ticks <- time.Now() // stable: run again
+	close(ticks)
+	if err := <-done; err != nil {
+		t.Fatalf("watchAndRun() error = %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "second.txt")); err != nil {
+		t.Errorf("second pass did not run: %v", err)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "README.md"), []byte("keep\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	nodes := []parser.Node{
+		{Path: "cmd/", IsDir: true},
+		{Path: "cmd/main.go"},
+		{Path: "README.md"},
+	}
+	s := scaffold.NewScaffolder()
+	s.Log = io.Discard
+	res, err := s.ApplyWithResult(root, nodes, nil)
+	if err != nil {
+		t.Fatalf("ApplyWithResult() error = %v", err)
+	}
+	if res.Count(scaffold.ActionCreated) != 2 || res.Count(scaffold.ActionSkipped) != 1 {
+		t.Fatalf("unexpected result: %+v", res.Entries)
+	}
+
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "report.json")
+	if err := writeReport(jsonPath, res); err != nil {
+		t.Fatalf("writeReport(json) error = %v", err)
+	}
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded scaffold.ApplyResult
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("report is not JSON: %v\n%s", err, data)
+	}
+	if !reflect.DeepEqual(&decoded, res) {
+		t.Errorf("JSON report = %+v, want %+v", decoded, res)
+	}
+
+	txtPath := filepath.Join(dir, "report.txt")
+	if err := writeReport(txtPath, res); err != nil {
+		t.Fatalf("writeReport(txt) error = %v", err)
+	}
+	data, err = os.ReadFile(txtPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "created     cmd/\nskipped     README.md (already exists)\ncreated     cmd/main.go\n"
+	if string(data) != want {
+		t.Errorf("text report = %q, want %q", data, want)
+	}
+}
+
+func TestFailOnSkip(t *testing.T) {
+	root := t.TempDir()
+	spec := filepath.Join(t.TempDir(), "app.tree")
+	if err := os.WriteFile(spec, []byte("app/\n├── cmd/\n│   └── main.go\n└── README.md\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := options{root: root, fromFile: spec, failOnSkip: true}
+
+	if err := run(opts); err != nil {
+		t.Fatalf("first run() error = %v", err)
+	}
+
+	// Everything exists now, so a second run is a no-op; the existing cmd/
+	// does not count as written
+	err := run(opts)
+	if err == nil {
+		t.Fatal("run() on an existing tree succeeded with -fail-on-skip")
+	}
+	if !strings.Contains(err.Error(), "all 2 files already existed") {
+		t.Errorf("error = %v, want it to count the skipped files", err)
+	}
+
+	opts.failOnSkip = false
+	if err := run(opts); err != nil {
+		t.Errorf("run() without -fail-on-skip error = %v", err)
+	}
+
+	// Directories are never skipped, so a directory-only spec always passes
+	dirSpec := filepath.Join(t.TempDir(), "dirs.tree")
+	if err := os.WriteFile(dirSpec, []byte("app/\n├── cmd/\n└── docs/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts = options{root: t.TempDir(), fromFile: dirSpec, failOnSkip: true}
+	for i := 0; i < 2; i++ {
+		if err := run(opts); err != nil {
+			t.Errorf("run() #%d of a directory-only spec error = %v", i+1, err)
+		}
+	}
+}
+
+func TestOverwriteList(t *testing.T) {
+	root := t.TempDir()
+	dir := t.TempDir()
+	spec := filepath.Join(dir, "app.tree")
+	if err := os.WriteFile(spec, []byte("app/\n├── main.go # regenerated\n└── util.go # regenerated\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	list := filepath.Join(dir, "overwrite.txt")
+	if err := os.WriteFile(list, []byte("# only the entry point\n./main.go\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"main.go", "util.go"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("original\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := run(options{root: root, fromFile: spec, overwriteList: list}); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	for name, want := range map[string]bool{"main.go": true, "util.go": false} {
+		data, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(string(data), "regenerated"); got != want {
+			t.Errorf("%s overwritten = %v, want %v (content %q)", name, got, want, data)
+		}
+	}
+}
+
+func TestOutputDirPerRoot(t *testing.T) {
+	root := t.TempDir()
+	spec := filepath.Join(t.TempDir(), "projects.tree")
+	input := `api/
+├── cmd/
+│   └── main.go
+└── go.mod
+---
+web/
+├── index.html
+└── src/
+    └── app.ts
+`
+	if err := os.WriteFile(spec, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run(options{root: root, fromFile: spec, dirPerRoot: true}); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	for _, p := range []string{"api/cmd/main.go", "api/go.mod", "web/index.html", "web/src/app.ts"} {
+		if _, err := os.Stat(filepath.Join(root, p)); err != nil {
+			t.Errorf("expected %s: %v", p, err)
+		}
+	}
+
+	// Without a root line there is nothing to name the directory after
+	nodes, err := parseDocuments(strings.NewReader("api/\n└── go.mod\n---\nmain.go\n"), parser.ParseOptions{})
+	if err == nil || !strings.Contains(err.Error(), "document 2 has no root line") {
+		t.Errorf("parseDocuments() = %v, %v; want an error for document 2", nodes, err)
+	}
+}
+
+func TestCreateRootReadme(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "myapp")
+	spec := filepath.Join(t.TempDir(), "app.tree")
+	if err := os.WriteFile(spec, []byte("app/\n└── main.go\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(options{root: root, fromFile: spec, rootReadme: true}); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(root, "README.md"))
+	if err != nil {
+		t.Fatalf("README.md was not created: %v", err)
+	}
+	if !strings.Contains(string(data), "myapp") {
+		t.Errorf("README.md = %q, want the project name", data)
+	}
+
+	// The root line's comment describes the project
+	root = filepath.Join(t.TempDir(), "shop")
+	if err := os.WriteFile(spec, []byte("app/ // online store backend\n└── main.go\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(options{root: root, fromFile: spec, rootReadme: true}); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(root, "README.md")); err != nil || !strings.Contains(string(data), "shop: online store backend") {
+		t.Errorf("README.md = %q, %v; want the name and root comment", data, err)
+	}
+
+	// The spec's own README wins
+	root = t.TempDir()
+	if err := os.WriteFile(spec, []byte("app/\n├── readme.md # docs\n└── main.go\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(options{root: root, fromFile: spec, rootReadme: true}); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if strings.Join(names, " ") != "main.go readme.md" {
+		t.Errorf("root holds %v, want only main.go and readme.md", names)
+	}
+}
+
+func TestSingleFile(t *testing.T) {
+	root := t.TempDir()
+	if err := run(options{root: root, singleFile: "internal/util/util.go", comment: "helpers"}); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "internal", "util", "util.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "// helpers\n\npackage util\n\n// TODO: implement util.go\n"; string(data) != want {
+		t.Errorf("util.go = %q, want %q", data, want)
+	}
+	entries, _ := os.ReadDir(root)
+	if len(entries) != 1 {
+		t.Errorf("root has %d entries, want only internal/", len(entries))
+	}
+
+	for _, bad := range []string{"internal/util/", "../outside.go", "/etc/passwd"} {
+		if err := run(options{root: root, singleFile: bad}); err == nil {
+			t.Errorf("run() with -single-file %q succeeded", bad)
+		}
+	}
+}
+
+// stubEnv is an environment whose clipboard blocks until release is closed
+type stubEnv struct {
+	release chan struct{}
+}
+
+func (stubEnv) GoVersion() (string, error)          { return "", env.ErrUnsupported }
+func (stubEnv) GitRemoteOriginURL() (string, error) { return "", env.ErrUnsupported }
+func (stubEnv) Getwd() (string, error)              { return os.Getwd() }
+func (stubEnv) Run(string, []string, []byte) ([]byte, error) {
+	return nil, env.ErrUnsupported
+}
+func (e stubEnv) Clipboard() ([]byte, error) {
+	<-e.release
+	return []byte("from-clipboard.txt\n"), nil
+}
+
+func TestReadInput(t *testing.T) {
+	const spec = "app/\n└── main.go\n"
+	e := stubEnv{release: make(chan struct{})}
+	defer close(e.release)
+
+	// Redirected from a regular file: tree2scaffold < spec.tree
+	path := filepath.Join(t.TempDir(), "spec.tree")
+	if err := os.WriteFile(path, []byte(spec), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if got := readAllInput(t, f, e); got != spec {
+		t.Errorf("readInput(file) = %q, want %q", got, spec)
+	}
+
+	// Piped: cat spec.tree | tree2scaffold
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	go func() {
+		io.WriteString(w, spec)
+		w.Close()
+	}()
+	if got := readAllInput(t, r, e); got != spec {
+		t.Errorf("readInput(pipe) = %q, want %q", got, spec)
+	}
+
+	// A terminal falls back to the clipboard, which must not hang forever
+	devNull, err := os.Open(os.DevNull) // a character device, like a terminal
+	if err != nil {
+		t.Skip(err)
+	}
+	defer devNull.Close()
+	old := clipboardTimeout
+	clipboardTimeout = 20 * time.Millisecond
+	defer func() { clipboardTimeout = old }()
+	if _, err := readInput(devNull, e); err == nil || !strings.Contains(err.Error(), "no answer after") {
+		t.Errorf("readInput(char device) with a stuck clipboard error = %v", err)
+	}
+}
+
+// readAllInput reads everything readInput returns for stdin
+func readAllInput(t *testing.T, stdin *os.File, e stubEnv) string {
+	t.Helper()
+	r, err := readInput(stdin, e)
+	if err != nil {
+		t.Fatalf("readInput() error = %v", err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	return string(data)
+}
+
+func TestStatSummary(t *testing.T) {
+	nodes, err := parser.Parse(strings.NewReader(`app/
+├── Dockerfile
+├── README.md
+├── go.mod
+├── cmd/
+│   └── app/
+│       ├── main.go
+│       └── flags.go
+├── internal/
+│   └── store/
+│       ├── store.go
+│       └── doc.md
+└── latest -> cmd/app`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := "3 .go, 2 .md, 1 .mod, 1 Dockerfile, 1 link, 4 dirs"
+	if got := statSummary(nodes); got != want {
+		t.Errorf("statSummary() = %q, want %q", got, want)
+	}
+}
+
+func TestCountOnly(t *testing.T) {
+	input := `app/
+├── cmd/
+│   └── app/
+│       └── main.go
+├── go.mod
+└── README.md
+`
+	nodes, err := parser.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if got, want := countSummary(nodes), "3 files, 2 directories"; got != want {
+		t.Errorf("countSummary() = %q, want %q", got, want)
+	}
+	if got, want := countSummary(nodes[:2]), "0 files, 2 directories"; got != want {
+		t.Errorf("countSummary() = %q, want %q", got, want)
+	}
+
+	root := filepath.Join(t.TempDir(), "out")
+	spec := filepath.Join(t.TempDir(), "app.tree")
+	if err := os.WriteFile(spec, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(options{root: root, fromFile: spec, countOnly: true}); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if _, err := os.Stat(root); !os.IsNotExist(err) {
+		t.Errorf("-count-only created %s: %v", root, err)
+	}
+}
+
+func TestRespectGitignore(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte("node_modules/\n*.log\n!keep.log\n/build\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	spec := filepath.Join(t.TempDir(), "app.tree")
+	input := `app/
+├── node_modules/
+│   └── left-pad/
+│       └── index.js
+├── build/
+│   └── app.bin
+├── src/
+│   ├── build/
+│   │   └── main.js
+│   └── index.js
+├── debug.log
+├── keep.log
+└── package.json
+`
+	if err := os.WriteFile(spec, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run(options{root: root, fromFile: spec, respectIgnore: true}); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	for _, p := range []string{"src/build/main.js", "src/index.js", "keep.log", "package.json"} {
+		if _, err := os.Stat(filepath.Join(root, p)); err != nil {
+			t.Errorf("expected %s: %v", p, err)
+		}
+	}
+	for _, p := range []string{"node_modules", "build", "debug.log"} {
+		if _, err := os.Stat(filepath.Join(root, p)); !os.IsNotExist(err) {
+			t.Errorf("%s is ignored but was created", p)
+		}
+	}
+}
+
+func TestPasteReport(t *testing.T) {
+	spec := "src\n├── lib.rs # crate root\n└── bin/\n"
+	var out strings.Builder
+	input, err := pasteReport(&out, strings.NewReader(spec), parser.ParseOptions{})
+	if err != nil {
+		t.Fatalf("pasteReport() error = %v", err)
+	}
+	for _, want := range []string{
+		"Format: tree\n",
+		"Indent unit: 4 columns\n",
+		"-> lib.rs (file, depth 0, comment \"crate root\")\n",
+		"-> bin/ (dir, depth 0)\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report missing %q:\n%s", want, out.String())
+		}
+	}
+
+	// The input is still there to be parsed
+	doc, err := parseInput(input, parser.ParseOptions{})
+	if err != nil || len(doc.Nodes) != 2 {
+		t.Errorf("parseInput() after the report = %v, %v", doc.Nodes, err)
+	}
+}
+
+func TestDebugRewrite(t *testing.T) {
+	input := `app/
+├── internal/
+│   ├── ui/
+│   │   └── view.go
+│   └── ui.go
+└── main.go
+`
+	var out strings.Builder
+	doc, err := parseInput(strings.NewReader(input), parser.ParseOptions{OnRewrite: debugRewrite(&out)})
+	if err != nil {
+		t.Fatalf("parseInput() error = %v", err)
+	}
+
+	want := "Rewrite [fixNestedPaths] line 5: internal/ui.go (IsDir=false) -> internal/ui/ui.go (IsDir=false)\n"
+	if out.String() != want {
+		t.Errorf("debug output = %q, want %q", out.String(), want)
+	}
+	var paths []string
+	for _, n := range doc.Nodes {
+		paths = append(paths, n.Path)
+	}
+	if !slices.Contains(paths, "internal/ui/ui.go") {
+		t.Errorf("nodes = %v, want the relocated internal/ui/ui.go", paths)
+	}
+
+	out.Reset()
+	popts := parser.ParseOptions{AssumeDirIfNoExtension: true, OnRewrite: debugRewrite(&out)}
+	if _, err := parseInput(strings.NewReader("main.go\nbin\n"), popts); err != nil {
+		t.Fatalf("parseInput() error = %v", err)
+	}
+	want = "Rewrite [postProcessDirectories] line 2: bin (IsDir=false) -> bin/ (IsDir=true)\n"
+	if out.String() != want {
+		t.Errorf("debug output = %q, want %q", out.String(), want)
+	}
+
+	// A bare name heading the list is its root, even under
+	// -assume-dir-if-no-extension, so bin is stripped rather than retyped
+	out.Reset()
+	doc, err = parseInput(strings.NewReader("bin\nmain.go\n"), popts)
+	if err != nil {
+		t.Fatalf("parseInput() error = %v", err)
+	}
+	want = "Rewrite [simpleRoot] line 1: bin (IsDir=false) -> bin/ (IsDir=true)\n"
+	if out.String() != want {
+		t.Errorf("debug output = %q, want %q", out.String(), want)
+	}
+	if doc.Root != "bin" || len(doc.Nodes) != 1 || doc.Nodes[0].Path != "main.go" {
+		t.Errorf("parseInput() = root %q, nodes %+v; want root bin and only main.go", doc.Root, doc.Nodes)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	root := t.TempDir()
+	spec := filepath.Join(t.TempDir(), "app.tree")
+	input := `app/
+├── shop/
+│   ├── models/
+│   └── cli.py  # command-line entry point
+├── .gitignore
+└── setup.py
+`
+	if err := os.WriteFile(spec, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("tree2scaffold", flag.ContinueOnError)
+	opts, err := parseArgs(fs, []string{"-profile", "python", "-root", root, "-from-file", spec})
+	if err != nil {
+		t.Fatalf("parseArgs() error = %v", err)
+	}
+	if opts.seedEntry != "python" {
+		t.Errorf("seedEntry = %q, want the profile's python", opts.seedEntry)
+	}
+	if err := run(opts); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(root, "shop", "cli.py")); err != nil || string(data) != `"""command-line entry point"""`+"\n" {
+		t.Errorf("cli.py = %q, %v; want a docstring", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "shop", "models", "__init__.py")); err != nil {
+		t.Errorf("models/__init__.py not seeded: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(root, ".gitignore")); err != nil || !strings.Contains(string(data), "__pycache__/\n") {
+		t.Errorf(".gitignore = %q, %v; want the Python defaults", data, err)
+	}
+
+	// The command line wins over the profile
+	fs = flag.NewFlagSet("tree2scaffold", flag.ContinueOnError)
+	if opts, err = parseArgs(fs, []string{"-seed-entry", "ts", "-profile", "python"}); err != nil {
+		t.Fatalf("parseArgs() error = %v", err)
+	}
+	if opts.seedEntry != "ts" {
+		t.Errorf("seedEntry = %q, want ts from the command line", opts.seedEntry)
+	}
+
+	fs = flag.NewFlagSet("tree2scaffold", flag.ContinueOnError)
+	if opts, err = parseArgs(fs, []string{"-profile", "go"}); err != nil {
+		t.Fatalf("parseArgs() error = %v", err)
+	}
+	if !opts.preserveExist {
+		t.Error("-profile go does not merge into an existing go.mod")
+	}
+
+	fs = flag.NewFlagSet("tree2scaffold", flag.ContinueOnError)
+	if _, err := parseArgs(fs, []string{"-profile", "cobol"}); err == nil || !strings.Contains(err.Error(), `unknown profile "cobol"`) {
+		t.Errorf("parseArgs() error = %v, want an unknown profile error", err)
+	}
+}
+
+func TestPreviewNodes(t *testing.T) {
+	nodes := []parser.Node{
+		{Path: "cmd/", IsDir: true, Comment: "commands"},
+		{Path: "cmd/app/", IsDir: true},
+		{Path: "cmd/app/run.go", Comment: "runs the app"},
+		{Path: "internal/store/main.go"},
+		{Path: "internal/store/store_test.go"},
+		{Path: "go.mod", Comment: "module definition"},
+		{Path: "main.go", Comment: "entry point"},
+	}
+	gen, err := defaultGenerator(options{modulePath: "example.com/app", goSum: "empty"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out strings.Builder
+	previewNodes(&out, nodes, gen, parser.RenderOptions{})
+	want := `☑️  Will create:
+    ├── cmd/ # commands
+    │   └── app/
+    │       └── run.go (package main) # runs the app
+    ├── internal/
+    │   └── store/
+    │       ├── main.go (package store)
+    │       └── store_test.go (package store_test)
+    ├── go.mod # module definition
+    └── main.go (package main) # entry point
+`
+	if out.String() != want {
+		t.Errorf("previewNodes() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestValidationMode(t *testing.T) {
+	spec := filepath.Join(t.TempDir(), "app.tree")
+	if err := os.WriteFile(spec, []byte("app/\n└── conf/\n    └── app.yaml\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	setup := func(t *testing.T) string {
+		root := t.TempDir()
+		if err := os.WriteFile(filepath.Join(root, "conf"), []byte("blocker"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return root
+	}
+
+	tests := []struct {
+		args []string
+		warn bool
+	}{
+		{nil, false},
+		{[]string{"-warn-on-validation"}, true},
+	}
+	for _, tt := range tests {
+		fs := flag.NewFlagSet("tree2scaffold", flag.ContinueOnError)
+		opts, err := parseArgs(fs, append(tt.args, "-from-file", spec))
+		if err != nil {
+			t.Fatalf("parseArgs(%q) error = %v", tt.args, err)
+		}
+		if opts.warnValidation != tt.warn {
+			t.Fatalf("parseArgs(%q) warnValidation = %v, want %v", tt.args, opts.warnValidation, tt.warn)
+		}
+
+		opts.root = setup(t)
+		err = run(opts)
+		info, statErr := os.Stat(filepath.Join(opts.root, "conf"))
+		if tt.warn {
+			if err != nil {
+				t.Errorf("run(%q) error = %v, want the conflict downgraded", tt.args, err)
+			}
+			// The warning announces that the blocking file is removed
+			if statErr != nil || !info.IsDir() {
+				t.Errorf("run(%q) left conf as a file: %v", tt.args, statErr)
+			}
+		} else {
+			if err == nil || !strings.Contains(err.Error(), "a file with the same name already exists") {
+				t.Errorf("run(%q) error = %v, want the validation error", tt.args, err)
+			}
+			if statErr != nil || info.IsDir() {
+				t.Errorf("run(%q) touched conf: %v", tt.args, statErr)
+			}
+		}
+	}
+}
+
+func TestRelativeTo(t *testing.T) {
+	dir := t.TempDir()
+	specs := filepath.Join(dir, "specs")
+	if err := os.Mkdir(specs, 0755); err != nil {
+		t.Fatal(err)
+	}
+	spec := filepath.Join(specs, "app.tree")
+	if err := os.WriteFile(spec, []byte("app/\n├── go.mod\n└── main.go\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run(options{root: "out", fromFile: spec, relativeTo: "spec"}); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	for _, name := range []string{"go.mod", "main.go"} {
+		if _, err := os.Stat(filepath.Join(specs, "out", name)); err != nil {
+			t.Errorf("%s was not created beside the spec: %v", name, err)
+		}
+	}
+
+	// The default root is the spec's own directory
+	if err := run(options{root: ".", fromFile: spec, relativeTo: "spec"}); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(specs, "main.go")); err != nil {
+		t.Errorf("main.go was not created beside the spec: %v", err)
+	}
+
+	abs := t.TempDir()
+	if root, err := resolveRoot(options{root: abs, fromFile: spec, relativeTo: "spec"}); err != nil || root != abs {
+		t.Errorf("resolveRoot(absolute) = %q, %v; want %q", root, err, abs)
+	}
+	if _, err := resolveRoot(options{root: "out", relativeTo: "spec"}); err == nil {
+		t.Error("resolveRoot accepted -relative-to spec without -from-file")
+	}
+	if _, err := resolveRoot(options{root: "out", fromFile: spec, relativeTo: "home"}); err == nil {
+		t.Error("resolveRoot accepted an unknown -relative-to")
+	}
+}
+
+func TestBuiltinTemplatesOptIn(t *testing.T) {
+	spec := filepath.Join(t.TempDir(), "app.tree")
+	if err := os.WriteFile(spec, []byte("app/\n├── app.py  # entry point\n└── tools/\n    └── user_service.py\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args []string
+		want map[string]string
+	}{
+		{nil, map[string]string{
+			"app.py":                "# entry point\n",
+			"tools/user_service.py": "",
+		}},
+		{[]string{"-comment-from-filename"}, map[string]string{
+			"tools/user_service.py": "# user service\n",
+		}},
+		{[]string{"-builtin-templates", "-comment-from-filename"}, map[string]string{
+			"app.py":                `"""entry point"""` + "\n",
+			"tools/user_service.py": `"""user service"""` + "\n",
+		}},
+	}
+	for _, tt := range tests {
+		root := t.TempDir()
+		fs := flag.NewFlagSet("tree2scaffold", flag.ContinueOnError)
+		opts, err := parseArgs(fs, append(tt.args, "-root", root, "-from-file", spec))
+		if err != nil {
+			t.Fatalf("parseArgs(%v) error = %v", tt.args, err)
+		}
+		if err := run(opts); err != nil {
+			t.Fatalf("run(%v) error = %v", tt.args, err)
+		}
+		for name, want := range tt.want {
+			data, err := os.ReadFile(filepath.Join(root, name))
+			if err != nil {
+				t.Fatalf("%v: ReadFile(%s) error = %v", tt.args, name, err)
+			}
+			if string(data) != want {
+				t.Errorf("%v: %s = %q, want %q", tt.args, name, data, want)
+			}
+		}
+	}
+}
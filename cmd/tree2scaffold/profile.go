@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// profile presets the flags suited to one ecosystem, so a new user gets
+// sensible generators and conventions from a single -profile
+type profile struct {
+	// flags are applied as if given on the command line, except the ones
+	// the command line sets itself
+	flags map[string]string
+
+	// gitignore is the body of generated .gitignore files
+	gitignore string
+}
+
+// profiles are the ecosystems -profile accepts
+var profiles = map[string]profile{
+	"go": {
+		flags: map[string]string{
+			"dir-comment": "first-file",
+			"build-tags":  "true",
+			// merge into an existing go.mod rather than skipping it
+			"preserve-existing-content": "true",
+		},
+		gitignore: "/bin/\n*.test\n*.out\ncoverage.*\n",
+	},
+	"node": {
+		flags: map[string]string{
+			"seed-entry": "js",
+		},
+		gitignore: "node_modules/\ndist/\ncoverage/\n*.log\n.env\n",
+	},
+	"python": {
+		flags: map[string]string{
+			"seed-entry":        "python",
+			"builtin-templates": "true", // module docstrings
+		},
+		gitignore: "__pycache__/\n*.py[cod]\n.venv/\n*.egg-info/\ndist/\nbuild/\n.pytest_cache/\n",
+	},
+	"rust": {
+		flags: map[string]string{
+			"seed-entry":     "rust",
+			"comment-syntax": ".rs=//!", // module docs
+		},
+		gitignore: "/target/\n",
+	},
+}
+
+// applyProfile sets the flags of the profile opts names that the command
+// line left alone
+func applyProfile(fs *flag.FlagSet, opts *options) error {
+	if opts.profile == "" {
+		return nil
+	}
+	p, ok := profiles[opts.profile]
+	if !ok {
+		return fmt.Errorf("unknown profile %q (want %s)", opts.profile, strings.Join(slices.Sorted(maps.Keys(profiles)), ", "))
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, name := range slices.Sorted(maps.Keys(p.flags)) {
+		if set[name] {
+			continue
+		}
+		if err := fs.Set(name, p.flags[name]); err != nil {
+			return fmt.Errorf("profile %s: -%s: %w", opts.profile, name, err)
+		}
+	}
+	return nil
+}
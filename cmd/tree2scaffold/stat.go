@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lancekrogers/tree2scaffold/pkg/parser"
+)
+
+// statSummary groups nodes by kind for -stat, e.g. "12 .go, 3 .md,
+// 1 Dockerfile, 4 dirs". Files count under their extension, or their name
+// when they have none; groups are ordered by count, then name, with links and
+// directories last.
+func statSummary(nodes []parser.Node) string {
+	counts := make(map[string]int)
+	seen := make(map[string]bool)
+	dirs, links := 0, 0
+	for _, n := range nodes {
+		path := strings.TrimSuffix(n.Path, "/")
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		switch {
+		case n.IsDir:
+			dirs++
+		case n.LinkTarget != "":
+			links++
+		default:
+			kind := filepath.Ext(path)
+			if kind == "" {
+				kind = filepath.Base(path)
+			}
+			counts[kind]++
+		}
+	}
+
+	kinds := make([]string, 0, len(counts))
+	for kind := range counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool {
+		if counts[kinds[i]] != counts[kinds[j]] {
+			return counts[kinds[i]] > counts[kinds[j]]
+		}
+		return kinds[i] < kinds[j]
+	})
+
+	var parts []string
+	for _, kind := range kinds {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[kind], kind))
+	}
+	if links > 0 {
+		parts = append(parts, plural(links, "link"))
+	}
+	parts = append(parts, plural(dirs, "dir"))
+	return strings.Join(parts, ", ")
+}
+
+// countSummary is the -count-only report, e.g. "42 files, 11 directories".
+// Symlinks count as files.
+func countSummary(nodes []parser.Node) string {
+	seen := make(map[string]bool)
+	files, dirs := 0, 0
+	for _, n := range nodes {
+		path := strings.TrimSuffix(n.Path, "/")
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		if n.IsDir {
+			dirs++
+		} else {
+			files++
+		}
+	}
+	directories := fmt.Sprintf("%d directories", dirs)
+	if dirs == 1 {
+		directories = "1 directory"
+	}
+	return plural(files, "file") + ", " + directories
+}
+
+// plural formats n with word, adding an "s" unless n is 1
+func plural(n int, word string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, word)
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// watchInterval is how often -watch polls the spec file
+const watchInterval = 500 * time.Millisecond
+
+// fileSig identifies a version of a file well enough to notice edits
+type fileSig struct {
+	modTime time.Time
+	size    int64
+}
+
+// statSig returns the current signature of path
+func statSig(path string) (fileSig, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileSig{}, err
+	}
+	return fileSig{modTime: fi.ModTime(), size: fi.Size()}, nil
+}
+
+// watchFile polls path on every tick and calls onChange once the file has
+// changed and then stayed the same for a full tick, so an editor writing in
+// several steps triggers a single run. It returns when ticks is closed.
+func watchFile(path string, ticks <-chan time.Time, onChange func()) error {
+	last, err := statSig(path)
+	if err != nil {
+		return err
+	}
+
+	pending := false
+	for range ticks {
+		sig, err := statSig(path)
+		if err != nil {
+			continue // the file may be mid-replace; look again next tick
+		}
+		if sig != last {
+			last, pending = sig, true
+			continue
+		}
+		if pending {
+			pending = false
+			onChange()
+		}
+	}
+	return nil
+}
@@ -1,8 +1,8 @@
 // Package env abstracts the host-environment probes that are not portable
 // across build targets. Under GOOS=wasip1 there is no process model, so the
-// exec-based probes (clipboard, `go version`, `git config`) are unavailable and
-// report ErrUnsupported; callers MUST fall back to sensible defaults rather than
-// treat that as a hard error. The implementation is selected by build tags:
+// exec-based probes (clipboard, `go version`, `git config`, external commands)
+// are unavailable and report ErrUnsupported; callers MUST fall back to sensible
+// defaults rather than treat that as a hard error. The implementation is selected by build tags:
 // env_exec.go for native builds, env_wasip1.go for WASI.
 package env
 
@@ -30,4 +30,9 @@ type Environment interface {
 	// Clipboard returns the clipboard contents, or (nil, ErrUnsupported) where a
 	// clipboard is unavailable (e.g. under WASI).
 	Clipboard() ([]byte, error)
+
+	// Run executes the named program with args, feeding it stdin, and returns
+	// its standard output, or (nil, ErrUnsupported) where no process can be
+	// spawned (e.g. under WASI).
+	Run(name string, args []string, stdin []byte) ([]byte, error)
 }
@@ -3,6 +3,8 @@
 package env
 
 import (
+	"bytes"
+	"fmt"
 	"os"
 	"os/exec"
 	"strings"
@@ -43,6 +45,23 @@ func (execEnv) Getwd() (string, error) { return os.Getwd() }
 // Clipboard reads the macOS clipboard via pbpaste.
 func (execEnv) Clipboard() ([]byte, error) { return exec.Command("pbpaste").Output() }
 
+// Run executes name with args and returns its stdout. A non-zero exit is
+// reported with the command's stderr so callers can surface the real cause.
+func (execEnv) Run(name string, args []string, stdin []byte) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return out, nil
+}
+
 // parseGoMinor turns a `go version` line into a "major.minor" string, e.g.
 // "go version go1.24.2 darwin/arm64" -> "1.24" and "go version go1.24 ..." ->
 // "1.24". It returns "" when the version string cannot be parsed.
@@ -39,3 +39,17 @@ func TestExecEnvGetwd(t *testing.T) {
 func TestExecEnvImplementsEnvironment(t *testing.T) {
 	var _ Environment = New()
 }
+
+func TestExecEnvRun(t *testing.T) {
+	out, err := New().Run("go", []string{"env", "GOOS"}, nil)
+	if err != nil {
+		t.Skipf("go toolchain unavailable: %v", err)
+	}
+	if len(out) == 0 {
+		t.Fatal("Run() returned empty output")
+	}
+
+	if _, err := New().Run("tree2scaffold-no-such-command", nil, nil); err == nil {
+		t.Error("Run() of a missing command returned no error")
+	}
+}
@@ -17,3 +17,6 @@ func (wasiEnv) GoVersion() (string, error)          { return "", ErrUnsupported
 func (wasiEnv) GitRemoteOriginURL() (string, error) { return "", ErrUnsupported }
 func (wasiEnv) Getwd() (string, error)              { return os.Getwd() }
 func (wasiEnv) Clipboard() ([]byte, error)          { return nil, ErrUnsupported }
+func (wasiEnv) Run(string, []string, []byte) ([]byte, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Metadata columns that `tree -s`/`tree -p` and `ls -l` print before a name
+var (
+	// treeMetaRe matches "[       4096]  ", "[drwxr-xr-x]  " or "[4.0K]  "
+	treeMetaRe = regexp.MustCompile(`^\[\s*([-dlcbps][-rwxsStT]{9}[.+@]?)?\s*(\d[\d.]*[KMGTPE]?)?\s*\]\s+`)
+
+	// lsLongRe matches "-rw-r--r--  1 user staff  1234 Jan  2 15:04 " and the
+	// ISO date variant "... 1234 2024-01-02 15:04 "
+	lsLongRe = regexp.MustCompile(`^([-dlcbps])[-rwxsStT]{9}[.+@]?\s+\d+\s+\S+\s+\S+\s+\d[\d.,]*[KMGTPE]?\s+` +
+		`(?:\w{3}\s+\d{1,2}\s+(?:\d{1,2}:\d{2}|\d{4})|\d{4}-\d{2}-\d{2}\s+\d{2}:\d{2})\s+`)
+
+	// treeReportRe matches the summary `tree` ends with, "3 directories, 5 files"
+	treeReportRe = regexp.MustCompile(`^\d+ director(?:y|ies)(?:, \d+ files?)?$`)
+
+	// gutterRe matches a line number at the start of a line, as `cat -n` and
+	// editor gutters print them, and the blanks after it
+	gutterRe = regexp.MustCompile(`^\s*(\d+)([ \t]*)`)
+)
+
+// stripMetaColumns removes the size and permission columns of `tree -s`,
+// `tree -p` or `ls -l` output from line, keeping any tree prefix. A name whose
+// permissions start with "d" gets a trailing slash so it stays a directory.
+func stripMetaColumns(line string) string {
+	prefix := treePrefix(line)
+	rest := line[len(prefix):]
+
+	var mode string
+	if m := treeMetaRe.FindStringSubmatch(rest); m != nil && (m[1] != "" || m[2] != "") {
+		rest, mode = rest[len(m[0]):], m[1]
+	} else if m := lsLongRe.FindStringSubmatch(rest); m != nil {
+		rest, mode = rest[len(m[0]):], m[1]
+	} else {
+		return line
+	}
+
+	if strings.HasPrefix(mode, "d") {
+		name, tail, _ := strings.Cut(rest, " ")
+		if !strings.HasSuffix(name, "/") {
+			rest = strings.TrimSuffix(name+"/ "+tail, " ")
+		}
+	}
+	return prefix + rest
+}
+
+// isTreeReport reports whether line is the summary line `tree` prints last
+func isTreeReport(line string) bool {
+	return treeReportRe.MatchString(strings.TrimSpace(line))
+}
+
+// stripLineNumbers removes a line number gutter from lines in place, so
+// "  12  ├── main.go" reads "├── main.go". It only acts when every non-blank
+// line is numbered, the numbers count up with the lines and at least one
+// blank separates each number from its text, which keeps names such as
+// "2024/" or list items such as "1. docs" intact. Only the blanks all lines
+// share are removed with the number, so indentation after it survives.
+func stripLineNumbers(lines []string) bool {
+	offset, sep, numbered := 0, -1, 0
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		m := gutterRe.FindStringSubmatch(line)
+		if m == nil {
+			return false
+		}
+		n, err := strconv.Atoi(m[1])
+		if err != nil || (numbered > 0 && n-i != offset) {
+			return false
+		}
+		offset = n - i
+		numbered++
+		if len(m[0]) < len(line) && (sep < 0 || len(m[2]) < sep) {
+			sep = len(m[2])
+		}
+	}
+	if numbered < 2 || sep <= 0 {
+		return false
+	}
+
+	for i, line := range lines {
+		if m := gutterRe.FindStringSubmatchIndex(line); m != nil {
+			rest := line[m[3]:]
+			lines[i] = rest[min(sep, len(rest)):]
+		}
+	}
+	return true
+}
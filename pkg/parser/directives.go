@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// directiveRe matches an "@name" or "@name=value" token in a comment
+var directiveRe = regexp.MustCompile(`^@([A-Za-z][A-Za-z0-9_-]*)(?:=(\S*))?$`)
+
+// splitDirectives separates "@name[=value]" tokens from the prose of a
+// comment. It returns the comment without them and the directives as a map
+// (a bare "@name" maps to ""), or nil when the comment has none.
+func splitDirectives(comment string) (string, map[string]string) {
+	if !strings.Contains(comment, "@") {
+		return comment, nil
+	}
+
+	var words []string
+	var attrs map[string]string
+	for _, word := range strings.Fields(comment) {
+		m := directiveRe.FindStringSubmatch(word)
+		if m == nil {
+			words = append(words, word)
+			continue
+		}
+		if attrs == nil {
+			attrs = make(map[string]string)
+		}
+		attrs[strings.ToLower(m[1])] = m[2]
+	}
+	if attrs == nil {
+		return comment, nil
+	}
+	return strings.Join(words, " "), attrs
+}
+
+// applyDirectives moves the directives out of every node's comment into its Attrs
+func applyDirectives(nodes []Node) {
+	for i := range nodes {
+		nodes[i].Comment, nodes[i].Attrs = splitDirectives(nodes[i].Comment)
+	}
+}
+
+// decodeBase64Content turns an "@base64=<data>" directive into the node's
+// literal content, so small binary assets can be declared inline. A node with
+// bad data keeps its directive and no content; the first such problem is
+// returned after every node has been tried.
+func decodeBase64Content(nodes []Node) error {
+	var first error
+	for i := range nodes {
+		data, ok := nodes[i].Attrs["base64"]
+		if !ok || nodes[i].IsDir {
+			continue
+		}
+		if nodes[i].Content != nil {
+			if first == nil {
+				first = fmt.Errorf("line %d: %s has both a heredoc and @base64 content", nodes[i].Line, nodes[i].Path)
+			}
+			continue
+		}
+		content, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			if first == nil {
+				first = fmt.Errorf("line %d: invalid @base64 data for %s: %w", nodes[i].Line, nodes[i].Path, err)
+			}
+			continue
+		}
+		nodes[i].Content = content
+
+		delete(nodes[i].Attrs, "base64")
+		if len(nodes[i].Attrs) == 0 {
+			nodes[i].Attrs = nil
+		}
+	}
+	return first
+}
@@ -0,0 +1,121 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Document is one spec of a multi-document input
+type Document struct {
+	// Root is the name of the root line a tree or simple list starts with,
+	// e.g. "app" for "app/", which Parse strips from the paths unless
+	// KeepRoot is set. Empty when the document has no root line, such as a
+	// plain file list or a partial tree.
+	Root string
+
+	// RootLine is the input line of Root; 0 when there is none
+	RootLine int
+
+	// Comment is the comment of the root line, e.g. "my application" for
+	// "app/ # my application" or "app/ // my application"
+	Comment string
+
+	Nodes []Node
+}
+
+// ParseDocuments reads several specs separated by "---" lines and parses each
+// on its own, as if it were the whole input. Node lines count from the start
+// of r. Empty documents are dropped. On a parse error the documents before
+// the broken one are returned with the error.
+func ParseDocuments(r io.Reader, opts ParseOptions) ([]Document, error) {
+	var docs []Document
+	var buf bytes.Buffer
+	start, num := 1, 0 // first line of the current document, lines read
+
+	flush := func() error {
+		defer buf.Reset()
+		if strings.TrimSpace(buf.String()) == "" {
+			return nil
+		}
+		doc, err := parseDocument(buf.Bytes(), opts)
+		if err != nil {
+			return fmt.Errorf("document %d: %w", len(docs)+1, err)
+		}
+		for i := range doc.Nodes {
+			if doc.Nodes[i].Line > 0 {
+				doc.Nodes[i].Line += start - 1
+			}
+		}
+		if doc.RootLine > 0 {
+			doc.RootLine += start - 1
+		}
+		docs = append(docs, doc)
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		num++
+		if strings.TrimSpace(scanner.Text()) == "---" {
+			if err := flush(); err != nil {
+				return docs, err
+			}
+			start = num + 1
+			continue
+		}
+		buf.Write(scanner.Bytes())
+		buf.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return docs, fmt.Errorf("line %d: %w", num+1, err)
+	}
+	return docs, flush()
+}
+
+// ParseDocument parses r as a single spec, like ParseWithOptions, and also
+// returns the root line it strips. On a parse error the document holds the
+// nodes before the broken line.
+func ParseDocument(r io.Reader, opts ParseOptions) (Document, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Document{}, err
+	}
+	return parseDocument(data, opts)
+}
+
+// parseDocument parses one document and finds the root line the tree parser
+// strips from it
+func parseDocument(data []byte, opts ParseOptions) (Document, error) {
+	nodes, err := ParseWithOptions(bytes.NewReader(data), opts)
+	doc := Document{Nodes: nodes}
+	if err != nil {
+		return doc, err
+	}
+
+	lines, contents, _ := readLines(bytes.NewReader(data))
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		texts[i] = line.text
+	}
+	format := opts.Format
+	if format == FormatUnknown {
+		format = detectFormat(texts)
+	}
+	switch {
+	case format == FormatTree && len(lines) > 0 && !strings.HasPrefix(lines[0].text, "├──"):
+		if root, comment := rootLine(lines[0].text); root != "" {
+			doc.Root = root
+			doc.RootLine = lines[0].num
+			doc.Comment = comment
+		}
+	case format == FormatSimple:
+		if root := simpleRoot(lines, contents, opts); root != "" {
+			doc.Root = root
+			doc.RootLine = lines[0].num
+		}
+	}
+	return doc, nil
+}
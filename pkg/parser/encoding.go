@@ -0,0 +1,58 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// decodeInput transcodes UTF-16 input marked by a byte order mark, as
+// written by Windows clipboards, to UTF-8 and drops a UTF-8 BOM. Anything
+// else is returned unchanged.
+func decodeInput(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	bom, err := br.Peek(3)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	var order binary.ByteOrder
+	switch {
+	case bytes.HasPrefix(bom, []byte{0xEF, 0xBB, 0xBF}):
+		br.Discard(3)
+		return br, nil
+	case bytes.HasPrefix(bom, []byte{0xFF, 0xFE}):
+		order = binary.LittleEndian
+	case bytes.HasPrefix(bom, []byte{0xFE, 0xFF}):
+		order = binary.BigEndian
+	default:
+		return br, nil
+	}
+
+	data, err := io.ReadAll(br)
+	if err != nil {
+		return nil, err
+	}
+	units := make([]uint16, 0, len(data)/2)
+	for i := 2; i+1 < len(data); i += 2 { // skip the BOM
+		units = append(units, order.Uint16(data[i:]))
+	}
+	return bytes.NewReader([]byte(string(utf16.Decode(units)))), nil
+}
+
+// normalizeSpaces turns the non-breaking and other Unicode spaces that trees
+// copied from rendered Markdown or web pages carry, as does `tree` itself in
+// some locales, into plain spaces so indentation and names split as usual
+func normalizeSpaces(line string) string {
+	return strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII && unicode.IsSpace(r) {
+			return ' '
+		}
+		return r
+	}, line)
+}
@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Explain writes how Parse reads r: the detected format, the indent unit of a
+// tree, and for every input line the node it became (path, kind, depth and
+// comment) or that it produced none. Directories only implied by a path are
+// listed last. It is meant for debugging surprising results.
+func Explain(w io.Writer, r io.Reader, opts ParseOptions) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	lines, _, err := readLines(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		texts[i] = line.text
+	}
+
+	format := opts.Format
+	if format == FormatUnknown {
+		format = detectFormat(texts)
+	}
+	fmt.Fprintf(w, "Format: %s\n", format)
+	if format == FormatTree {
+		fmt.Fprintf(w, "Indent unit: %d columns\n", indentUnit(lines))
+	}
+
+	nodes, err := ParseWithOptions(bytes.NewReader(data), opts)
+	if err != nil {
+		fmt.Fprintf(w, "Error: %v\n", err)
+	}
+	byLine := make(map[int][]Node)
+	var implied []Node
+	for _, n := range nodes {
+		if n.Line == 0 {
+			implied = append(implied, n)
+			continue
+		}
+		byLine[n.Line] = append(byLine[n.Line], n)
+	}
+
+	fmt.Fprintln(w, "Lines:")
+	for _, line := range lines {
+		fmt.Fprintf(w, "  %3d  %s\n", line.num, strings.TrimRight(line.text, " "))
+		made := byLine[line.num]
+		if len(made) == 0 {
+			fmt.Fprintln(w, "       -> no node")
+		}
+		for _, n := range made {
+			fmt.Fprintf(w, "       -> %s\n", describeParsed(n))
+		}
+	}
+	if len(implied) > 0 {
+		fmt.Fprintln(w, "Implied:")
+		for _, n := range implied {
+			fmt.Fprintf(w, "       -> %s\n", describeParsed(n))
+		}
+	}
+	return nil
+}
+
+// describeParsed summarizes one node for Explain, e.g.
+// `cmd/main.go (file, depth 1, comment "entry point")`
+func describeParsed(n Node) string {
+	kind := "file"
+	switch {
+	case n.IsDir:
+		kind = "dir"
+	case n.LinkTarget != "":
+		kind = "link to " + n.LinkTarget
+	}
+	s := fmt.Sprintf("%s (%s, depth %d", n.Path, kind, n.Depth)
+	if n.Comment != "" {
+		s += fmt.Sprintf(", comment %q", n.Comment)
+	}
+	return s + ")"
+}
@@ -0,0 +1,158 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Format is the kind of spec an input contains
+type Format int
+
+const (
+	// FormatUnknown is reported for empty or blank input
+	FormatUnknown Format = iota
+	// FormatTree is `tree`-style output with ├── and └── connectors
+	FormatTree
+	// FormatSimple is one path per line
+	FormatSimple
+	// FormatJSON is a JSON document
+	FormatJSON
+	// FormatYAML is a YAML document
+	FormatYAML
+	// FormatLsR is `ls -R` output: "dir:" headers each followed by bare names
+	FormatLsR
+	// FormatList is a nested Markdown-style list: "- cmd/", "  - main.go"
+	FormatList
+)
+
+// String returns the lower-case name of the format
+func (f Format) String() string {
+	switch f {
+	case FormatTree:
+		return "tree"
+	case FormatSimple:
+		return "simple"
+	case FormatJSON:
+		return "json"
+	case FormatYAML:
+		return "yaml"
+	case FormatLsR:
+		return "ls-r"
+	case FormatList:
+		return "list"
+	default:
+		return "unknown"
+	}
+}
+
+// yamlKeyRe matches a top-level "key:" or "key: value" line
+var yamlKeyRe = regexp.MustCompile(`^[A-Za-z_][\w.-]*:(\s|$)`)
+
+// DetectFormat sniffs the format of the spec in r. Since sniffing consumes r,
+// it also returns a reader that yields the complete input again, transcoded
+// to UTF-8 like Parse does.
+func DetectFormat(r io.Reader) (Format, io.Reader, error) {
+	r, err := decodeInput(r)
+	if err != nil {
+		return FormatUnknown, nil, err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return FormatUnknown, nil, err
+	}
+	return detectFormat(strings.Split(string(data), "\n")), bytes.NewReader(data), nil
+}
+
+// detectFormat classifies input lines. JSON is recognized by the input being
+// one JSON value, YAML by every line belonging to a top-level mapping and
+// lists by every line being an item; otherwise any tree glyph in front of a
+// path makes the input a tree. A first line like "[slug]/page.tsx" or "src:"
+// alone is not enough.
+func detectFormat(lines []string) Format {
+	first := ""
+	for _, line := range lines {
+		if first = strings.TrimSpace(line); first != "" && !isCodeFence(first) {
+			break
+		}
+	}
+
+	switch {
+	case first == "":
+		return FormatUnknown
+	case isLsR(lines):
+		return FormatLsR
+	case (strings.HasPrefix(first, "{") || strings.HasPrefix(first, "[")) && isJSON(lines):
+		return FormatJSON
+	case (first == "---" || yamlKeyRe.MatchString(first)) && isYAMLMapping(lines):
+		return FormatYAML
+	case isList(lines):
+		return FormatList
+	}
+
+	// Only glyphs before the path count; a comment may quote a tree
+	for _, line := range lines {
+		if containsTreeChar(treePrefix(line)) {
+			return FormatTree
+		}
+	}
+	return FormatSimple
+}
+
+// isJSON reports whether lines, code fences aside, hold a single JSON value
+func isJSON(lines []string) bool {
+	var b strings.Builder
+	for _, line := range lines {
+		if !isCodeFence(line) {
+			b.WriteString(line)
+			b.WriteByte('\n')
+		}
+	}
+	return json.Valid([]byte(b.String()))
+}
+
+// isYAMLMapping reports whether lines read as a YAML mapping: every top-level
+// line is a "key:" entry, a document marker or a comment, and everything else
+// is indented under a key or a sequence item of one. A spec that mixes a
+// "src:" line with plain paths is not.
+func isYAMLMapping(lines []string) bool {
+	keys := 0
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "", trimmed == "---", trimmed == "...", strings.HasPrefix(trimmed, "#"), isCodeFence(trimmed):
+		case line[0] == ' ' || line[0] == '\t' || trimmed == "-" || strings.HasPrefix(trimmed, "- "):
+			if keys == 0 {
+				return false
+			}
+		case yamlKeyRe.MatchString(trimmed):
+			keys++
+		default:
+			return false
+		}
+	}
+	return keys > 0
+}
+
+// ParseFormat maps a command-line value ("tree", "simple", "list", "ls-r") to
+// the Format a parse should be forced to; "" and "auto" mean FormatUnknown,
+// which lets Parse detect it
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "auto":
+		return FormatUnknown, nil
+	case "tree":
+		return FormatTree, nil
+	case "simple":
+		return FormatSimple, nil
+	case "list":
+		return FormatList, nil
+	case "ls-r":
+		return FormatLsR, nil
+	default:
+		return FormatUnknown, fmt.Errorf("unknown input format %q (want auto, tree, simple, list or ls-r)", s)
+	}
+}
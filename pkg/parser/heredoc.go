@@ -0,0 +1,46 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// heredocRe matches the "<<TAG" marker that follows a path and opens a
+// literal content block
+var heredocRe = regexp.MustCompile(`\s<<([A-Za-z_][A-Za-z0-9_]*)(\s|$)`)
+
+// heredocBody collects the lines of an open heredoc
+type heredocBody struct {
+	tag   string
+	line  int // line number of the node that opened it
+	lines []string
+}
+
+// cutHeredoc removes a "<<TAG" marker from line and returns the heredoc it
+// opens, or nil when the line has no marker before its comment
+func cutHeredoc(line string, num int) (string, *heredocBody) {
+	body, _, _ := strings.Cut(line, "#")
+	loc := heredocRe.FindStringSubmatchIndex(body)
+	if loc == nil {
+		return line, nil
+	}
+	tag := line[loc[2]:loc[3]]
+	return line[:loc[0]] + line[loc[3]:], &heredocBody{tag: tag, line: num}
+}
+
+// add appends a line to the heredoc and reports whether it was the terminator
+func (h *heredocBody) add(line string) bool {
+	if strings.TrimSpace(line) == h.tag {
+		return true
+	}
+	h.lines = append(h.lines, line)
+	return false
+}
+
+// content returns the collected lines, each terminated by a newline
+func (h *heredocBody) content() []byte {
+	if len(h.lines) == 0 {
+		return []byte{}
+	}
+	return []byte(strings.Join(h.lines, "\n") + "\n")
+}
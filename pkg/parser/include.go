@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// includeRe matches an "@include FILE" line: the entry itself, after nothing
+// but tree glyphs, list markers and indentation, so "@include" in a comment
+// such as "foo.md # see @include x" is left alone
+var includeRe = regexp.MustCompile(`^((?:[\s│├└─|\x60+*-]|\d+[.)]\s|[a-zA-Z][.)]\s)*)@include\s+(\S+)\s*$`)
+
+// includeMarker starts the entry name an "@include FILE" line is rewritten
+// to, so the format parsers place it in the tree like any file
+const includeMarker = "@include="
+
+// markInclude rewrites "├── @include lib/base.tree" to
+// "├── @include=lib%2Fbase.tree", escaping the file so it stays one entry
+func markInclude(line string) string {
+	m := includeRe.FindStringSubmatchIndex(line)
+	if m == nil {
+		return line
+	}
+	return line[:m[3]] + includeMarker + url.PathEscape(line[m[4]:m[5]])
+}
+
+// resolveIncludes replaces every include entry with the nodes of the spec it
+// names, parsed with the same options and placed under the entry's directory.
+// Nodes the including spec already declares are not repeated. A failed
+// include is left out and the first error is returned with the other nodes.
+func resolveIncludes(nodes []Node, opts ParseOptions) ([]Node, error) {
+	declared := make(map[string]bool)
+	for _, n := range nodes {
+		declared[n.Path] = true
+	}
+
+	var out []Node
+	var first error
+	for _, n := range nodes {
+		dir, name := path.Split(strings.TrimSuffix(n.Path, "/"))
+		if !strings.HasPrefix(name, includeMarker) {
+			out = append(out, n)
+			continue
+		}
+		file, err := url.PathUnescape(strings.TrimPrefix(name, includeMarker))
+		if err != nil {
+			file = strings.TrimPrefix(name, includeMarker)
+		}
+		included, err := parseInclude(file, opts)
+		if err != nil {
+			if first == nil {
+				first = fmt.Errorf("line %d: @include %s: %w", n.Line, file, err)
+			}
+			continue
+		}
+		for _, c := range included {
+			c.Path = dir + c.Path
+			c.Line = n.Line // the included file's own lines mean nothing here
+			if !declared[c.Path] {
+				declared[c.Path] = true
+				out = append(out, c)
+			}
+		}
+	}
+	return out, first
+}
+
+// parseInclude reads and parses the spec at name, relative to
+// opts.IncludeDir, refusing files that are already being included
+func parseInclude(name string, opts ParseOptions) ([]Node, error) {
+	if opts.IncludeDir == "" {
+		return nil, fmt.Errorf("no directory to resolve it against; includes work in spec files only")
+	}
+	file := filepath.Join(opts.IncludeDir, filepath.FromSlash(name))
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(opts.including, abs) {
+		return nil, fmt.Errorf("include cycle: %s", strings.Join(append(opts.including, abs), " -> "))
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sub := opts
+	sub.Format = FormatUnknown // a fragment may be written in any format
+	sub.IncludeDir = filepath.Dir(file)
+	sub.including = append(slices.Clip(opts.including), abs)
+	return ParseWithOptions(f, sub)
+}
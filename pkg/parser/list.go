@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// listItemRe matches a Markdown-style list item: indentation, a marker ("-",
+// "*", "+", "1.", "2)", "a.") and the entry after it
+var listItemRe = regexp.MustCompile(`^(\s*)(?:[-*+]|\d+[.)]|[A-Za-z][.)])\s+(\S.*)$`)
+
+// isList reports whether every line is a list item, as in a design doc that
+// outlines a layout with "- cmd/", "  - main.go" or "1. README.md"
+func isList(lines []string) bool {
+	items := 0
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || isCodeFence(trimmed) {
+			continue
+		}
+		if !listItemRe.MatchString(line) {
+			return false
+		}
+		items++
+	}
+	return items > 0
+}
+
+// listIndent measures leading whitespace, counting a tab as four columns
+func listIndent(s string) int {
+	width := 0
+	for _, r := range s {
+		if r == '\t' {
+			width += 4
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+// parseList reads a nested list: an item indented deeper than the one before
+// it lives in that item, which becomes a directory. Markers are dropped and
+// an entry may be wrapped in backticks ("- `cmd/`"). Unlike a tree, the first
+// item is not taken for a root and stripped.
+func parseList(lines []sourceLine) ([]Node, error) {
+	// open holds the items the current line may be nested in, outermost first
+	type item struct {
+		indent int
+		node   int // index in nodes
+	}
+	var open []item
+	var nodes []Node
+	hasChildren := make(map[int]bool)
+
+	for _, line := range lines {
+		m := listItemRe.FindStringSubmatch(line.text)
+		if m == nil {
+			continue
+		}
+		indent := listIndent(m[1])
+
+		text, target := cutLink(unquoteListEntry(m[2]))
+		fm := simpleFileRe.FindStringSubmatch(text)
+		if fm == nil {
+			continue
+		}
+
+		for len(open) > 0 && open[len(open)-1].indent >= indent {
+			open = open[:len(open)-1]
+		}
+		path := strings.TrimSuffix(fm[1], "/")
+		if len(open) > 0 {
+			parent := open[len(open)-1].node
+			hasChildren[parent] = true
+			path = strings.TrimSuffix(nodes[parent].Path, "/") + "/" + path
+		}
+
+		nodes = append(nodes, Node{
+			Path:       path,
+			IsDir:      target == "" && strings.HasSuffix(fm[1], "/"),
+			Comment:    strings.TrimSpace(fm[2]),
+			Line:       line.num,
+			LinkTarget: target,
+		})
+		open = append(open, item{indent: indent, node: len(nodes) - 1})
+	}
+
+	for i := range nodes {
+		if nodes[i].LinkTarget == "" && (nodes[i].IsDir || hasChildren[i]) {
+			nodes[i].IsDir = true
+			nodes[i].Path += "/"
+		}
+	}
+	return nodes, nil
+}
+
+// unquoteListEntry removes the backticks around a path in a Markdown list,
+// keeping any comment after it: "`cmd/` # entry" becomes "cmd/ # entry"
+func unquoteListEntry(entry string) string {
+	if !strings.HasPrefix(entry, "`") {
+		return entry
+	}
+	if end := strings.Index(entry[1:], "`"); end >= 0 {
+		return entry[1:end+1] + entry[end+2:]
+	}
+	return entry
+}
@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// lsColumnRe separates the columns of `ls -R` output printed to a terminal,
+// which pads names with at least two spaces or a tab
+var lsColumnRe = regexp.MustCompile(`\t+|  +`)
+
+// lsHeader returns the directory of an `ls -R` section header such as
+// "./internal/api:", or false when line is not a header. Headers with spaces
+// are not recognized, so prose ending in a colon is not taken for one.
+func lsHeader(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if len(line) < 2 || !strings.HasSuffix(line, ":") || strings.ContainsAny(line, " \t") {
+		return "", false
+	}
+	return strings.TrimSuffix(line, ":"), true
+}
+
+// isLsR reports whether lines look like `ls -R` output: the first line is a
+// section header and at least one bare, unindented name follows a header.
+// YAML keys ending in ":" are followed by indented lines or other keys.
+func isLsR(lines []string) bool {
+	seenHeader := false
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if _, ok := lsHeader(line); ok {
+			seenHeader = true
+			continue
+		}
+		if !seenHeader {
+			return false
+		}
+		return line[0] != ' ' && line[0] != '\t' && !strings.HasPrefix(line, "- ")
+	}
+	return false
+}
+
+// parseLsR rebuilds paths from `ls -R` output. Each "dir:" header starts a
+// section listing that directory's entries; an entry is a directory when it
+// has a section of its own. The first header is the root and is stripped
+// from every path, like the root line of a tree. Multi-column lines such as
+// "cmd  go.mod  internal" list one entry per column, so names with runs of
+// spaces need `ls -R1` output.
+func parseLsR(lines []sourceLine) ([]Node, error) {
+	// Collect the directories that have sections
+	var root string
+	sections := make(map[string]bool)
+	for i, line := range lines {
+		dir, ok := lsHeader(line.text)
+		if !ok {
+			continue
+		}
+		dir = path.Clean(dir)
+		if i == 0 {
+			root = dir
+		}
+		sections[dir] = true
+	}
+
+	rel := func(p string) string {
+		if root == "." {
+			return p
+		}
+		if p == root {
+			return ""
+		}
+		if rest, ok := strings.CutPrefix(p, root+"/"); ok {
+			return rest
+		}
+		return p // a sibling section such as ab: next to a:
+	}
+
+	var nodes []Node
+	current := root
+	for _, line := range lines {
+		if dir, ok := lsHeader(line.text); ok {
+			current = path.Clean(dir)
+			continue
+		}
+		text := strings.TrimSpace(line.text)
+		if text == "" || strings.HasPrefix(text, "total ") {
+			continue // `ls -lR` block size line
+		}
+
+		for _, name := range lsColumnRe.Split(text, -1) {
+			full := path.Join(current, name)
+			isDir := sections[full]
+			p := rel(full)
+			if p == "" {
+				continue
+			}
+			if isDir {
+				p += "/"
+			}
+			nodes = append(nodes, Node{Path: p, IsDir: isDir, Line: line.num})
+		}
+	}
+
+	return nodes, nil
+}
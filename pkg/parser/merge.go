@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MergePolicy decides which node MergeNodes keeps when both lists declare
+// the same path
+type MergePolicy int
+
+const (
+	// MergePreferA keeps the node of the first list
+	MergePreferA MergePolicy = iota
+	// MergePreferB keeps the node of the second list, in the place the
+	// first list gave the path
+	MergePreferB
+	// MergeError fails on a file both lists declare. A directory both
+	// declare is no conflict and keeps the first list's node.
+	MergeError
+)
+
+// MergeNodes combines two node lists into one: the nodes of a in order,
+// followed by the nodes of b whose paths a does not declare. policy settles
+// paths declared in both. A path that is a directory in one list and a file
+// in the other, or a file that the merged list nests nodes under, is an
+// error whatever the policy. Neither list is modified.
+func MergeNodes(a, b []Node, policy MergePolicy) ([]Node, error) {
+	index := make(map[string]int, len(a)) // path -> position in out
+	out := make([]Node, 0, len(a)+len(b))
+	for _, n := range a {
+		index[strings.TrimSuffix(n.Path, "/")] = len(out)
+		out = append(out, n)
+	}
+
+	for _, n := range b {
+		key := strings.TrimSuffix(n.Path, "/")
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(out)
+			out = append(out, n)
+			continue
+		}
+		switch have := out[i]; {
+		case have.IsDir != n.IsDir:
+			return nil, fmt.Errorf("%q is a %s in the first list and a %s in the second", key, nodeKind(have), nodeKind(n))
+		case policy == MergePreferB:
+			out[i] = n
+		case policy == MergeError && !n.IsDir:
+			return nil, fmt.Errorf("%q is declared in both lists", key)
+		}
+	}
+
+	// A file cannot hold what the other list puts inside it
+	for _, n := range out {
+		for dir := parentPath(n.Path); dir != ""; dir = parentPath(dir) {
+			if i, ok := index[dir]; ok && !out[i].IsDir {
+				return nil, fmt.Errorf("%q is a file, but %q is inside it", dir, n.Path)
+			}
+		}
+	}
+	return out, nil
+}
+
+// nodeKind names what a node is for MergeNodes errors
+func nodeKind(n Node) string {
+	if n.IsDir {
+		return "directory"
+	}
+	return "file"
+}
+
+// parentPath returns the directory p is in, without a trailing slash, or ""
+// at the top level
+func parentPath(p string) string {
+	p = strings.TrimSuffix(p, "/")
+	i := strings.LastIndex(p, "/")
+	if i < 0 {
+		return ""
+	}
+	return p[:i]
+}
@@ -2,9 +2,12 @@ package parser
 
 import (
 	"bufio"
+	"fmt"
 	"io"
+	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 )
 
@@ -17,6 +20,70 @@ type Node struct {
 	Path    string // e.g. "cmd/tree2scaffold/main.go" or "pkg/parser/"
 	IsDir   bool
 	Comment string
+	Line    int // 1-based line in the input the node came from; 0 when synthesized
+	Depth   int // nesting level: 0 for root-level nodes, 1 for their children, ...
+
+	// LinkTarget makes the node a symlink to this target, written in the
+	// spec as "name -> target". Empty for regular files and directories.
+	LinkTarget string
+
+	// Attrs holds the "@name" and "@name=value" directives from the comment,
+	// e.g. "run.sh # entry point @executable". Nil when there are none.
+	Attrs map[string]string
+
+	// Content is the literal file body given in the spec, e.g. by a heredoc
+	// ("config.yaml <<END" ... "END"). Nil means the content is generated.
+	Content []byte
+}
+
+// ParseOptions tunes the heuristics Parse applies on top of the literal input
+type ParseOptions struct {
+	// AssumeDirIfNoExtension makes every leaf without an extension, such as
+	// "bin" or "LICENSE", a directory. By default only a trailing slash or
+	// nested children make a node a directory.
+	AssumeDirIfNoExtension bool
+
+	// TrailingSlashOptional makes a childless entry of a simple list a
+	// directory when its name has no extension and is no well-known file
+	// like LICENSE or Makefile, so "internal" means the same as "internal/".
+	// By default only a trailing slash or children make it a directory.
+	// Unlike AssumeDirIfNoExtension it leaves tree input alone.
+	TrailingSlashOptional bool
+
+	// KeepRoot keeps the root line a tree or simple list starts with as a
+	// directory holding every other node, instead of stripping it
+	KeepRoot bool
+
+	// NoRelocate skips fixNestedPaths, so no file is moved into a directory
+	// the input did not put it in, such as internal/ui.go into internal/ui/
+	NoRelocate bool
+
+	// Deprecated: names are no longer guessed to be directories, so this has
+	// no effect. See AssumeDirIfNoExtension.
+	NoMagicDirs bool
+
+	// Format forces the input to be read as one format instead of detecting
+	// it. FormatUnknown (the zero value) means detect.
+	Format Format
+
+	// IncludeDir is the directory "@include FILE" lines are resolved
+	// against, normally the one holding the spec. Empty rejects includes.
+	IncludeDir string
+
+	// OnRewrite, when set, is called for every node a post-processing pass
+	// changes, with the node before and after: a file fixNestedPaths moved
+	// into a directory, or a name postProcessDirectories made a directory
+	OnRewrite func(pass string, before, after Node)
+
+	// including lists the absolute paths of the specs being included, outermost
+	// first, to catch include cycles
+	including []string
+}
+
+// sourceLine is a non-blank input line together with its 1-based line number
+type sourceLine struct {
+	text string
+	num  int
 }
 
 // Parse reads an ASCII-tree from r and returns Nodes with full relative paths.
@@ -27,62 +94,196 @@ type Node struct {
 // - partial tree output (starting with a file like ├── orchestrator.go)
 // - classic tree command output (with ├── and └── characters)
 func Parse(r io.Reader) ([]Node, error) {
-	// Read all lines into memory
-	scanner := bufio.NewScanner(r)
-	var lines []string
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.TrimSpace(line) != "" {
-			lines = append(lines, line)
-		}
-	}
-	if err := scanner.Err(); err != nil {
-		return nil, err
-	}
+	return ParseWithOptions(r, ParseOptions{})
+}
+
+// ParseWithOptions is Parse with explicit control over its heuristics.
+//
+// A malformed line does not discard the rest of the input: the nodes parsed
+// from everything readable are returned along with the first error, and the
+// caller decides whether to use them.
+func ParseWithOptions(r io.Reader, opts ParseOptions) ([]Node, error) {
+	lines, contents, lineErr := readLines(r)
 
 	// If no lines, return empty
 	if len(lines) == 0 {
-		return nil, nil
+		return nil, lineErr
 	}
 
-	// Check if we should use simple file list format
-	isSimpleFormat := true
-	for _, line := range lines {
-		if containsTreeChar(line) {
-			isSimpleFormat = false
-			break
-		}
+	// Pick the parser for the input's format
+	texts := make([]string, len(lines))
+	for i, line := range lines {
+		texts[i] = line.text
 	}
 
-	// Parse based on the format
 	var nodes []Node
 	var err error
 
-	if isSimpleFormat {
-		nodes, err = parseSimpleFormat(lines)
-	} else {
-		nodes, err = parseTreeFormat(lines)
+	format := opts.Format
+	if format == FormatUnknown {
+		format = detectFormat(texts)
+	}
+
+	switch format {
+	case FormatTree:
+		nodes, err = parseTreeFormat(lines, opts)
+	case FormatSimple:
+		root := simpleRoot(lines, contents, opts)
+		if root != "" && opts.OnRewrite != nil {
+			num := lines[0].num
+			opts.OnRewrite("simpleRoot", Node{Path: root, Line: num}, Node{Path: root + "/", IsDir: true, Line: num})
+		}
+		nodes, err = parseSimpleFormat(lines, root, opts)
+		if opts.TrailingSlashOptional {
+			nodes = slashlessDirs(nodes, contents)
+		}
+	case FormatLsR:
+		nodes, err = parseLsR(lines)
+	case FormatList:
+		nodes, err = parseList(lines)
+	default:
+		return nil, fmt.Errorf("%s input is not supported: expected a tree or one path per line", strings.ToUpper(format.String()))
 	}
 
 	if err != nil {
 		return nil, err
 	}
+	err = lineErr
+
+	// Lift "@name[=value]" directives out of the comments
+	applyDirectives(nodes)
+
+	// Attach heredoc bodies to the nodes declared on their opening lines
+	for i := range nodes {
+		if content, ok := contents[nodes[i].Line]; ok && !nodes[i].IsDir {
+			nodes[i].Content = content
+		}
+	}
+	if berr := decodeBase64Content(nodes); err == nil {
+		err = berr
+	}
 
 	// Post-processing for both formats: handle directory detection
-	nodes = postProcessDirectories(nodes)
+	nodes = tracePass("postProcessDirectories", nodes, opts, func(nodes []Node) []Node {
+		return postProcessDirectories(nodes, opts)
+	})
 
 	// Fix path issues with nested files, like the ui files in this tree structure
-	nodes = fixNestedPaths(nodes)
+	if !opts.NoRelocate {
+		nodes = tracePass("fixNestedPaths", nodes, opts, fixNestedPaths)
+	}
 
-	return nodes, nil
+	// Declare any intermediate directories only implied by slashes in a path
+	nodes = addMissingParents(nodes)
+
+	// Splice in the specs named by "@include FILE" lines
+	nodes, ierr := resolveIncludes(nodes, opts)
+	if err == nil {
+		err = ierr
+	}
+
+	for i := range nodes {
+		nodes[i].Depth = PathDepth(nodes[i].Path)
+	}
+
+	return nodes, err
+}
+
+// readLines reads the input's non-blank lines, normalized for the format
+// parsers: decoded to UTF-8, without metadata columns or a Markdown fence,
+// and with heredoc bodies taken out into contents by opening line number.
+// On a read error or an unterminated heredoc it returns the lines before the
+// problem along with the error.
+func readLines(r io.Reader) ([]sourceLine, map[int][]byte, error) {
+	// Normalize UTF-16 and BOM-prefixed input to plain UTF-8
+	r, err := decodeInput(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Read all lines into memory
+	scanner := bufio.NewScanner(r)
+	var raw []string
+	for scanner.Scan() {
+		raw = append(raw, strings.TrimSuffix(scanner.Text(), "\r")) // CRLF input from Windows
+	}
+
+	// Drop the line numbers of a `cat -n` listing or an editor's gutter
+	stripLineNumbers(raw)
+
+	// Read Windows `tree` output as its Unix equivalent
+	convertWindowsTree(raw)
+
+	var lines []sourceLine
+	var heredoc *heredocBody
+	contents := make(map[int][]byte) // line number -> heredoc content
+	num := 0
+	for _, line := range raw {
+		num++
+
+		// Inside a heredoc every line up to the terminator is literal content
+		if heredoc != nil {
+			if heredoc.add(line) {
+				contents[heredoc.line] = heredoc.content()
+				heredoc = nil
+			}
+			continue
+		}
+
+		if strings.TrimSpace(line) != "" && !isTreeReport(line) {
+			line = markInclude(stripMetaColumns(normalizeSpaces(line)))
+			line, heredoc = cutHeredoc(line, num)
+			lines = append(lines, sourceLine{text: line, num: num})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		err = fmt.Errorf("line %d: %w", num+1, err)
+		return stripCodeFence(lines), contents, err
+	}
+	if heredoc != nil {
+		err = fmt.Errorf("line %d: heredoc <<%s is never terminated", heredoc.line, heredoc.tag)
+	}
+
+	// Drop the fence around a tree copied from a Markdown code block
+	return stripCodeFence(lines), contents, err
+}
+
+// PathDepth returns the nesting level of a node path: 0 for "main.go" or
+// "cmd/", 1 for "cmd/main.go", and so on
+func PathDepth(path string) int {
+	return strings.Count(strings.Trim(path, "/"), "/")
+}
+
+// isCodeFence reports whether line opens or closes a Markdown code block,
+// e.g. "```", "```text" or "~~~"
+func isCodeFence(line string) bool {
+	line = strings.TrimSpace(line)
+	return strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~")
+}
+
+// stripCodeFence removes a code fence on the first and on the last line
+func stripCodeFence(lines []sourceLine) []sourceLine {
+	if len(lines) > 0 && isCodeFence(lines[0].text) {
+		lines = lines[1:]
+	}
+	if len(lines) > 0 && isCodeFence(lines[len(lines)-1].text) {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
 }
 
 // parseSimpleFormat handles simple file list format (no tree characters)
-func parseSimpleFormat(lines []string) ([]Node, error) {
+func parseSimpleFormat(lines []sourceLine, root string, opts ParseOptions) ([]Node, error) {
 	var nodes []Node
+	rootLine := 0
+	if root != "" {
+		rootLine = lines[0].num
+		lines = lines[1:]
+	}
 
 	for _, line := range lines {
-		m := simpleFileRe.FindStringSubmatch(line)
+		text, target := cutLink(line.text)
+		m := simpleFileRe.FindStringSubmatch(text)
 		if m == nil {
 			continue // Skip lines that don't match
 		}
@@ -93,75 +294,154 @@ func parseSimpleFormat(lines []string) ([]Node, error) {
 			comment = strings.TrimSpace(m[2])
 		}
 
-		isDir := strings.HasSuffix(path, "/")
-		cleanPath := strings.TrimSuffix(path, "/")
+		if target != "" {
+			path = strings.TrimSuffix(path, "/")
+		}
+		if root != "" {
+			path = strings.TrimPrefix(path, root+"/") // "app/main.go" under "app"
+		}
 
 		nodes = append(nodes, Node{
-			Path:    cleanPath,
-			IsDir:   isDir,
-			Comment: comment,
+			Path:       path,
+			IsDir:      target == "" && strings.HasSuffix(path, "/"),
+			Comment:    comment,
+			Line:       line.num,
+			LinkTarget: target,
 		})
 	}
 
+	if root != "" && opts.KeepRoot {
+		nodes = keepRoot(root, "", rootLine, nodes)
+	}
 	return nodes, nil
 }
 
+// extensionlessFiles are well-known file names without an extension, which
+// simpleRoot never takes for a root directory. Keys are lowercase.
+var extensionlessFiles = map[string]bool{
+	"license": true, "licence": true, "copying": true, "notice": true,
+	"authors": true, "contributors": true, "codeowners": true,
+	"changelog": true, "readme": true, "version": true,
+	"makefile": true, "gnumakefile": true, "justfile": true, "taskfile": true,
+	"dockerfile": true, "containerfile": true, "procfile": true,
+	"gemfile": true, "rakefile": true, "pipfile": true, "brewfile": true,
+	"jenkinsfile": true, "vagrantfile": true,
+}
+
+// simpleRoot returns the name heading a simple list when it is clearly the
+// root of the listing rather than a file, as in "orchestrator" followed by
+// "file.go": a bare name without extension, comment, link or content that is
+// no well-known file like LICENSE, followed by entries that can all be its
+// children. Entries nested under other directories, as "cmd/main.go" after
+// "internal", make it a sibling. Under TrailingSlashOptional the name is a
+// directory of its own. It returns "" otherwise.
+func simpleRoot(lines []sourceLine, contents map[int][]byte, opts ParseOptions) string {
+	if len(lines) < 2 || opts.TrailingSlashOptional {
+		return ""
+	}
+	if _, ok := contents[lines[0].num]; ok {
+		return ""
+	}
+	text, target := cutLink(lines[0].text)
+	m := simpleFileRe.FindStringSubmatch(text)
+	if m == nil || target != "" || m[2] != "" {
+		return ""
+	}
+	name := m[1]
+	if strings.ContainsAny(name, "/\\") || path.Ext(name) != "" || extensionlessFiles[strings.ToLower(name)] {
+		return ""
+	}
+
+	for _, line := range lines[1:] {
+		text, _ := cutLink(line.text)
+		m := simpleFileRe.FindStringSubmatch(text)
+		if m == nil {
+			continue
+		}
+		p := strings.TrimSuffix(m[1], "/")
+		if p == name || strings.HasPrefix(p, "/") || p == ".." || strings.HasPrefix(p, "../") {
+			return ""
+		}
+		if strings.Contains(p, "/") && !strings.HasPrefix(p, name+"/") {
+			return ""
+		}
+	}
+	return name
+}
+
+// slashlessDirs makes the extensionless entries of a simple list directories
+// for ParseOptions.TrailingSlashOptional, except well-known files, links and
+// entries with literal content
+func slashlessDirs(nodes []Node, contents map[int][]byte) []Node {
+	for i, n := range nodes {
+		if _, ok := contents[n.Line]; ok || n.IsDir || n.LinkTarget != "" {
+			continue
+		}
+		name := path.Base(n.Path)
+		if path.Ext(name) != "" || extensionlessFiles[strings.ToLower(name)] {
+			continue
+		}
+		nodes[i].IsDir = true
+		nodes[i].Path += "/"
+	}
+	return nodes
+}
+
+// keepRoot declares root as a directory, with the comment and input line
+// given, and moves every node under it, for ParseOptions.KeepRoot
+func keepRoot(root, comment string, line int, nodes []Node) []Node {
+	out := []Node{{Path: root + "/", IsDir: true, Comment: comment, Line: line}}
+	for _, n := range nodes {
+		n.Path = root + "/" + n.Path
+		out = append(out, n)
+	}
+	return out
+}
+
 // parseTreeFormat handles tree command style output
-func parseTreeFormat(lines []string) ([]Node, error) {
+func parseTreeFormat(lines []sourceLine, opts ParseOptions) ([]Node, error) {
 	var nodes []Node
 	var parents []string
 	var rootName string
 
 	// Check if it's a partial tree format starting with a file
 	isPartialTreeFormat := false
-	if len(lines) > 0 && strings.HasPrefix(lines[0], "├──") {
+	if len(lines) > 0 && strings.HasPrefix(lines[0].text, "├──") {
 		isPartialTreeFormat = true
 	}
 
 	// First line is assumed to be the root directory (unless it's a partial tree)
+	rootNum, rootComment := 0, ""
 	if len(lines) > 0 && !isPartialTreeFormat {
-		rootLine := lines[0]
-		rootMatch := simpleFileRe.FindStringSubmatch(rootLine) // Use simpleFileRe for root
-
-		if rootMatch != nil {
-			rootPath := rootMatch[1]
-			if strings.HasSuffix(rootPath, "/") {
-				rootName = rootPath
-			} else {
-				rootName = rootPath + "/"
-			}
+		rootNum = lines[0].num
+		var name string
+		if name, rootComment = rootLine(lines[0].text); name != "" {
+			rootName = name + "/"
 		}
 
 		// Skip the root line in further processing
 		lines = lines[1:]
 	}
 
-	// Process remaining lines
-	for _, line := range lines {
-		// Calculate indentation level
-		indentLevel := 0
-		indentStr := ""
-
-		for _, ch := range line {
-			if ch == '│' || ch == ' ' || ch == '├' || ch == '└' || ch == '─' {
-				indentStr += string(ch)
-				continue
-			}
-			break
-		}
-
-		// Count the level based on tree characters
-		pipes := strings.Count(indentStr, "│")
-		branches := 0
-		if strings.Contains(indentStr, "├") || strings.Contains(indentStr, "└") {
-			branches = 1
-		}
+	// Measure every line's depth up front so each node can look ahead at the
+	// next one to decide whether it has children
+	unit := indentUnit(lines)
+	prefixes := make([]string, len(lines))
+	levels := make([]int, len(lines))
+	for i, src := range lines {
+		prefixes[i] = treePrefix(src.text)
+		levels[i] = treeLevel(prefixes[i], unit)
+	}
 
-		indentLevel = pipes + branches
+	// Process remaining lines
+	for i, src := range lines {
+		line, target := cutLink(src.text)
+		indentStr := prefixes[i]
+		indentLevel := levels[i]
 
 		// Extract the path name
 		parts := strings.SplitN(strings.TrimPrefix(line, indentStr), " ", 2)
-		if len(parts) == 0 {
+		if len(parts) == 0 || parts[0] == "" {
 			continue
 		}
 
@@ -177,29 +457,15 @@ func parseTreeFormat(lines []string) ([]Node, error) {
 		// 3. Directory naming conventions (common directory names without extensions)
 		isDir := strings.HasSuffix(path, "/")
 
-		// For tree structures, check if this node has children
-		if !isDir && indentLevel < len(lines)-1 {
-			nextLine := lines[indentLevel+1]
-			// If next line has more indent, this is a directory
-			nextIndent := strings.Count(nextLine, "│") + strings.Count(nextLine, "├") + strings.Count(nextLine, "└")
-			if nextIndent > indentLevel {
-				isDir = true
-			}
+		// A symlink is never a directory node, whatever it points at
+		if target != "" {
+			path = strings.TrimSuffix(path, "/")
+			isDir = false
 		}
 
-		// Common directory names
-		dirNames := map[string]bool{
-			".github": true, "cmd": true, "internal": true, "pkg": true,
-			"api": true, "test": true, "testdata": true, "config": true,
-			"workflows": true, "server": true, "problems": true,
-		}
-
-		// If the path is a known directory name without an extension, mark it as a directory
-		if !isDir && !strings.Contains(path, ".") {
-			baseName := filepath.Base(path)
-			if _, ok := dirNames[baseName]; ok {
-				isDir = true
-			}
+		// For tree structures, a node followed by a deeper line has children
+		if !isDir && i+1 < len(lines) && levels[i+1] > indentLevel {
+			isDir = true
 		}
 
 		cleanPath := strings.TrimSuffix(path, "/")
@@ -234,21 +500,149 @@ func parseTreeFormat(lines []string) ([]Node, error) {
 		// If path is not empty, add it to nodes
 		if fullPath != "" {
 			nodes = append(nodes, Node{
-				Path:    fullPath,
-				IsDir:   isDir,
-				Comment: comment,
+				Path:       fullPath,
+				IsDir:      isDir,
+				Comment:    comment,
+				Line:       src.num,
+				LinkTarget: target,
 			})
 		}
 	}
 
+	if root := strings.TrimSuffix(rootName, "/"); opts.KeepRoot && root != "" && root != "." {
+		nodes = keepRoot(root, rootComment, rootNum, nodes)
+	}
 	return nodes, nil
 }
 
+// absRootRe matches a root line that is an absolute path, as printed by
+// `tree /Users/me/projects/app`: Unix, home-relative or Windows
+var absRootRe = regexp.MustCompile(`^(/|~(/|$)|[A-Za-z]:[\\/])`)
+
+// rootCommentRe matches the comment of a root line, which may follow "#" or,
+// as in "app/ // my application", "//"
+var rootCommentRe = regexp.MustCompile(`(\s*#|\s+//)\s*(.*)$`)
+
+// rootLine returns the name of the directory a tree's root line declares and
+// the line's comment: "app" for "app/", and the last element of an absolute
+// path such as "/Users/me/projects/app", whose parents are not part of the
+// project
+func rootLine(text string) (name, comment string) {
+	text = strings.TrimSpace(text)
+	if m := rootCommentRe.FindStringSubmatchIndex(text); m != nil {
+		comment = strings.TrimSpace(text[m[4]:m[5]])
+		text = text[:m[0]] // a path may hold spaces, but not a comment
+	}
+	if absRootRe.MatchString(text) {
+		text = strings.TrimRight(text, "/\\")
+		return text[strings.LastIndexAny(text, "/\\~:")+1:], comment
+	}
+	if m := simpleFileRe.FindStringSubmatch(text); m != nil {
+		return strings.TrimSuffix(m[1], "/"), comment
+	}
+	return "", ""
+}
+
+// treePrefix returns the leading run of indentation and connector glyphs of a
+// tree line, i.e. everything before the path token. Scanning stops at the
+// first other rune, so glyphs in the path or comment are never indentation.
+func treePrefix(line string) string {
+	for i, ch := range line {
+		if ch != '│' && ch != ' ' && ch != '├' && ch != '└' && ch != '─' {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// indentUnit infers how many columns one tree level occupies from the
+// smallest non-zero column at which a branch glyph appears. `tree` uses four
+// ("│   ├── "), but hand-written trees often use two.
+func indentUnit(lines []sourceLine) int {
+	unit := 0
+	for _, l := range lines {
+		if col := branchColumn(treePrefix(l.text)); col > 0 && (unit == 0 || col < unit) {
+			unit = col
+		}
+	}
+	if unit == 0 {
+		return 4
+	}
+	return unit
+}
+
+// branchColumn returns the rune column of the first ├ or └ in prefix, or -1
+func branchColumn(prefix string) int {
+	col := 0
+	for _, ch := range prefix {
+		if ch == '├' || ch == '└' {
+			return col
+		}
+		col++
+	}
+	return -1
+}
+
+// treeLevel converts a line prefix into a tree depth. A branch glyph at column
+// 0 is level 1 and every unit of indentation before it adds one. This depends
+// only on the glyph's column, so the continuation of a last child ("    └──")
+// nests exactly like a pipe continuation ("│   └──"). Lines without a branch
+// glyph are level 0 at column 0 and otherwise sit one level below the glyph
+// column they align with.
+func treeLevel(prefix string, unit int) int {
+	col := branchColumn(prefix)
+	if col < 0 {
+		if col = len([]rune(prefix)); col == 0 {
+			return 0
+		}
+	}
+	return (col+unit/2)/unit + 1
+}
+
+// cutLink splits a "name -> target # comment" line into the line without the
+// arrow and target, and the target. Lines without an arrow before any comment
+// are returned unchanged with an empty target.
+func cutLink(line string) (string, string) {
+	body, comment, hasComment := strings.Cut(line, "#")
+	before, after, ok := strings.Cut(body, " -> ")
+	if !ok {
+		return line, ""
+	}
+	target := strings.TrimSpace(after)
+	if target == "" {
+		return line, ""
+	}
+	if hasComment {
+		return before + " #" + comment, target
+	}
+	return before, target
+}
+
 // containsTreeChar checks if a line contains ASCII tree characters
 func containsTreeChar(line string) bool {
 	return strings.ContainsAny(line, "│├└─")
 }
 
+// tracePass runs pass over nodes and reports every node whose path or type it
+// changed to opts.OnRewrite. The passes rewrite nodes in place, keeping their
+// order, so nodes are matched by index.
+func tracePass(name string, nodes []Node, opts ParseOptions, pass func([]Node) []Node) []Node {
+	if opts.OnRewrite == nil {
+		return pass(nodes)
+	}
+	before := slices.Clone(nodes)
+	after := pass(nodes)
+	if len(after) != len(before) {
+		return after
+	}
+	for i := range after {
+		if after[i].Path != before[i].Path || after[i].IsDir != before[i].IsDir {
+			opts.OnRewrite(name, before[i], after[i])
+		}
+	}
+	return after
+}
+
 // fixNestedPaths fixes issues with files that should be under a directory
 func fixNestedPaths(nodes []Node) []Node {
 	// Look for files that need to be fixed
@@ -371,53 +765,62 @@ func fixNestedPaths(nodes []Node) []Node {
 	return nodes
 }
 
-// postProcessDirectories performs additional processing to properly identify directories
-func postProcessDirectories(nodes []Node) []Node {
-	// Common directory names
-	dirNames := map[string]bool{
-		".github": true, "cmd": true, "internal": true, "pkg": true,
-		"api": true, "test": true, "testdata": true, "config": true,
-		"workflows": true, "server": true, "problems": true, "license": true,
-		"session": true, "stats": true, "ui": true,
+// addMissingParents inserts a directory node for every ancestor that a path
+// implies but the input never declared, e.g. "internal/" and "internal/config/"
+// for a simple-format line "internal/config/config.go". Each synthesized node
+// is placed right before the first node that needs it, so parents always
+// precede their children.
+func addMissingParents(nodes []Node) []Node {
+	declared := make(map[string]bool)
+	for _, n := range nodes {
+		if n.IsDir {
+			declared[strings.TrimSuffix(n.Path, "/")] = true
+		}
 	}
 
-	// First, mark common directory names
-	for i, n := range nodes {
-		path := n.Path
-		baseName := filepath.Base(path)
-
-		// If this is a common directory name without an extension and not already marked as a directory
-		if !n.IsDir && !strings.Contains(baseName, ".") {
-			if _, ok := dirNames[baseName]; ok {
-				nodes[i].IsDir = true
-				if !strings.HasSuffix(nodes[i].Path, "/") {
-					nodes[i].Path += "/"
-				}
+	var out []Node
+	for _, n := range nodes {
+		var missing []string
+		for dir := filepath.Dir(strings.TrimSuffix(n.Path, "/")); dir != "." && dir != "/"; dir = filepath.Dir(dir) {
+			if !declared[dir] {
+				missing = append(missing, dir)
 			}
 		}
+		// Emit outermost ancestors first
+		for i := len(missing) - 1; i >= 0; i-- {
+			declared[missing[i]] = true
+			out = append(out, Node{Path: missing[i] + "/", IsDir: true})
+		}
+		out = append(out, n)
 	}
 
-	// Then, infer directories from path structure
+	return out
+}
+
+// postProcessDirectories performs additional processing to properly identify directories
+func postProcessDirectories(nodes []Node, opts ParseOptions) []Node {
+	// First, make extension-less names directories when asked to; a file
+	// with literal content is a file whatever its name
 	for i, n := range nodes {
-		// For each node, check if any other node has it as a parent path
-		if !n.IsDir {
-			nodePath := n.Path
-			for _, other := range nodes {
-				// Skip self-comparison
-				if other.Path == nodePath {
-					continue
-				}
+		if opts.AssumeDirIfNoExtension && !n.IsDir && n.LinkTarget == "" && n.Content == nil && path.Ext(n.Path) == "" {
+			nodes[i].IsDir = true
+			nodes[i].Path += "/"
+		}
+	}
 
-				// If this node is a parent path of another node, it should be a directory
-				parentDir := filepath.Dir(other.Path)
-				if parentDir != "." && parentDir == nodePath {
-					nodes[i].IsDir = true
-					if !strings.HasSuffix(nodes[i].Path, "/") {
-						nodes[i].Path += "/"
-					}
-					break
-				}
-			}
+	// Then, infer directories from path structure: anything another node is
+	// nested under is a directory, whatever its name looks like (a parent
+	// called "assets.bundle" or "config.d" has a dot but is no file)
+	parents := make(map[string]bool)
+	for _, n := range nodes {
+		for dir := path.Dir(strings.TrimSuffix(n.Path, "/")); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			parents[dir] = true
+		}
+	}
+	for i, n := range nodes {
+		if !n.IsDir && n.LinkTarget == "" && parents[n.Path] {
+			nodes[i].IsDir = true
+			nodes[i].Path += "/"
 		}
 	}
 
@@ -1,8 +1,15 @@
 package parser
 
 import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"unicode/utf16"
 )
 
 func TestParse(t *testing.T) {
@@ -133,15 +140,15 @@ func TestPostProcessDirectories(t *testing.T) {
 		want  []Node
 	}{
 		{
-			name: "Common directory names are marked",
+			name: "Common directory names are not guessed",
 			input: []Node{
 				{Path: "cmd", IsDir: false, Comment: ""},
 				{Path: "internal", IsDir: false, Comment: ""},
 				{Path: "file.go", IsDir: false, Comment: ""},
 			},
 			want: []Node{
-				{Path: "cmd/", IsDir: true, Comment: ""},
-				{Path: "internal/", IsDir: true, Comment: ""},
+				{Path: "cmd", IsDir: false, Comment: ""},
+				{Path: "internal", IsDir: false, Comment: ""},
 				{Path: "file.go", IsDir: false, Comment: ""},
 			},
 		},
@@ -162,7 +169,7 @@ func TestPostProcessDirectories(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := postProcessDirectories(tt.input)
+			got := postProcessDirectories(tt.input, ParseOptions{})
 
 			// Check that directories are correctly marked
 			for i, node := range got {
@@ -290,4 +297,1383 @@ func TestFixNestedPaths(t *testing.T) {
 	}
 }
 
+func TestParseAssumeDirIfNoExtension(t *testing.T) {
+	input := `project/
+├── bin # build output
+├── Makefile.d/
+│   └── rules.mk
+└── main.go`
+
+	tests := []struct {
+		assume bool
+		want   []Node
+	}{
+		{false, []Node{
+			{Path: "bin", Comment: "build output", Line: 2},
+			{Path: "Makefile.d/", IsDir: true, Line: 3},
+			{Path: "Makefile.d/rules.mk", Line: 4, Depth: 1},
+			{Path: "main.go", Line: 5},
+		}},
+		{true, []Node{
+			{Path: "bin/", IsDir: true, Comment: "build output", Line: 2},
+			{Path: "Makefile.d/", IsDir: true, Line: 3},
+			{Path: "Makefile.d/rules.mk", Line: 4, Depth: 1},
+			{Path: "main.go", Line: 5},
+		}},
+	}
+
+	for _, tt := range tests {
+		nodes, err := ParseWithOptions(strings.NewReader(input), ParseOptions{AssumeDirIfNoExtension: tt.assume})
+		if err != nil {
+			t.Fatalf("ParseWithOptions() error = %v", err)
+		}
+		if !reflect.DeepEqual(nodes, tt.want) {
+			t.Errorf("AssumeDirIfNoExtension=%v: got %+v, want %+v", tt.assume, nodes, tt.want)
+		}
+	}
+}
+
+func TestParseSimpleRoot(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		keepRoot bool
+		want     []string
+	}{
+		{"bare name is the root", "orchestrator\nfile.go\nutil/\n", false, []string{"file.go", "util/"}},
+		{"root repeated in paths", "orchestrator\norchestrator/file.go\n", false, []string{"file.go"}},
+		{"kept root", "orchestrator\nfile.go\n", true, []string{"orchestrator/", "orchestrator/file.go"}},
+		{"commented name is a file", "orchestrator # the binary\nfile.go\n", false, []string{"orchestrator", "file.go"}},
+		{"name with an extension", "main.go\nfile.go\n", false, []string{"main.go", "file.go"}},
+		{"well-known file", "LICENSE\nmain.go\n", false, []string{"LICENSE", "main.go"}},
+		{"alone", "orchestrator\n", false, []string{"orchestrator"}},
+		{"sibling of nested paths", "internal\ncmd/main.go\ngo.mod\n", false, []string{"internal", "cmd/", "cmd/main.go", "go.mod"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := ParseWithOptions(strings.NewReader(tt.input), ParseOptions{KeepRoot: tt.keepRoot})
+			if err != nil {
+				t.Fatalf("ParseWithOptions() error = %v", err)
+			}
+			var got []string
+			for _, n := range nodes {
+				got = append(got, n.Path)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("paths = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// With optional trailing slashes the bare name is a directory, not the root
+	nodes, err := ParseWithOptions(strings.NewReader("internal\nmain.go\n"), ParseOptions{TrailingSlashOptional: true})
+	if err != nil {
+		t.Fatalf("ParseWithOptions() error = %v", err)
+	}
+	if len(nodes) != 2 || nodes[0].Path != "internal/" || !nodes[0].IsDir {
+		t.Errorf("TrailingSlashOptional: nodes = %+v, want internal/ kept as a directory", nodes)
+	}
+}
+
+func TestParseAbsoluteRoot(t *testing.T) {
+	body := "├── cmd\n│   └── main.go\n└── go.mod\n\n2 directories, 2 files\n"
+	for _, root := range []string{
+		"/Users/me/projects/app",
+		"/Users/me/My Projects/app/",
+		"~/projects/app",
+		`C:\Users\me\projects\app`,
+	} {
+		nodes, err := ParseWithOptions(strings.NewReader(root+"\n"+body), ParseOptions{KeepRoot: true})
+		if err != nil {
+			t.Fatalf("%s: ParseWithOptions() error = %v", root, err)
+		}
+		var got []string
+		for _, n := range nodes {
+			got = append(got, n.Path)
+		}
+		want := []string{"app/", "app/cmd/", "app/cmd/main.go", "app/go.mod"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: paths = %v, want %v", root, got, want)
+		}
+
+		docs, err := ParseDocuments(strings.NewReader(root+"\n"+body), ParseOptions{})
+		if err != nil || len(docs) != 1 || docs[0].Root != "app" {
+			t.Errorf("%s: ParseDocuments() = %+v, %v; want the root app", root, docs, err)
+		}
+	}
+}
+
+func TestParseKeepRoot(t *testing.T) {
+	input := "app/\n├── cmd/\n│   └── main.go\n└── go.mod\n"
+	nodes, err := ParseWithOptions(strings.NewReader(input), ParseOptions{KeepRoot: true})
+	if err != nil {
+		t.Fatalf("ParseWithOptions() error = %v", err)
+	}
+	want := []Node{
+		{Path: "app/", IsDir: true, Line: 1},
+		{Path: "app/cmd/", IsDir: true, Line: 2, Depth: 1},
+		{Path: "app/cmd/main.go", Line: 3, Depth: 2},
+		{Path: "app/go.mod", Line: 4, Depth: 1},
+	}
+	if !reflect.DeepEqual(nodes, want) {
+		t.Errorf("got %+v, want %+v", nodes, want)
+	}
+}
+
+func TestParseRootComment(t *testing.T) {
+	for _, root := range []string{"myapp/ # my application", "myapp/ // my application", "myapp //  my application "} {
+		input := root + "\n├── cmd/\n│   └── main.go\n└── go.mod\n"
+		doc, err := ParseDocument(strings.NewReader(input), ParseOptions{})
+		if err != nil {
+			t.Fatalf("ParseDocument(%q) error = %v", root, err)
+		}
+		if doc.Root != "myapp" || doc.RootLine != 1 || doc.Comment != "my application" {
+			t.Errorf("ParseDocument(%q) root = %q (line %d) %q, want myapp (line 1) %q", root, doc.Root, doc.RootLine, doc.Comment, "my application")
+		}
+		if len(doc.Nodes) != 3 || doc.Nodes[0].Path != "cmd/" {
+			t.Errorf("ParseDocument(%q) nodes = %+v, want the root stripped", root, doc.Nodes)
+		}
+
+		// A kept root carries the comment
+		nodes, err := ParseWithOptions(strings.NewReader(input), ParseOptions{KeepRoot: true})
+		if err != nil {
+			t.Fatalf("ParseWithOptions(%q) error = %v", root, err)
+		}
+		if want := (Node{Path: "myapp/", IsDir: true, Comment: "my application", Line: 1}); !reflect.DeepEqual(nodes[0], want) {
+			t.Errorf("ParseWithOptions(%q) root = %+v, want %+v", root, nodes[0], want)
+		}
+	}
+}
+
+func TestParseNoRelocate(t *testing.T) {
+	input := `project/
+├── internal/
+│   ├── ui/
+│   │   └── view.go
+│   └── ui.go
+└── main.go`
+
+	tests := []struct {
+		noRelocate bool
+		want       string
+	}{
+		{false, "internal/ui/ui.go"},
+		{true, "internal/ui.go"},
+	}
+
+	for _, tt := range tests {
+		nodes, err := ParseWithOptions(strings.NewReader(input), ParseOptions{NoRelocate: tt.noRelocate})
+		if err != nil {
+			t.Fatalf("ParseWithOptions() error = %v", err)
+		}
+		want := Node{Path: tt.want, Line: 5, Depth: PathDepth(tt.want)}
+		if len(nodes) != 5 || !reflect.DeepEqual(nodes[3], want) {
+			t.Errorf("NoRelocate=%v: got %+v, want %+v at index 3", tt.noRelocate, nodes, want)
+		}
+	}
+}
+
+func TestParseNestingOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name: "file before sibling directory",
+			input: `app/
+├── main.go
+├── lib/
+│   └── util.go
+└── README.md`,
+			want: []string{"main.go", "lib/", "lib/util.go", "README.md"},
+		},
+		{
+			name: "last child directory with space continuation",
+			input: `app/
+├── README.md
+└── docs/
+    ├── guide/
+    │   └── intro.md
+    └── index.md`,
+			want: []string{"README.md", "docs/", "docs/guide/", "docs/guide/intro.md", "docs/index.md"},
+		},
+		{
+			name: "deep chain then back to shallow",
+			input: `app/
+├── a/
+│   ├── b/
+│   │   └── c/
+│   │       └── deep.txt
+│   └── shallow.txt
+└── top.txt`,
+			want: []string{"a/", "a/b/", "a/b/c/", "a/b/c/deep.txt", "a/shallow.txt", "top.txt"},
+		},
+		{
+			name: "files after directories at every level",
+			input: `app/
+├── web/
+│   ├── assets/
+│   │   └── logo.svg
+│   └── index.html
+├── scripts/
+│   └── run.sh
+└── Makefile`,
+			want: []string{"web/", "web/assets/", "web/assets/logo.svg", "web/index.html", "scripts/", "scripts/run.sh", "Makefile"},
+		},
+		{
+			name: "directory without slash inferred from children",
+			input: `app/
+└── web
+    └── views
+        └── home.html`,
+			want: []string{"web/", "web/views/", "web/views/home.html"},
+		},
+		{
+			name: "two-space indentation",
+			input: `app/
+├─ src/
+│ ├─ app.js
+│ └─ lib/
+│   └─ math.js
+└─ package.json`,
+			want: []string{"src/", "src/app.js", "src/lib/", "src/lib/math.js", "package.json"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			var got []string
+			for _, n := range nodes {
+				got = append(got, n.Path)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Parse() paths =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestParseSimpleNestedPaths(t *testing.T) {
+	input := `internal/config/
+internal/config/config.go # configuration loader
+cmd/tool/main.go # entry point
+docs/api/v1/`
+
+	nodes, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := []Node{
+		{Path: "internal/", IsDir: true},
+		{Path: "internal/config/", IsDir: true, Line: 1, Depth: 1},
+		{Path: "internal/config/config.go", Comment: "configuration loader", Line: 2, Depth: 2},
+		{Path: "cmd/", IsDir: true},
+		{Path: "cmd/tool/", IsDir: true, Depth: 1},
+		{Path: "cmd/tool/main.go", Comment: "entry point", Line: 3, Depth: 2},
+		{Path: "docs/", IsDir: true},
+		{Path: "docs/api/", IsDir: true, Depth: 1},
+		{Path: "docs/api/v1/", IsDir: true, Line: 4, Depth: 2},
+	}
+	if len(nodes) != len(want) {
+		t.Fatalf("Parse() returned %d nodes, want %d: %+v", len(nodes), len(want), nodes)
+	}
+	for i := range want {
+		if !reflect.DeepEqual(nodes[i], want[i]) {
+			t.Errorf("node %d = %+v, want %+v", i, nodes[i], want[i])
+		}
+	}
+}
+
 // TestCalcDepth removed because we've redesigned the parsing approach
+
+func TestParseDirectives(t *testing.T) {
+	input := `scripts/
+├── deploy.sh # deploy to staging @executable
+├── server.go # http server @template=grpc-service
+└── notes.md # reach me @ the office`
+
+	nodes, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := []Node{
+		{Path: "deploy.sh", Comment: "deploy to staging", Line: 2, Attrs: map[string]string{"executable": ""}},
+		{Path: "server.go", Comment: "http server", Line: 3, Attrs: map[string]string{"template": "grpc-service"}},
+		{Path: "notes.md", Comment: "reach me @ the office", Line: 4},
+	}
+	if !reflect.DeepEqual(nodes, want) {
+		t.Errorf("Parse() = %+v, want %+v", nodes, want)
+	}
+}
+
+func TestParseSymlinks(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Node
+	}{
+		{
+			name: "tree format",
+			input: `releases/
+├── v1.2.0/
+│   └── notes.md
+└── latest -> v1.2.0 # current release`,
+			want: []Node{
+				{Path: "v1.2.0/", IsDir: true, Line: 2},
+				{Path: "v1.2.0/notes.md", Line: 3, Depth: 1},
+				{Path: "latest", Comment: "current release", Line: 4, LinkTarget: "v1.2.0"},
+			},
+		},
+		{
+			name: "simple format",
+			input: `bin/
+bin/tool -> ../scripts/tool.sh`,
+			want: []Node{
+				{Path: "bin/", IsDir: true, Line: 1},
+				{Path: "bin/tool", Line: 2, Depth: 1, LinkTarget: "../scripts/tool.sh"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(nodes, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", nodes, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBase64Content(t *testing.T) {
+	input := `assets/
+├── favicon.ico # site icon @base64=AAEC/w==
+└── robots.txt`
+
+	nodes, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := []Node{
+		{Path: "favicon.ico", Comment: "site icon", Line: 2, Content: []byte{0x00, 0x01, 0x02, 0xff}},
+		{Path: "robots.txt", Line: 3},
+	}
+	if !reflect.DeepEqual(nodes, want) {
+		t.Errorf("Parse() = %+v, want %+v", nodes, want)
+	}
+
+	_, err = Parse(strings.NewReader("assets/\n└── logo.png # @base64=not*base64\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2: invalid @base64 data for logo.png") {
+		t.Errorf("Parse() error = %v, want invalid @base64 data on line 2", err)
+	}
+}
+
+func TestParseHeredoc(t *testing.T) {
+	input := `app/
+├── config.yaml <<END # service settings
+port: 8080
+
+debug: false
+END
+├── empty.txt <<EOF
+EOF
+└── main.go`
+
+	nodes, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := []Node{
+		{Path: "config.yaml", Comment: "service settings", Line: 2, Content: []byte("port: 8080\n\ndebug: false\n")},
+		{Path: "empty.txt", Line: 7, Content: []byte{}},
+		{Path: "main.go", Line: 9},
+	}
+	if !reflect.DeepEqual(nodes, want) {
+		t.Errorf("Parse() = %+v, want %+v", nodes, want)
+	}
+
+	if _, err := Parse(strings.NewReader("notes.md <<END\nnever closed\n")); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("expected an unterminated heredoc error, got %v", err)
+	}
+}
+
+func TestParseDepth(t *testing.T) {
+	inputs := map[string]string{
+		"tree": `app/
+├── go.mod
+├── cmd/
+│   └── app/
+│       └── main.go
+└── internal/
+    └── store/
+        └── db/
+            └── conn.go`,
+		"simple": `go.mod
+cmd/app/main.go
+internal/store/db/conn.go`,
+	}
+	want := map[string]int{
+		"go.mod":                    0,
+		"cmd/":                      0,
+		"cmd/app/":                  1,
+		"cmd/app/main.go":           2,
+		"internal/":                 0,
+		"internal/store/":           1,
+		"internal/store/db/":        2,
+		"internal/store/db/conn.go": 3,
+	}
+
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			nodes, err := Parse(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			got := make(map[string]int)
+			for _, n := range nodes {
+				got[n.Path] = n.Depth
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("depths = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseDottedDirectories(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Node
+	}{
+		{
+			name: "tree format",
+			input: `app/
+├── assets.bundle
+│   ├── icon.png
+│   └── fonts/
+│       └── mono.ttf
+└── main.go`,
+			want: []Node{
+				{Path: "assets.bundle/", IsDir: true, Line: 2},
+				{Path: "assets.bundle/icon.png", Line: 3, Depth: 1},
+				{Path: "assets.bundle/fonts/", IsDir: true, Line: 4, Depth: 1},
+				{Path: "assets.bundle/fonts/mono.ttf", Line: 5, Depth: 2},
+				{Path: "main.go", Line: 6},
+			},
+		},
+		{
+			name: "declared before a grandchild",
+			input: `assets.bundle # static files
+assets.bundle/img/icon.png`,
+			want: []Node{
+				{Path: "assets.bundle/", IsDir: true, Comment: "static files", Line: 1},
+				{Path: "assets.bundle/img/", IsDir: true, Depth: 1},
+				{Path: "assets.bundle/img/icon.png", Line: 2, Depth: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(nodes, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", nodes, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMetaColumns(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Node
+	}{
+		{
+			name: "tree -s and -p",
+			input: `.
+├── [       4096]  cmd
+│   └── [        120]  main.go
+├── [drwxr-xr-x       4096]  docs
+│   └── [-rw-r--r--         12]  README.md
+├── [  1.2K]  go.mod
+└── [id].tsx
+
+2 directories, 4 files`,
+			want: []Node{
+				{Path: "cmd/", IsDir: true, Line: 2},
+				{Path: "cmd/main.go", Line: 3, Depth: 1},
+				{Path: "docs/", IsDir: true, Line: 4},
+				{Path: "docs/README.md", Line: 5, Depth: 1},
+				{Path: "go.mod", Line: 6},
+				{Path: "[id].tsx", Line: 7},
+			},
+		},
+		{
+			name: "ls -l",
+			input: `total 24
+drwxr-xr-x  3 lance  staff    96 Jan  2 15:04 scripts
+-rw-r--r--  1 lance  staff  1234 Jan  2  2024 go.mod
+lrwxr-xr-x  1 lance  staff     6 2024-01-02 15:04 latest -> go.mod
+-rw-r--r--@ 1 lance  staff   220 Mar 14 09:30 main.go`,
+			want: []Node{
+				{Path: "scripts/", IsDir: true, Line: 2},
+				{Path: "go.mod", Line: 3},
+				{Path: "latest", Line: 4, LinkTarget: "go.mod"},
+				{Path: "main.go", Line: 5},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(nodes, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", nodes, tt.want)
+			}
+		})
+	}
+}
+
+func TestExplain(t *testing.T) {
+	input := `app/
+├── cmd/
+│   └── main.go # entry point
+└── internal/store/db.go`
+
+	var out strings.Builder
+	if err := Explain(&out, strings.NewReader(input), ParseOptions{}); err != nil {
+		t.Fatalf("Explain() error = %v", err)
+	}
+
+	want := `Format: tree
+Indent unit: 4 columns
+Lines:
+    1  app/
+       -> no node
+    2  ├── cmd/
+       -> cmd/ (dir, depth 0)
+    3  │   └── main.go # entry point
+       -> cmd/main.go (file, depth 1, comment "entry point")
+    4  └── internal/store/db.go
+       -> internal/store/db.go (file, depth 2)
+Implied:
+       -> internal/ (dir, depth 0)
+       -> internal/store/ (dir, depth 1)
+`
+	if out.String() != want {
+		t.Errorf("Explain() =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestParsePartialResults(t *testing.T) {
+	head := "app/\n├── cmd/\n│   └── main.go\n├── go.mod\n"
+	tests := []struct {
+		name    string
+		tail    string
+		wantErr string
+	}{
+		{"line too long", "└── " + strings.Repeat("x", 70*1024) + "\n", "line 5:"},
+		{"bad base64", "└── logo.png # @base64=***\n", "line 5: invalid @base64"},
+		{"unterminated heredoc", "└── notes.md <<END\nnever closed\n", "line 5: heredoc <<END"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := Parse(strings.NewReader(head + tt.tail))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Parse() error = %v, want %q", err, tt.wantErr)
+			}
+			var got []string
+			for _, n := range nodes {
+				got = append(got, n.Path)
+			}
+			for _, want := range []string{"cmd/", "cmd/main.go", "go.mod"} {
+				if !strings.Contains(strings.Join(got, " "), want) {
+					t.Errorf("Parse() = %v, want the nodes before the bad line, including %s", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderTree(t *testing.T) {
+	nodes := []Node{
+		{Path: "src/", IsDir: true},
+		{Path: "src/main/", IsDir: true},
+		{Path: "src/main/java/", IsDir: true},
+		{Path: "src/main/java/App.java", Comment: "entry point"},
+		{Path: "docs/", IsDir: true, Comment: "handbook"},
+		{Path: "docs/guide/", IsDir: true},
+		{Path: "docs/guide/intro.md"},
+		{Path: "latest", LinkTarget: "docs"},
+	}
+
+	expanded := `├── src/
+│   └── main/
+│       └── java/
+│           └── App.java # entry point
+├── docs/ # handbook
+│   └── guide/
+│       └── intro.md
+└── latest -> docs
+`
+	if got := RenderTree(nodes, RenderOptions{}); got != expanded {
+		t.Errorf("RenderTree() =\n%s\nwant\n%s", got, expanded)
+	}
+
+	collapsed := `├── src/main/java/
+│   └── App.java # entry point
+├── docs/ # handbook
+│   └── guide/
+│       └── intro.md
+└── latest -> docs
+`
+	if got := RenderTree(nodes, RenderOptions{CollapseSingleChildDirs: true}); got != collapsed {
+		t.Errorf("RenderTree(collapsed) =\n%s\nwant\n%s", got, collapsed)
+	}
+
+	aligned := `├── src/
+│   └── main/
+│       └── java/
+│           └── App.java # entry point
+├── docs/                # handbook
+│   └── guide/
+│       └── intro.md
+└── latest -> docs
+`
+	if got := RenderTree(nodes, RenderOptions{AlignComments: true}); got != aligned {
+		t.Errorf("RenderTree(aligned) =\n%s\nwant\n%s", got, aligned)
+	}
+
+	// The expanded drawing parses back into the same paths
+	parsed, err := Parse(strings.NewReader(expanded))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	var got []string
+	for _, n := range parsed {
+		got = append(got, n.Path)
+	}
+	want := []string{"src/", "src/main/", "src/main/java/", "src/main/java/App.java", "docs/", "docs/guide/", "docs/guide/intro.md", "latest"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %v, want %v", got, want)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  Format
+	}{
+		{"tree", "app/\n├── main.go\n└── go.mod\n", FormatTree},
+		{"partial tree", "├── main.go\n└── go.mod\n", FormatTree},
+		{"simple", "cmd/\ncmd/main.go # entry\n", FormatSimple},
+		{"json object", "  {\"name\": \"app\", \"children\": []}\n", FormatJSON},
+		{"json array", "[\"cmd/main.go\"]\n", FormatJSON},
+		{"yaml document", "---\napp:\n  - main.go\n", FormatYAML},
+		{"yaml mapping", "\nname: app\nfiles:\n  - main.go\n", FormatYAML},
+		{"bracketed directory", "[slug]/page.tsx\n[slug]/layout.tsx\n", FormatSimple},
+		{"template directory", "{{name}}/\n├── main.go\n└── go.mod\n", FormatTree},
+		{"colon directory", "src:\n  main.go\ngo.mod\n", FormatSimple},
+		{"key-like comment", "notes: todo.md\ncmd/main.go\n", FormatSimple},
+		{"empty", " \n\n", FormatUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, r, err := DetectFormat(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("DetectFormat() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("DetectFormat() = %v, want %v", got, tt.want)
+			}
+			rest, err := io.ReadAll(r)
+			if err != nil || string(rest) != tt.input {
+				t.Errorf("returned reader yields %q, %v; want the full input", rest, err)
+			}
+		})
+	}
+
+	if _, err := Parse(strings.NewReader("{\"name\": \"app\"}")); err == nil || !strings.Contains(err.Error(), "JSON") {
+		t.Errorf("Parse() of JSON error = %v, want an unsupported format error", err)
+	}
+}
+
+func TestParseCodeFence(t *testing.T) {
+	input := "```text\n" + `myapp/
+├── cmd/
+│   └── main.go # entry point
+└── go.mod
+` + "```\n"
+
+	nodes, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := []Node{
+		{Path: "cmd/", IsDir: true, Line: 3},
+		{Path: "cmd/main.go", Comment: "entry point", Line: 4, Depth: 1},
+		{Path: "go.mod", Line: 5},
+	}
+	if !reflect.DeepEqual(nodes, want) {
+		t.Errorf("Parse() = %+v, want %+v", nodes, want)
+	}
+
+	// A fenced path list works the same way
+	nodes, err = Parse(strings.NewReader("~~~\ncmd/main.go\n~~~\n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if len(nodes) != 2 || nodes[1].Path != "cmd/main.go" {
+		t.Errorf("Parse() of a fenced list = %+v", nodes)
+	}
+}
+
+func TestParseBoxDrawingInComments(t *testing.T) {
+	input := `tool/
+├── render/ # draws │ ├── and └── connectors
+│   └── glyphs.go # the │ glyph set
+└── README.md # usage: tree │ tree2scaffold`
+
+	nodes, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := []Node{
+		{Path: "render/", IsDir: true, Comment: "draws │ ├── and └── connectors", Line: 2},
+		{Path: "render/glyphs.go", Comment: "the │ glyph set", Line: 3, Depth: 1},
+		{Path: "README.md", Comment: "usage: tree │ tree2scaffold", Line: 4},
+	}
+	if !reflect.DeepEqual(nodes, want) {
+		t.Errorf("Parse() = %+v, want %+v", nodes, want)
+	}
+
+	// Glyphs in comments do not turn a path list into a tree
+	nodes, err = Parse(strings.NewReader("docs/\ndocs/tree.md # explains │ and ├──\n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want = []Node{
+		{Path: "docs/", IsDir: true, Line: 1},
+		{Path: "docs/tree.md", Comment: "explains │ and ├──", Line: 2, Depth: 1},
+	}
+	if !reflect.DeepEqual(nodes, want) {
+		t.Errorf("Parse() = %+v, want %+v", nodes, want)
+	}
+}
+
+func TestParseUTF16(t *testing.T) {
+	const tree = "app/\r\n├── main.go # entry point\r\n└── README.md\r\n"
+	want := []Node{
+		{Path: "main.go", Comment: "entry point", Line: 2},
+		{Path: "README.md", Line: 3},
+	}
+
+	encode := func(bom []byte, order binary.AppendByteOrder) []byte {
+		b := append([]byte{}, bom...)
+		for _, u := range utf16.Encode([]rune(tree)) {
+			b = order.AppendUint16(b, u)
+		}
+		return b
+	}
+
+	inputs := map[string][]byte{
+		"utf-16le":  encode([]byte{0xFF, 0xFE}, binary.LittleEndian),
+		"utf-16be":  encode([]byte{0xFE, 0xFF}, binary.BigEndian),
+		"utf-8 bom": append([]byte{0xEF, 0xBB, 0xBF}, tree...),
+	}
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			nodes, err := Parse(bytes.NewReader(input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(nodes, want) {
+				t.Errorf("Parse() = %+v, want %+v", nodes, want)
+			}
+		})
+	}
+}
+
+func TestParseNonBreakingSpaces(t *testing.T) {
+	// A tree copied from a rendered page: U+00A0 after the glyphs and in the
+	// indentation, a narrow no-break space before a comment
+	const tree = "app/\n" +
+		"├──\u00a0cmd/\n" +
+		"│\u00a0\u00a0 └──\u00a0main.go\u202f# entry point\n" +
+		"└──\u00a0README.md\n"
+	nodes, err := Parse(strings.NewReader(tree))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := []Node{
+		{Path: "cmd/", IsDir: true, Line: 2},
+		{Path: "cmd/main.go", Comment: "entry point", Depth: 1, Line: 3},
+		{Path: "README.md", Line: 4},
+	}
+	if !reflect.DeepEqual(nodes, want) {
+		t.Errorf("Parse() = %+v, want %+v", nodes, want)
+	}
+
+}
+
+func TestParseWindowsTree(t *testing.T) {
+	// `tree /f` in cmd.exe, CRLF line endings included
+	unicodeTree := "Folder PATH listing for volume OS\r\n" +
+		"Volume serial number is 1234-ABCD\r\n" +
+		"C:.\r\n" +
+		"│   go.mod\r\n" +
+		"│\r\n" +
+		"├───cmd\r\n" +
+		"│   └───app\r\n" +
+		"│           main.go\r\n" +
+		"│\r\n" +
+		"└───internal\r\n" +
+		"        util.go\r\n"
+	want := []Node{
+		{Path: "go.mod", Line: 4},
+		{Path: "cmd/", IsDir: true, Line: 6},
+		{Path: "cmd/app/", IsDir: true, Depth: 1, Line: 7},
+		{Path: "cmd/app/main.go", Depth: 2, Line: 8},
+		{Path: "internal/", IsDir: true, Line: 10},
+		{Path: "internal/util.go", Depth: 1, Line: 11},
+	}
+	nodes, err := Parse(strings.NewReader(unicodeTree))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if !reflect.DeepEqual(nodes, want) {
+		t.Errorf("Parse() = %+v, want %+v", nodes, want)
+	}
+
+	// "C:." is the current directory, not a root to keep
+	kept, err := ParseWithOptions(strings.NewReader(unicodeTree), ParseOptions{KeepRoot: true})
+	if err != nil {
+		t.Fatalf("ParseWithOptions() error = %v", err)
+	}
+	if !reflect.DeepEqual(kept, want) {
+		t.Errorf("ParseWithOptions(KeepRoot) = %+v, want %+v", kept, want)
+	}
+	docs, err := ParseDocuments(strings.NewReader(unicodeTree), ParseOptions{KeepRoot: true})
+	if err != nil {
+		t.Fatalf("ParseDocuments() error = %v", err)
+	}
+	if len(docs) != 1 || docs[0].Root != "" {
+		t.Fatalf("ParseDocuments() = %+v, want one document without a root", docs)
+	}
+
+	// `tree /f /a` rooted at a path, whose last element is the root
+	ascii := "C:\\Users\\me\\app\n" +
+		"|   go.mod\n" +
+		"|\n" +
+		"+---cmd\n" +
+		"|   \\---app\n" +
+		"|           main.go\n" +
+		"|\n" +
+		"\\---internal\n" +
+		"        util.go\n"
+	docs, err = ParseDocuments(strings.NewReader(ascii), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseDocuments() error = %v", err)
+	}
+	if len(docs) != 1 || docs[0].Root != "app" {
+		t.Fatalf("ParseDocuments() = %+v, want one document rooted at app", docs)
+	}
+	for i := range want {
+		want[i].Line -= 2 // no volume header
+	}
+	if !reflect.DeepEqual(docs[0].Nodes, want) {
+		t.Errorf("ParseDocuments() nodes = %+v, want %+v", docs[0].Nodes, want)
+	}
+}
+
+func TestParseLsR(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Node
+	}{
+		{
+			name: "current directory",
+			input: `.:
+cmd
+go.mod
+internal
+
+./cmd:
+app
+
+./cmd/app:
+main.go
+
+./internal:
+store.go
+`,
+			want: []Node{
+				{Path: "cmd/", IsDir: true, Line: 2},
+				{Path: "go.mod", Line: 3},
+				{Path: "internal/", IsDir: true, Line: 4},
+				{Path: "cmd/app/", IsDir: true, Line: 7, Depth: 1},
+				{Path: "cmd/app/main.go", Line: 10, Depth: 2},
+				{Path: "internal/store.go", Line: 13, Depth: 1},
+			},
+		},
+		{
+			name:  "multi-column",
+			input: ".:\ncmd  go.mod  internal\n\n./cmd:\nmain.go\tversion.go\n\n./internal:\nmy notes.md\n",
+			want: []Node{
+				{Path: "cmd/", IsDir: true, Line: 2},
+				{Path: "go.mod", Line: 2},
+				{Path: "internal/", IsDir: true, Line: 2},
+				{Path: "cmd/main.go", Line: 5, Depth: 1},
+				{Path: "cmd/version.go", Line: 5, Depth: 1},
+				{Path: "internal/my notes.md", Line: 8, Depth: 1},
+			},
+		},
+		{
+			name: "named root",
+			input: `myapp:
+README.md
+docs
+
+myapp/docs:
+guide.md
+`,
+			want: []Node{
+				{Path: "README.md", Line: 2},
+				{Path: "docs/", IsDir: true, Line: 3},
+				{Path: "docs/guide.md", Line: 6, Depth: 1},
+			},
+		},
+		{
+			name: "sibling sharing the root's prefix",
+			input: `a:
+x.go
+
+ab:
+y.go
+`,
+			want: []Node{
+				{Path: "x.go", Line: 2},
+				{Path: "ab/", IsDir: true},
+				{Path: "ab/y.go", Line: 5, Depth: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _, err := DetectFormat(strings.NewReader(tt.input))
+			if err != nil || f != FormatLsR {
+				t.Fatalf("DetectFormat() = %v, %v; want %v", f, err, FormatLsR)
+			}
+			nodes, err := Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(nodes, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", nodes, tt.want)
+			}
+		})
+	}
+
+	// YAML keys are not mistaken for ls -R headers
+	if f, _, _ := DetectFormat(strings.NewReader("app:\n  files:\n    - main.go\n")); f != FormatYAML {
+		t.Errorf("DetectFormat(yaml) = %v, want %v", f, FormatYAML)
+	}
+
+	// Nor is prose ending in a colon
+	if f, _, _ := DetectFormat(strings.NewReader("Here is the layout:\nmain.go\ncmd/\n")); f == FormatLsR {
+		t.Errorf("DetectFormat(prose) = %v, want anything but %v", f, FormatLsR)
+	}
+}
+
+func TestParseList(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Node
+	}{
+		{
+			name: "dash bullets",
+			input: `- cmd/
+  - app/
+    - main.go # entry point
+- ` + "`internal`" + `
+  - store.go
+- go.mod
+`,
+			want: []Node{
+				{Path: "cmd/", IsDir: true, Line: 1},
+				{Path: "cmd/app/", IsDir: true, Line: 2, Depth: 1},
+				{Path: "cmd/app/main.go", Comment: "entry point", Line: 3, Depth: 2},
+				{Path: "internal/", IsDir: true, Line: 4},
+				{Path: "internal/store.go", Line: 5, Depth: 1},
+				{Path: "go.mod", Line: 6},
+			},
+		},
+		{
+			name: "numbered nesting",
+			input: `1. docs
+   a. guide.md
+   b. api.md
+2. README.md
+`,
+			want: []Node{
+				{Path: "docs/", IsDir: true, Line: 1},
+				{Path: "docs/guide.md", Line: 2, Depth: 1},
+				{Path: "docs/api.md", Line: 3, Depth: 1},
+				{Path: "README.md", Line: 4},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _, err := DetectFormat(strings.NewReader(tt.input))
+			if err != nil || f != FormatList {
+				t.Fatalf("DetectFormat() = %v, %v; want %v", f, err, FormatList)
+			}
+			nodes, err := Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(nodes, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", nodes, tt.want)
+			}
+		})
+	}
+
+	// The caller can name the format instead of relying on detection
+	input := "- src/\n  - main.go\n"
+	nodes, err := ParseWithOptions(strings.NewReader(input), ParseOptions{Format: FormatList})
+	if err != nil {
+		t.Fatalf("ParseWithOptions() error = %v", err)
+	}
+	want := []Node{
+		{Path: "src/", IsDir: true, Line: 1},
+		{Path: "src/main.go", Line: 2, Depth: 1},
+	}
+	if !reflect.DeepEqual(nodes, want) {
+		t.Errorf("ParseWithOptions(FormatList) = %+v, want %+v", nodes, want)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for in, want := range map[string]Format{
+		"": FormatUnknown, "auto": FormatUnknown, "tree": FormatTree,
+		"simple": FormatSimple, "list": FormatList, "ls-r": FormatLsR,
+	} {
+		if got, err := ParseFormat(in); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("json"); err == nil {
+		t.Error("ParseFormat(\"json\") should fail")
+	}
+}
+
+func TestParseInclude(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		name = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("fragments/service.tree", "service/\n├── handler.go # http handlers\n└── store/\n    └── store.go\n")
+
+	input := `app/
+├── services/
+│   └── users/
+│       └── @include fragments/service.tree
+└── go.mod
+`
+	nodes, err := ParseWithOptions(strings.NewReader(input), ParseOptions{IncludeDir: dir})
+	if err != nil {
+		t.Fatalf("ParseWithOptions() error = %v", err)
+	}
+	want := []Node{
+		{Path: "services/", IsDir: true, Line: 2},
+		{Path: "services/users/", IsDir: true, Line: 3, Depth: 1},
+		{Path: "services/users/handler.go", Comment: "http handlers", Line: 4, Depth: 2},
+		{Path: "services/users/store/", IsDir: true, Line: 4, Depth: 2},
+		{Path: "services/users/store/store.go", Line: 4, Depth: 3},
+		{Path: "go.mod", Line: 5},
+	}
+	if !reflect.DeepEqual(nodes, want) {
+		t.Errorf("ParseWithOptions() = %+v, want %+v", nodes, want)
+	}
+
+	// "@include" in a comment splices nothing in
+	nodes, err = ParseWithOptions(strings.NewReader("docs/\n├── foo.md # see @include x\n└── bar.md\n"), ParseOptions{IncludeDir: dir})
+	if err != nil {
+		t.Fatalf("ParseWithOptions() error = %v", err)
+	}
+	if len(nodes) != 2 || nodes[0].Path != "foo.md" || nodes[0].Comment != "see x" || nodes[1].Path != "bar.md" {
+		t.Errorf("ParseWithOptions() = %+v, want just foo.md, with x left in its comment, and bar.md", nodes)
+	}
+
+	// Includes need a directory to resolve against
+	if _, err := Parse(strings.NewReader(input)); err == nil || !strings.Contains(err.Error(), "@include fragments/service.tree") {
+		t.Errorf("Parse() without IncludeDir error = %v", err)
+	}
+
+	// A spec that includes itself, even through another, is refused
+	write("a.tree", "lib/\n@include b.tree\n")
+	write("b.tree", "@include a.tree\n")
+	_, err = ParseWithOptions(strings.NewReader("@include a.tree\n"), ParseOptions{IncludeDir: dir})
+	if err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("ParseWithOptions() with a cycle error = %v, want an include cycle", err)
+	}
+}
+
+func TestParseDocuments(t *testing.T) {
+	input := `---
+api/
+└── go.mod
+---
+cmd/main.go
+`
+	docs, err := ParseDocuments(strings.NewReader(input), ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseDocuments() error = %v", err)
+	}
+	want := []Document{
+		{Root: "api", RootLine: 2, Nodes: []Node{{Path: "go.mod", Line: 3}}},
+		{Nodes: []Node{
+			{Path: "cmd/", IsDir: true},
+			{Path: "cmd/main.go", Line: 5, Depth: 1},
+		}},
+	}
+	if !reflect.DeepEqual(docs, want) {
+		t.Errorf("ParseDocuments() = %+v, want %+v", docs, want)
+	}
+}
+
+func TestParseLineNumbers(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []Node
+	}{
+		{
+			name:  "cat -n",
+			input: "     1\tapp/\n     2\t├── cmd/\n     3\t│   └── main.go # entry\n     4\t\n     5\t└── go.mod\n",
+			want: []Node{
+				{Path: "cmd/", IsDir: true, Line: 2},
+				{Path: "cmd/main.go", Comment: "entry", Line: 3, Depth: 1},
+				{Path: "go.mod", Line: 5},
+			},
+		},
+		{
+			name:  "editor gutter",
+			input: "  9  app/\n 10  ├── src/\n 11  │   └── main.go\n 12  └── README.md\n",
+			want: []Node{
+				{Path: "src/", IsDir: true, Line: 2},
+				{Path: "src/main.go", Line: 3, Depth: 1},
+				{Path: "README.md", Line: 4},
+			},
+		},
+		{
+			name:  "numbered names",
+			input: "2024/\n2024/notes.md\n",
+			want: []Node{
+				{Path: "2024/", IsDir: true, Line: 1},
+				{Path: "2024/notes.md", Line: 2, Depth: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := Parse(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(nodes, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", nodes, tt.want)
+			}
+		})
+	}
+
+	// Numbers that do not count up with the lines are left alone
+	if lines := []string{"1 a", "5 b"}; stripLineNumbers(lines) || lines[0] != "1 a" {
+		t.Errorf("stripLineNumbers() stripped an uneven gutter: %q", lines)
+	}
+}
+
+func TestParseHiddenDirectories(t *testing.T) {
+	// `tree -a` output: dot-directories nest like any other directory, at
+	// every depth, without relying on fixNestedPaths to relocate their files
+	input := `project/
+├── .config/
+│   └── nvim/
+│       └── lua/
+│           └── plugins/
+│               └── init.lua
+├── .github/
+│   ├── ISSUE_TEMPLATE/
+│   │   └── bug_report.md
+│   └── workflows/
+│       ├── build.yml
+│       └── deploy/
+│           └── prod.yml
+├── .vscode/
+│   ├── launch.json
+│   └── .cache/
+│       └── state.json
+└── main.go
+`
+	nodes, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := []string{
+		".config/", ".config/nvim/", ".config/nvim/lua/", ".config/nvim/lua/plugins/",
+		".config/nvim/lua/plugins/init.lua",
+		".github/", ".github/ISSUE_TEMPLATE/", ".github/ISSUE_TEMPLATE/bug_report.md",
+		".github/workflows/", ".github/workflows/build.yml",
+		".github/workflows/deploy/", ".github/workflows/deploy/prod.yml",
+		".vscode/", ".vscode/launch.json", ".vscode/.cache/", ".vscode/.cache/state.json",
+		"main.go",
+	}
+	var got []string
+	for _, n := range nodes {
+		got = append(got, n.Path)
+		if n.Depth != PathDepth(n.Path) {
+			t.Errorf("%s has depth %d, want %d", n.Path, n.Depth, PathDepth(n.Path))
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() paths = %q, want %q", got, want)
+	}
+}
+
+func TestParseTrailingSlashOptional(t *testing.T) {
+	const list = "go.mod\ninternal\nLICENSE\nbin -> /usr/local/bin\ncmd\ncmd/main.go\n"
+	for _, tt := range []struct {
+		optional bool
+		want     []Node
+	}{
+		{false, []Node{
+			{Path: "go.mod", Line: 1},
+			{Path: "internal", Line: 2},
+			{Path: "LICENSE", Line: 3},
+			{Path: "bin", LinkTarget: "/usr/local/bin", Line: 4},
+			{Path: "cmd/", IsDir: true, Line: 5},
+			{Path: "cmd/main.go", Line: 6, Depth: 1},
+		}},
+		{true, []Node{
+			{Path: "go.mod", Line: 1},
+			{Path: "internal/", IsDir: true, Line: 2},
+			{Path: "LICENSE", Line: 3},
+			{Path: "bin", LinkTarget: "/usr/local/bin", Line: 4},
+			{Path: "cmd/", IsDir: true, Line: 5},
+			{Path: "cmd/main.go", Line: 6, Depth: 1},
+		}},
+	} {
+		nodes, err := ParseWithOptions(strings.NewReader(list), ParseOptions{TrailingSlashOptional: tt.optional})
+		if err != nil {
+			t.Fatalf("TrailingSlashOptional=%v: error = %v", tt.optional, err)
+		}
+		if !reflect.DeepEqual(nodes, tt.want) {
+			t.Errorf("TrailingSlashOptional=%v: got %+v, want %+v", tt.optional, nodes, tt.want)
+		}
+	}
+
+	// Trees mark their directories with glyphs and are left alone
+	nodes, err := ParseWithOptions(strings.NewReader("app/\n└── internal\n"), ParseOptions{TrailingSlashOptional: true})
+	if err != nil {
+		t.Fatalf("tree: error = %v", err)
+	}
+	if len(nodes) != 1 || nodes[0].IsDir {
+		t.Errorf("tree: got %+v, want the file internal", nodes)
+	}
+}
+
+func TestMergeNodes(t *testing.T) {
+	a := []Node{
+		{Path: "cmd/", IsDir: true, Line: 1},
+		{Path: "cmd/main.go", Comment: "from a", Line: 2},
+		{Path: "go.mod", Line: 3},
+	}
+	b := []Node{
+		{Path: "cmd/", IsDir: true, Comment: "commands", Line: 1},
+		{Path: "cmd/main.go", Comment: "from b", Line: 2},
+		{Path: "README.md", Line: 3},
+	}
+
+	tests := []struct {
+		policy MergePolicy
+		want   []Node
+		err    string
+	}{
+		{MergePreferA, []Node{a[0], a[1], a[2], b[2]}, ""},
+		{MergePreferB, []Node{b[0], b[1], a[2], b[2]}, ""},
+		{MergeError, nil, `"cmd/main.go" is declared in both lists`},
+	}
+	for _, tt := range tests {
+		got, err := MergeNodes(a, b, tt.policy)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("policy %d: error = %v, want %q", tt.policy, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("policy %d: error = %v", tt.policy, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("policy %d: got %+v, want %+v", tt.policy, got, tt.want)
+		}
+	}
+	if a[1].Comment != "from a" {
+		t.Errorf("MergeNodes modified its input: %+v", a[1])
+	}
+
+	// Directories both lists declare are no conflict, even under MergeError
+	got, err := MergeNodes(a, b[:1], MergeError)
+	if err != nil || !reflect.DeepEqual(got, a) {
+		t.Errorf("MergeError with a shared directory = %+v, %v; want the first list", got, err)
+	}
+
+	// Type conflicts fail under every policy
+	for _, policy := range []MergePolicy{MergePreferA, MergePreferB, MergeError} {
+		if _, err := MergeNodes(a, []Node{{Path: "go.mod/", IsDir: true}}, policy); err == nil || err.Error() != `"go.mod" is a file in the first list and a directory in the second` {
+			t.Errorf("policy %d: dir/file conflict error = %v", policy, err)
+		}
+		if _, err := MergeNodes(a, []Node{{Path: "go.mod/extra.go"}}, policy); err == nil || err.Error() != `"go.mod" is a file, but "go.mod/extra.go" is inside it` {
+			t.Errorf("policy %d: file used as parent error = %v", policy, err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// RenderOptions tunes how RenderTree draws nodes
+type RenderOptions struct {
+	// CollapseSingleChildDirs draws a chain of directories that each contain
+	// only one directory as a single entry, e.g. "a/b/c/". It only changes
+	// the drawing, not the nodes.
+	CollapseSingleChildDirs bool
+
+	// AlignComments pads entries so every "#" comment starts in the same
+	// column, one past the longest commented entry
+	AlignComments bool
+
+	// Annotate, when set, returns a note drawn in parentheses after a
+	// node's name, such as the package of a Go file, or "" for none. Parse
+	// does not read notes back.
+	Annotate func(n Node) string
+}
+
+// renderLine is one drawn entry and its comment, kept apart until the
+// comment column is known
+type renderLine struct {
+	entry   string
+	comment string
+}
+
+// renderEntry is one name in the tree being rendered
+type renderEntry struct {
+	name     string
+	node     Node
+	children []*renderEntry
+}
+
+// RenderTree draws nodes as a `tree`-style diagram without a root line, in
+// the order the nodes were given. Directories end in "/", symlinks show
+// "-> target" and comments follow a "#". Parents only implied by a path are
+// drawn too. Parse reads the output back into the same paths.
+func RenderTree(nodes []Node, opts RenderOptions) string {
+	root := &renderEntry{node: Node{IsDir: true}}
+	entries := map[string]*renderEntry{".": root}
+
+	// entry returns the entry for path, creating it and its parents as needed
+	var entry func(path string) *renderEntry
+	entry = func(path string) *renderEntry {
+		if e, ok := entries[path]; ok {
+			return e
+		}
+		parent := entry(filepath.Dir(path))
+		e := &renderEntry{name: filepath.Base(path), node: Node{Path: path + "/", IsDir: true}}
+		parent.children = append(parent.children, e)
+		entries[path] = e
+		return e
+	}
+
+	for _, n := range nodes {
+		path := strings.TrimSuffix(n.Path, "/")
+		if path == "" || path == "." {
+			continue
+		}
+		entry(path).node = n
+	}
+
+	var lines []renderLine
+	root.render(&lines, "", opts)
+
+	column := 0
+	if opts.AlignComments {
+		for _, l := range lines {
+			if w := utf8.RuneCountInString(l.entry); l.comment != "" && w > column {
+				column = w
+			}
+		}
+	}
+
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString(l.entry)
+		if l.comment != "" {
+			if pad := column - utf8.RuneCountInString(l.entry); pad > 0 {
+				b.WriteString(strings.Repeat(" ", pad))
+			}
+			b.WriteString(" # " + l.comment)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// render appends a line for each of e's children, each starting with prefix
+func (e *renderEntry) render(lines *[]renderLine, prefix string, opts RenderOptions) {
+	for i, child := range e.children {
+		label := child.name
+		if child.node.IsDir {
+			for opts.CollapseSingleChildDirs && child.node.Comment == "" &&
+				len(child.children) == 1 && child.children[0].node.IsDir {
+				child = child.children[0]
+				label += "/" + child.name
+			}
+			label += "/"
+		}
+		if child.node.LinkTarget != "" {
+			label += " -> " + child.node.LinkTarget
+		}
+		if opts.Annotate != nil {
+			if note := opts.Annotate(child.node); note != "" {
+				label += " (" + note + ")"
+			}
+		}
+
+		connector, indent := "├── ", "│   "
+		if i == len(e.children)-1 {
+			connector, indent = "└── ", "    "
+		}
+		*lines = append(*lines, renderLine{entry: prefix + connector + label, comment: child.node.Comment})
+		child.render(lines, prefix+indent, opts)
+	}
+}
@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Windows `tree /f` output differs from the Unix tool's: a "C:." or
+// "C:\path" root line under a volume header, "├───" connectors glued to
+// directory names, files listed without connectors, and "+---", "\---" and
+// "|" in its ASCII (/a) form:
+//
+//	Folder PATH listing for volume OS
+//	Volume serial number is 1234-ABCD
+//	C:.
+//	│   go.mod
+//	│
+//	└───cmd
+//	        main.go
+var (
+	driveRootRe     = regexp.MustCompile(`^[A-Za-z]:(\.|\\.*)$`)
+	bareDriveRe     = regexp.MustCompile(`^[A-Za-z]:\.$`)
+	windowsHeaderRe = regexp.MustCompile(`^(Folder PATH listing( for volume .*)?|Volume serial number is .*|No subfolders exist\.?)$`)
+	windowsIndents  = []string{"│   ", "|   ", "    "}
+	windowsBranches = []string{"├───", "└───", "+---", `\---`}
+	windowsGlyphs   = strings.NewReplacer("│", "", "|", "", " ", "")
+)
+
+// convertWindowsTree rewrites Windows `tree` output in lines, in place, as the
+// equivalent Unix tree so the tree parser reads it. The header, the drive
+// root and the blank "│" separators become empty lines, which keeps the line
+// numbers of the others; a root line with a path becomes its last element.
+// Lines are left alone unless they start with a drive root and no line uses
+// Unix connectors.
+func convertWindowsTree(lines []string) {
+	root := -1
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || windowsHeaderRe.MatchString(line) {
+			continue
+		}
+		if driveRootRe.MatchString(line) {
+			root = i
+		}
+		break
+	}
+	if root < 0 {
+		return
+	}
+	for _, line := range lines[root+1:] {
+		if strings.Contains(line, "── ") {
+			return
+		}
+	}
+
+	for i, line := range lines {
+		switch {
+		case i < root:
+			lines[i] = ""
+		case i == root:
+			// a bare drive root such as "C:." is the current directory and
+			// names no root
+			lines[i] = ""
+			if name, _ := rootLine(line); name != "" && !bareDriveRe.MatchString(strings.TrimSpace(line)) {
+				lines[i] = name + "/"
+			}
+		case windowsGlyphs.Replace(line) == "" || windowsHeaderRe.MatchString(strings.TrimSpace(line)):
+			lines[i] = ""
+		default:
+			lines[i] = windowsTreeLine(line)
+		}
+	}
+}
+
+// windowsTreeLine converts one entry of Windows `tree` output. Each level is
+// four columns wide. A directory's connector sits in the column of its own
+// level, while a file is indented one level past its directory's children.
+func windowsTreeLine(line string) string {
+	units := 0
+	for {
+		rest, ok := cutAnyPrefix(line, windowsIndents)
+		if !ok {
+			break
+		}
+		line = rest
+		units++
+	}
+
+	depth := units - 1
+	name, isDir := cutAnyPrefix(line, windowsBranches)
+	if isDir {
+		depth = units
+	}
+	depth = max(depth, 0)
+
+	name = strings.ReplaceAll(name, `\`, "/")
+	if isDir {
+		before, comment, ok := strings.Cut(name, " #")
+		name = strings.TrimRight(strings.TrimSpace(before), "/") + "/"
+		if ok {
+			name += " #" + comment
+		}
+	}
+	return strings.Repeat("│   ", depth) + "├── " + name
+}
+
+// cutAnyPrefix removes the first of prefixes s starts with
+func cutAnyPrefix(s string, prefixes []string) (string, bool) {
+	for _, p := range prefixes {
+		if rest, ok := strings.CutPrefix(s, p); ok {
+			return rest, true
+		}
+	}
+	return s, false
+}
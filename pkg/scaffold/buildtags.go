@@ -0,0 +1,40 @@
+package scaffold
+
+import "strings"
+
+// knownOS and knownArch are the GOOS and GOARCH values the go tool matches in
+// file name suffixes such as _windows.go or _linux_arm64.go
+var (
+	knownOS = map[string]bool{
+		"aix": true, "android": true, "darwin": true, "dragonfly": true, "freebsd": true,
+		"hurd": true, "illumos": true, "ios": true, "js": true, "linux": true, "nacl": true,
+		"netbsd": true, "openbsd": true, "plan9": true, "solaris": true, "wasip1": true,
+		"windows": true, "zos": true,
+	}
+	knownArch = map[string]bool{
+		"386": true, "amd64": true, "amd64p32": true, "arm": true, "armbe": true, "arm64": true,
+		"arm64be": true, "loong64": true, "mips": true, "mipsle": true, "mips64": true,
+		"mips64le": true, "mips64p32": true, "mips64p32le": true, "ppc": true, "ppc64": true,
+		"ppc64le": true, "riscv": true, "riscv64": true, "s390": true, "s390x": true,
+		"sparc": true, "sparc64": true, "wasm": true,
+	}
+)
+
+// buildConstraint returns the //go:build expression implied by a Go file
+// name's _GOOS, _GOARCH or _GOOS_GOARCH suffix ("linux && amd64"), or "" when
+// the name has none. Like the go tool, it ignores a _test suffix and a name
+// that is nothing but the suffix ("windows.go").
+func buildConstraint(fileName string) string {
+	name := strings.TrimSuffix(strings.TrimSuffix(fileName, ".go"), "_test")
+	parts := strings.Split(name, "_")
+	n := len(parts)
+	switch {
+	case n >= 3 && knownOS[parts[n-2]] && knownArch[parts[n-1]]:
+		return parts[n-2] + " && " + parts[n-1]
+	case n >= 2 && knownOS[parts[n-1]]:
+		return parts[n-1]
+	case n >= 2 && knownArch[parts[n-1]]:
+		return parts[n-1]
+	}
+	return ""
+}
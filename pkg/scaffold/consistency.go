@@ -0,0 +1,99 @@
+package scaffold
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/lancekrogers/tree2scaffold/pkg/parser"
+)
+
+// CheckConsistency reports nodes that cannot coexist on disk: a path that is
+// declared as a file while another node needs it as a directory, either because
+// it is declared as one or because it is an ancestor of another node. These
+// contradictions usually come from relocation or prefix rewriting, and Apply
+// would otherwise skip or mangle them silently.
+func CheckConsistency(nodes []parser.Node) error {
+	// Index every file node by its clean path
+	files := make(map[string]parser.Node)
+	for _, n := range nodes {
+		if !n.IsDir {
+			files[cleanNodePath(n.Path)] = n
+		}
+	}
+
+	for _, n := range nodes {
+		path := cleanNodePath(n.Path)
+
+		// A directory node whose path is also declared as a file
+		if n.IsDir {
+			if f, ok := files[path]; ok {
+				return fmt.Errorf("inconsistent structure: %s is declared as a file but %s declares it as a directory",
+					describeNode(f), describeNode(n))
+			}
+		}
+
+		// Any node whose ancestor is declared as a file
+		for dir := filepath.Dir(path); dir != "." && dir != "/"; dir = filepath.Dir(dir) {
+			if f, ok := files[dir]; ok {
+				return fmt.Errorf("inconsistent structure: %s is declared as a file but %s needs it as a directory",
+					describeNode(f), describeNode(n))
+			}
+		}
+	}
+
+	return nil
+}
+
+// CheckParents reports the first node whose parent directory no node
+// declares, for specs that must spell out every directory they create
+func CheckParents(nodes []parser.Node) error {
+	dirs := make(map[string]bool)
+	for _, n := range nodes {
+		if n.IsDir {
+			dirs[cleanNodePath(n.Path)] = true
+		}
+	}
+	for _, n := range nodes {
+		dir := filepath.Dir(cleanNodePath(n.Path))
+		if dir != "." && dir != "/" && !dirs[dir] {
+			return fmt.Errorf("%s is inside %s/, which the spec does not declare", describeNode(n), dir)
+		}
+	}
+	return nil
+}
+
+// cleanNodePath strips the trailing slash that marks directory nodes
+func cleanNodePath(path string) string {
+	return strings.TrimSuffix(path, "/")
+}
+
+// describeNode renders a node for error messages, including its input line when known
+func describeNode(n parser.Node) string {
+	if n.Line > 0 {
+		return fmt.Sprintf("%q (line %d)", n.Path, n.Line)
+	}
+	return fmt.Sprintf("%q", n.Path)
+}
+
+// DefaultProtectedPaths are the version-control directories Apply refuses to
+// create, replace or convert, wherever they appear in a spec
+var DefaultProtectedPaths = []string{".git", ".hg", ".svn"}
+
+// CheckProtected reports the first node that is, or lies inside, a directory
+// named in protected, so scaffolding into an existing checkout can never
+// touch its repository data
+func CheckProtected(nodes []parser.Node, protected []string) error {
+	names := make(map[string]bool, len(protected))
+	for _, name := range protected {
+		names[name] = true
+	}
+	for _, n := range nodes {
+		for _, part := range strings.Split(cleanNodePath(n.Path), "/") {
+			if names[part] {
+				return fmt.Errorf("%s is protected: the spec must not create or replace %s", describeNode(n), part)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package scaffold
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LoadTemplateData reads the file of values templates see as .Data: JSON, or
+// YAML when the name ends in .yaml or .yml. The top level must be a mapping.
+func LoadTemplateData(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read template data: %w", err)
+	}
+
+	var v any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		v, err = parseYAML(string(data))
+	default:
+		err = json.Unmarshal(data, &v)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse template data %s: %w", path, err)
+	}
+	if v == nil {
+		return map[string]any{}, nil
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("cannot use template data %s: the top level must be a mapping", path)
+	}
+	return m, nil
+}
+
+// yamlLine is a meaningful line of a YAML document: its indentation and the
+// text after it, without a trailing comment
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// parseYAML decodes the block-style subset of YAML data files are written
+// in: nested mappings and sequences, plain and quoted scalars, and flow
+// sequences of scalars ("[a, b]"). Anchors, tags, block scalars ("|", ">")
+// and flow mappings are rejected.
+func parseYAML(src string) (any, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(src, "\n") {
+		text := strings.TrimRight(stripYAMLComment(raw), " \t\r")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || (len(lines) == 0 && trimmed == "---") {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs cannot indent YAML", i+1)
+		}
+		lines = append(lines, yamlLine{num: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+
+	v, next, err := parseYAMLBlock(lines, 0, lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if next < len(lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", lines[next].num)
+	}
+	return v, nil
+}
+
+// parseYAMLBlock parses the mapping or sequence whose entries start at
+// lines[i] with the given indentation, returning it and the next line index
+func parseYAMLBlock(lines []yamlLine, i, indent int) (any, int, error) {
+	if isYAMLSeqItem(lines[i].text) {
+		return parseYAMLSeq(lines, i, indent)
+	}
+	return parseYAMLMap(lines, i, indent)
+}
+
+// parseYAMLSeq parses "- item" entries at indent
+func parseYAMLSeq(lines []yamlLine, i, indent int) (any, int, error) {
+	seq := []any{}
+	for i < len(lines) && lines[i].indent == indent && isYAMLSeqItem(lines[i].text) {
+		item := strings.TrimLeft(strings.TrimPrefix(lines[i].text, "-"), " ")
+		switch {
+		case item == "":
+			// The item is the block nested below
+			if i+1 < len(lines) && lines[i+1].indent > indent {
+				v, next, err := parseYAMLBlock(lines, i+1, lines[i+1].indent)
+				if err != nil {
+					return nil, 0, err
+				}
+				seq, i = append(seq, v), next
+				continue
+			}
+			seq, i = append(seq, nil), i+1
+		case isYAMLSeqItem(item) || yamlKey(item) != "":
+			// "- key: value" starts a mapping, "- - x" a sequence, indented
+			// to the column of the item
+			col := indent + len(lines[i].text) - len(item)
+			lines[i] = yamlLine{num: lines[i].num, indent: col, text: item}
+			v, next, err := parseYAMLBlock(lines, i, col)
+			if err != nil {
+				return nil, 0, err
+			}
+			seq, i = append(seq, v), next
+		default:
+			v, err := parseYAMLScalar(item, lines[i].num)
+			if err != nil {
+				return nil, 0, err
+			}
+			seq, i = append(seq, v), i+1
+		}
+	}
+	return seq, i, nil
+}
+
+// parseYAMLMap parses "key: value" entries at indent
+func parseYAMLMap(lines []yamlLine, i, indent int) (any, int, error) {
+	m := map[string]any{}
+	for i < len(lines) && lines[i].indent == indent {
+		line := lines[i]
+		key := yamlKey(line.text)
+		if key == "" {
+			return nil, 0, fmt.Errorf("line %d: expected \"key: value\", got %q", line.num, line.text)
+		}
+		name, err := parseYAMLKey(key, line.num)
+		if err != nil {
+			return nil, 0, err
+		}
+		value := strings.TrimLeft(line.text[len(key)+1:], " ")
+		i++
+
+		switch {
+		case value != "":
+			if m[name], err = parseYAMLScalar(value, line.num); err != nil {
+				return nil, 0, err
+			}
+		case i < len(lines) && lines[i].indent > indent:
+			if m[name], i, err = parseYAMLBlock(lines, i, lines[i].indent); err != nil {
+				return nil, 0, err
+			}
+		case i < len(lines) && lines[i].indent == indent && isYAMLSeqItem(lines[i].text):
+			// A sequence may sit at its key's indentation
+			if m[name], i, err = parseYAMLSeq(lines, i, indent); err != nil {
+				return nil, 0, err
+			}
+		default:
+			m[name] = nil
+		}
+	}
+	return m, i, nil
+}
+
+// isYAMLSeqItem reports whether text is a "- item" sequence entry
+func isYAMLSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// yamlKey returns the key part of a "key: value" or "key:" line, including
+// any quotes, or "" when text is no mapping entry
+func yamlKey(text string) string {
+	if text[0] == '"' || text[0] == '\'' {
+		end := strings.IndexByte(text[1:], text[0])
+		if end < 0 {
+			return ""
+		}
+		key := text[:end+2]
+		if rest := text[len(key):]; rest == ":" || strings.HasPrefix(rest, ": ") {
+			return key
+		}
+		return ""
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return text[:i]
+		}
+	}
+	return ""
+}
+
+// parseYAMLKey unquotes a mapping key
+func parseYAMLKey(key string, num int) (string, error) {
+	v, err := parseYAMLScalar(key, num)
+	if err != nil {
+		return "", err
+	}
+	if s, ok := v.(string); ok && (key[0] == '"' || key[0] == '\'') {
+		return s, nil
+	}
+	return key, nil
+}
+
+// parseYAMLScalar decodes a scalar or flow sequence value
+func parseYAMLScalar(s string, num int) (any, error) {
+	switch {
+	case s[0] == '"':
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad double-quoted string %s", num, s)
+		}
+		return v, nil
+	case s[0] == '\'':
+		if len(s) < 2 || s[len(s)-1] != '\'' {
+			return nil, fmt.Errorf("line %d: bad single-quoted string %s", num, s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	case s[0] == '[':
+		if s[len(s)-1] != ']' {
+			return nil, fmt.Errorf("line %d: unterminated flow sequence %s", num, s)
+		}
+		seq := []any{}
+		if inner := strings.TrimSpace(s[1 : len(s)-1]); inner != "" {
+			for _, item := range strings.Split(inner, ",") {
+				v, err := parseYAMLScalar(strings.TrimSpace(item), num)
+				if err != nil {
+					return nil, err
+				}
+				seq = append(seq, v)
+			}
+		}
+		return seq, nil
+	case strings.ContainsRune("{&*!|>", rune(s[0])):
+		return nil, fmt.Errorf("line %d: unsupported YAML %q; write the value in block style or quote it", num, s)
+	}
+
+	switch s {
+	case "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return int(n), nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, nil
+	}
+	return s, nil
+}
+
+// stripYAMLComment cuts a "#" comment that starts a line or follows a space,
+// outside quoted values
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || line[i-1] == ' '):
+			quote = c // only a quote opening a value; "don't" has none
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
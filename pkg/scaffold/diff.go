@@ -0,0 +1,34 @@
+package scaffold
+
+import "github.com/lancekrogers/tree2scaffold/pkg/parser"
+
+// DiffNodes compares two specs path by path. Added holds the nodes only after
+// declares and removed those only before declares, each in its own spec's
+// order. Changed holds the after version of nodes both declare whose comment
+// or type differs: a file that became a directory, or a symlink with another
+// target.
+func DiffNodes(before, after []parser.Node) (added, removed, changed []parser.Node) {
+	old := make(map[string]parser.Node, len(before))
+	for _, n := range before {
+		old[cleanNodePath(n.Path)] = n
+	}
+	kept := make(map[string]bool, len(after))
+
+	for _, n := range after {
+		p := cleanNodePath(n.Path)
+		kept[p] = true
+		prev, ok := old[p]
+		switch {
+		case !ok:
+			added = append(added, n)
+		case prev.IsDir != n.IsDir, prev.LinkTarget != n.LinkTarget, prev.Comment != n.Comment:
+			changed = append(changed, n)
+		}
+	}
+	for _, n := range before {
+		if !kept[cleanNodePath(n.Path)] {
+			removed = append(removed, n)
+		}
+	}
+	return added, removed, changed
+}
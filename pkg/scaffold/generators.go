@@ -3,10 +3,15 @@ package scaffold
 
 import (
 	"fmt"
+	"io"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/lancekrogers/tree2scaffold/internal/env"
+	"github.com/lancekrogers/tree2scaffold/pkg/parser"
 )
 
 // FileGenerator produces the initial content for a file at relPath, given its comment.
@@ -17,6 +22,50 @@ type DefaultContentGenerator struct {
 	env           env.Environment
 	generators    map[string]FileGenerator
 	commentSyntax map[string]struct{ prefix, suffix string }
+	extAliases    map[string]string
+
+	// fallbackSyntax comments files whose type has no registered syntax. The
+	// zero value writes no comment at all.
+	fallbackSyntax struct{ prefix, suffix string }
+
+	// MainEverywhere makes every main.go package main, even inside library
+	// trees such as internal/ or pkg/
+	MainEverywhere bool
+
+	// RootPackage is the package name for .go files at the scaffold root
+	// other than main.go. Empty means "main".
+	RootPackage string
+
+	// CommentFromFilename gives files without a comment a placeholder derived
+	// from their name, e.g. "user_service.go" gets "user service"
+	CommentFromFilename bool
+
+	// BuildTags starts .go files named like foo_windows.go or bar_amd64.go
+	// with the //go:build constraint their suffix implies
+	BuildTags bool
+
+	// GoSum is what go.sum files are created with. The zero value writes an
+	// empty file, which the go command accepts.
+	GoSum GoSumMode
+
+	// ModulePath is the module path of the scaffold root, e.g.
+	// "github.com/me/app". When set, _test.go stubs outside package main are
+	// external tests (package util_test) that import the package under test,
+	// and generated go.mod files declare it.
+	ModulePath string
+
+	// GoVersion is the go directive of generated go.mod and go.work files,
+	// e.g. the one an existing go.work declares. Empty means the host
+	// toolchain's version.
+	GoVersion string
+
+	// mainDirs are the directories, noted by PlanSpec, whose main.go is
+	// package main, which makes every Go file there package main
+	mainDirs map[string]bool
+
+	// testFuncs are the test function names PlanSpec picked for the spec's
+	// test files, unique within each directory
+	testFuncs map[string]string
 }
 
 // NewDefaultContentGenerator creates a new content generator with default file
@@ -32,26 +81,59 @@ func newContentGenerator(e env.Environment) *DefaultContentGenerator {
 	gen := &DefaultContentGenerator{
 		env:        e,
 		generators: make(map[string]FileGenerator),
+		extAliases: make(map[string]string),
 		commentSyntax: map[string]struct{ prefix, suffix string }{
-			".py":   {"# ", ""},
-			".js":   {"// ", ""},
-			".ts":   {"// ", ""},
-			".rs":   {"// ", ""},
-			".java": {"// ", ""},
-			".c":    {"// ", ""},
-			".cpp":  {"// ", ""},
-			".h":    {"// ", ""},
-			".sh":   {"# ", ""},
-			".yaml": {"# ", ""},
-			".yml":  {"# ", ""},
-			".toml": {"# ", ""},
-			".xml":  {"<!-- ", " -->"},
-			".html": {"<!-- ", " -->"},
-			".md":   {"<!-- ", " -->"},
-			".mod":  {"// ", ""}, // go.mod files use Go-style comments
-			".work": {"// ", ""}, // go.work files use Go-style comments
-			".sum":  {"// ", ""}, // go.sum files use Go-style comments
-			".go":   {"// ", ""}, // Go files
+			".py":           {"# ", ""},
+			".js":           {"// ", ""},
+			".ts":           {"// ", ""},
+			".rs":           {"// ", ""},
+			".java":         {"// ", ""},
+			".c":            {"// ", ""},
+			".cpp":          {"// ", ""},
+			".h":            {"// ", ""},
+			".sh":           {"# ", ""},
+			".bash":         {"# ", ""},
+			".zsh":          {"# ", ""},
+			".rb":           {"# ", ""},
+			".pl":           {"# ", ""},
+			".r":            {"# ", ""},
+			".tf":           {"# ", ""},
+			".txt":          {"# ", ""}, // requirements.txt and friends
+			".cfg":          {"# ", ""},
+			".conf":         {"# ", ""},
+			".ini":          {"; ", ""},
+			".lua":          {"-- ", ""},
+			".sql":          {"-- ", ""},
+			".hs":           {"-- ", ""},
+			".el":           {";; ", ""},
+			".clj":          {";; ", ""},
+			".css":          {"/* ", " */"},
+			".scss":         {"// ", ""},
+			".jsx":          {"// ", ""},
+			".tsx":          {"// ", ""},
+			".mjs":          {"// ", ""},
+			".cjs":          {"// ", ""},
+			".kt":           {"// ", ""},
+			".swift":        {"// ", ""},
+			".cs":           {"// ", ""},
+			".php":          {"// ", ""},
+			".dart":         {"// ", ""},
+			".scala":        {"// ", ""},
+			".proto":        {"// ", ""},
+			".gitignore":    {"# ", ""},
+			".dockerignore": {"# ", ""},
+			"Dockerfile":    {"# ", ""}, // file names are looked up before extensions
+			"Makefile":      {"# ", ""},
+			".yaml":         {"# ", ""},
+			".yml":          {"# ", ""},
+			".toml":         {"# ", ""},
+			".xml":          {"<!-- ", " -->"},
+			".html":         {"<!-- ", " -->"},
+			".md":           {"<!-- ", " -->"},
+			".mod":          {"// ", ""}, // go.mod files use Go-style comments
+			".work":         {"// ", ""}, // go.work files use Go-style comments
+			".sum":          {"// ", ""}, // go.sum files use Go-style comments
+			".go":           {"// ", ""}, // Go files
 		},
 	}
 
@@ -60,6 +142,10 @@ func newContentGenerator(e env.Environment) *DefaultContentGenerator {
 	gen.RegisterGenerator("go.mod", gen.generateGoMod)
 	gen.RegisterGenerator("go.work", gen.generateGoWork)
 	gen.RegisterGenerator("go.sum", gen.generateGoSum)
+	gen.RegisterGenerator(".env", gen.generateEnv)
+	gen.RegisterGenerator(".editorconfig", DotfileGenerator(editorConfigDefaults))
+	gen.RegisterGenerator(".gitattributes", DotfileGenerator(gitattributesDefaults))
+	gen.RegisterGenerator(".dockerignore", DotfileGenerator(dockerignoreDefaults))
 
 	return gen
 }
@@ -69,10 +155,44 @@ func (g *DefaultContentGenerator) RegisterGenerator(extOrName string, generator
 	g.generators[extOrName] = generator
 }
 
+// AliasExtension makes files with extension from use the generator and
+// comment syntax registered for extension to, e.g. ".mjs" -> ".js".
+func (g *DefaultContentGenerator) AliasExtension(from, to string) {
+	g.extAliases[from] = to
+}
+
+// SetCommentSyntax makes the default generator write comments for files with
+// extension ext as prefix+comment+suffix, e.g. (".lua", "-- ", "") or
+// (".ml", "(* ", " *)"). It replaces any syntax already set for ext.
+func (g *DefaultContentGenerator) SetCommentSyntax(ext, prefix, suffix string) {
+	g.commentSyntax[ext] = struct{ prefix, suffix string }{prefix, suffix}
+}
+
+// SetFallbackCommentSyntax sets the comment style for files whose name and
+// extension have no syntax of their own. By default they get no comment, since
+// any guess (such as "# ") breaks some file types.
+func (g *DefaultContentGenerator) SetFallbackCommentSyntax(prefix, suffix string) {
+	g.fallbackSyntax = struct{ prefix, suffix string }{prefix, suffix}
+}
+
+// extOf returns relPath's lowercased extension with any alias applied, so
+// "MAIN.GO" is treated like "main.go"
+func (g *DefaultContentGenerator) extOf(relPath string) string {
+	ext := strings.ToLower(filepath.Ext(relPath))
+	if alias, ok := g.extAliases[ext]; ok {
+		return alias
+	}
+	return ext
+}
+
 // GenerateContent creates content for a file based on its path and comment
 func (g *DefaultContentGenerator) GenerateContent(relPath, comment string) string {
 	fileName := filepath.Base(relPath)
-	ext := filepath.Ext(relPath)
+	ext := g.extOf(relPath)
+
+	if comment == "" && g.CommentFromFilename {
+		comment = commentFromFilename(fileName)
+	}
 
 	// Check for specific filename generator first (e.g., "go.mod")
 	if generator, ok := g.generators[fileName]; ok {
@@ -88,16 +208,44 @@ func (g *DefaultContentGenerator) GenerateContent(relPath, comment string) strin
 	return g.defaultGenerator(relPath, comment)
 }
 
+// Preview returns the content GenerateContent would produce for a single file.
+// It lets tools show a file's stub without building nodes or a scaffolder.
+func (g *DefaultContentGenerator) Preview(path, comment string) string {
+	return g.GenerateContent(path, comment)
+}
+
+// PreviewFile returns the content the default generator would write to path.
+func PreviewFile(path, comment string) string {
+	return NewDefaultContentGenerator().Preview(path, comment)
+}
+
+// commentFromFilename turns a file name into words: the extension is dropped
+// and underscores, dashes and dots become spaces
+func commentFromFilename(name string) string {
+	stem := strings.TrimSuffix(name, filepath.Ext(name))
+	if stem == "" {
+		stem = name // dotfiles such as .gitignore
+	}
+	return strings.Join(strings.FieldsFunc(stem, func(r rune) bool {
+		return r == '_' || r == '-' || r == '.' || r == ' '
+	}), " ")
+}
+
 // defaultGenerator emits only the comment header in the right syntax.
 func (g *DefaultContentGenerator) defaultGenerator(relPath, comment string) string {
 	if comment == "" {
 		return ""
 	}
 
-	ext := filepath.Ext(relPath)
-	syn, ok := g.commentSyntax[ext]
+	syn, ok := g.commentSyntax[filepath.Base(relPath)]
+	if !ok {
+		syn, ok = g.commentSyntax[g.extOf(relPath)]
+	}
 	if !ok {
-		syn = g.commentSyntax[".sh"] // fallback to shell-style comments
+		syn = g.fallbackSyntax
+	}
+	if syn.prefix == "" && syn.suffix == "" {
+		return ""
 	}
 
 	if syn.suffix != "" {
@@ -106,30 +254,232 @@ func (g *DefaultContentGenerator) defaultGenerator(relPath, comment string) stri
 	return fmt.Sprintf("%s%s\n", syn.prefix, comment)
 }
 
-// generateGo produces the package stub for .go files.
+// GenerateNodeContent honors the @package-doc directive on .go files by
+// writing the comment as the package's doc comment, and is GenerateContent
+// otherwise
+func (g *DefaultContentGenerator) GenerateNodeContent(n parser.Node, comment string) string {
+	if _, ok := n.Attrs["package-doc"]; ok && comment != "" && g.extOf(n.Path) == ".go" {
+		return g.goFile(n.Path, comment, true)
+	}
+	return g.GenerateContent(n.Path, comment)
+}
+
+// generateGo produces the package stub for .go files, led by a //go:build
+// line when BuildTags is set and the file name implies one.
 func (g *DefaultContentGenerator) generateGo(relPath, comment string) string {
-	pkg := inferPkg(relPath)
+	return g.goFile(relPath, comment, false)
+}
+
+// goFile is generateGo, writing comment as the package doc when doc is set
+func (g *DefaultContentGenerator) goFile(relPath, comment string, doc bool) string {
+	stub := g.goStub(relPath, comment, doc)
+	if !g.BuildTags {
+		return stub
+	}
+	if expr := buildConstraint(filepath.Base(relPath)); expr != "" {
+		return "//go:build " + expr + "\n\n" + stub
+	}
+	return stub
+}
+
+// goStub is the package clause and TODO body of a .go file, led by comment:
+// as the package doc when doc is set, else as a detached header
+func (g *DefaultContentGenerator) goStub(relPath, comment string, doc bool) string {
+	pkg := g.inferPkg(relPath)
 	name := filepath.Base(relPath)
 
-	// Check if this is a main.go file - special handling for main.go
-	if name == "main.go" {
-		if comment != "" {
-			return fmt.Sprintf("// %s\n\npackage main\n\nfunc main() {\n    // TODO: implement %s\n}\n", comment, name)
+	header := ""
+	switch {
+	case comment == "":
+	case doc:
+		header = packageDoc(pkg, comment)
+	default:
+		header = fmt.Sprintf("// %s\n\n", comment)
+	}
+
+	// Check if this is a command's main.go file - special handling for main.go
+	if g.isMainGo(relPath) && pkg == "main" {
+		return fmt.Sprintf("%spackage main\n\nfunc main() {\n    // TODO: implement %s\n}\n", header, name)
+	}
+
+	// Test files get a test function to fill in
+	if strings.HasSuffix(name, "_test.go") {
+		imports := "import \"testing\""
+		if g.externalTest(name, pkg) {
+			// The stub has no symbol to reference yet, so the import is blank
+			// to keep the test compiling until one is written
+			imports = fmt.Sprintf("import (\n    \"testing\"\n\n    _ %q\n)", g.importPath(relPath))
+			pkg += "_test"
+		}
+		fn, ok := g.testFuncs[filepath.Clean(relPath)]
+		if !ok {
+			fn = testFuncName(name)
 		}
-		return fmt.Sprintf("package main\n\nfunc main() {\n    // TODO: implement %s\n}\n", name)
+		return fmt.Sprintf("%spackage %s\n\n%s\n\nfunc %s(t *testing.T) {\n    // TODO: implement %s\n}\n",
+			header, pkg, imports, fn, name)
 	}
 
 	// Regular .go file handling
-	if comment != "" {
-		return fmt.Sprintf("// %s\n\npackage %s\n\n// TODO: implement %s\n", comment, pkg, name)
+	return fmt.Sprintf("%spackage %s\n\n// TODO: implement %s\n", header, pkg, name)
+}
+
+// externalTest reports whether the test file name in package pkg is written
+// as an external test, which needs ModulePath to import its package
+func (g *DefaultContentGenerator) externalTest(name, pkg string) bool {
+	return strings.HasSuffix(name, "_test.go") && g.ModulePath != "" && pkg != "main"
+}
+
+// PlanSpec notes the directories of nodes whose main.go is package main, so
+// their other Go files get package main too and the directory compiles, and
+// numbers test functions whose names would clash within a directory, as
+// TestFooBar for foo_bar_test.go and TestFooBar2 for foo-bar_test.go
+func (g *DefaultContentGenerator) PlanSpec(nodes []parser.Node) {
+	g.mainDirs, g.testFuncs = nil, nil
+	mainDirs := make(map[string]bool)
+	for _, n := range nodes {
+		if !n.IsDir && n.LinkTarget == "" && g.isMainGo(n.Path) && g.inferPkg(n.Path) == "main" {
+			mainDirs[filepath.Dir(n.Path)] = true
+		}
+	}
+
+	testFuncs := make(map[string]string)
+	taken := make(map[[2]string]bool) // directory and function name
+	for _, n := range nodes {
+		name := filepath.Base(n.Path)
+		if n.IsDir || n.LinkTarget != "" || !strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		dir, base := filepath.Dir(n.Path), testFuncName(name)
+		fn := base
+		for i := 2; taken[[2]string{dir, fn}]; i++ {
+			fn = fmt.Sprintf("%s%d", base, i)
+		}
+		taken[[2]string{dir, fn}] = true
+		testFuncs[filepath.Clean(n.Path)] = fn
+	}
+	g.mainDirs, g.testFuncs = mainDirs, testFuncs
+}
+
+// PackageName returns the package the stub of the Go file at relPath
+// declares, e.g. "main" for cmd/app/run.go, or "" when relPath is not a Go
+// file
+func (g *DefaultContentGenerator) PackageName(relPath string) string {
+	if g.extOf(relPath) != ".go" {
+		return ""
+	}
+	pkg := g.inferPkg(relPath)
+	if g.externalTest(filepath.Base(relPath), pkg) {
+		pkg += "_test"
+	}
+	return pkg
+}
+
+// packageDoc renders comment as the doc comment of package pkg, following
+// the "Package name" convention outside package main
+func packageDoc(pkg, comment string) string {
+	if pkg == "main" || strings.HasPrefix(comment, "Package "+pkg) {
+		return fmt.Sprintf("// %s\n", comment)
+	}
+	return fmt.Sprintf("// Package %s: %s\n", pkg, comment)
+}
+
+// importPath is the import path of the package relPath belongs to: ModulePath
+// followed by the file's directory
+func (g *DefaultContentGenerator) importPath(relPath string) string {
+	dir := filepath.ToSlash(filepath.Dir(relPath))
+	if dir == "." {
+		return g.ModulePath
+	}
+	return strings.TrimSuffix(g.ModulePath, "/") + "/" + dir
+}
+
+// testFuncName derives a test function name from a test file name, e.g.
+// "user_service_test.go" becomes "TestUserService". Characters that cannot
+// be part of an identifier separate words, and each word's first letter is
+// upper-cased whatever its encoded width, so "émoji_test.go" gives
+// "TestÉmoji".
+func testFuncName(fileName string) string {
+	words := strings.FieldsFunc(strings.TrimSuffix(fileName, "_test.go"), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	b.WriteString("Test")
+	for _, w := range words {
+		r, size := utf8.DecodeRuneInString(w)
+		b.WriteRune(unicode.ToUpper(r))
+		b.WriteString(w[size:])
+	}
+	return b.String()
+}
+
+// envNameRe matches an environment variable name such as DB_URL
+var envNameRe = regexp.MustCompile(`^[A-Z][A-Z0-9]*(_[A-Z0-9]+)*$`)
+
+// generateEnv writes the comment and a "NAME=" placeholder for every
+// variable name listed in it, e.g. "DB_URL, API_KEY"
+func (g *DefaultContentGenerator) generateEnv(relPath, comment string) string {
+	if comment == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", comment)
+	seen := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(comment, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\t'
+	}) {
+		word = strings.Trim(word, ".:()[]")
+		if len(word) > 1 && envNameRe.MatchString(word) && !seen[word] {
+			seen[word] = true
+			fmt.Fprintf(&b, "%s=\n", word)
+		}
+	}
+	return b.String()
+}
+
+// Default bodies of the dotfiles DotfileGenerator writes
+const (
+	editorConfigDefaults = `root = true
+
+[*]
+charset = utf-8
+end_of_line = lf
+insert_final_newline = true
+trim_trailing_whitespace = true
+indent_style = space
+indent_size = 4
+
+[{Makefile,*.go}]
+indent_style = tab
+`
+	gitattributesDefaults = `* text=auto
+`
+	dockerignoreDefaults = `.git
+node_modules
+*.log
+.env
+`
+)
+
+// DotfileGenerator returns a generator for a "#"-commented dotfile such as
+// .editorconfig that writes body under the comment, so the file works as is
+func DotfileGenerator(body string) FileGenerator {
+	return func(relPath, comment string) string {
+		if comment == "" {
+			return body
+		}
+		return fmt.Sprintf("# %s\n\n%s", comment, body)
 	}
-	return fmt.Sprintf("package %s\n\n// TODO: implement %s\n", pkg, name)
 }
 
 // generateGoMod creates a go.mod file with the host Go version (falling back to a
-// default when the toolchain cannot be probed, e.g. under WASI).
+// default when the toolchain cannot be probed, e.g. under WASI). Its module is
+// ModulePath when set, so stub imports resolve, else an inferred name.
 func (g *DefaultContentGenerator) generateGoMod(relPath, comment string) string {
 	moduleName := g.inferModuleName(relPath)
+	if g.ModulePath != "" {
+		moduleName = g.importPath(relPath)
+	}
 	goVersion := g.goVersion()
 
 	if comment != "" {
@@ -148,18 +498,54 @@ func (g *DefaultContentGenerator) generateGoWork(relPath, comment string) string
 	return fmt.Sprintf("go %s\n\nuse (\n    // Add your module directories here\n    // .\n)\n", goVersion)
 }
 
-// generateGoSum creates a placeholder go.sum file.
+// GoSumMode selects how go.sum files are scaffolded
+type GoSumMode int
+
+const (
+	// GoSumEmpty creates an empty go.sum (the default)
+	GoSumEmpty GoSumMode = iota
+	// GoSumSkip leaves go.sum out; see SkipGoSum
+	GoSumSkip
+	// GoSumComment writes the spec's comment and a placeholder note, which
+	// the go command rejects as malformed until the file is regenerated
+	GoSumComment
+)
+
+// ParseGoSumMode maps a command-line value ("empty", "skip", "comment") to a
+// GoSumMode
+func ParseGoSumMode(s string) (GoSumMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "empty":
+		return GoSumEmpty, nil
+	case "skip":
+		return GoSumSkip, nil
+	case "comment":
+		return GoSumComment, nil
+	default:
+		return GoSumEmpty, fmt.Errorf("unknown go.sum mode %q (want empty, skip or comment)", s)
+	}
+}
+
+// generateGoSum creates a go.sum file: empty, since it may hold nothing but
+// checksum lines, or a commented placeholder under GoSumComment
 func (g *DefaultContentGenerator) generateGoSum(relPath, comment string) string {
+	if g.GoSum != GoSumComment {
+		return ""
+	}
 	if comment != "" {
 		return fmt.Sprintf("// %s\n// This file will be automatically populated when dependencies are added to go.mod\n", comment)
 	}
 	return "// This file will be automatically populated when dependencies are added to go.mod\n"
 }
 
-// goVersion returns the host Go major.minor, falling back to a sane default when
-// the toolchain cannot be probed (e.g. exec is unavailable under WASI).
+// goVersion returns GoVersion when set, else the host Go major.minor, falling
+// back to a sane default when the toolchain cannot be probed (e.g. exec is
+// unavailable under WASI).
 func (g *DefaultContentGenerator) goVersion() string {
 	const fallback = "1.24"
+	if g.GoVersion != "" {
+		return g.GoVersion
+	}
 	if v, err := g.env.GoVersion(); err == nil && v != "" {
 		return v
 	}
@@ -167,18 +553,34 @@ func (g *DefaultContentGenerator) goVersion() string {
 }
 
 // inferPkg derives the Go package name from relPath.
-// Files named main.go get package main; otherwise use the name of the parent directory.
-func inferPkg(relPath string) string {
+// Top-level files and files in a top-level command directory (cmd/<name>/)
+// get package main, as does main.go anywhere outside a library tree
+// (internal/ or pkg/) and, after PlanSpec, every file next to such a main.go.
+// Everything else uses the name of the parent directory.
+func (g *DefaultContentGenerator) inferPkg(relPath string) string {
 	dirPath := filepath.Dir(relPath)
-	fileName := filepath.Base(relPath)
 
-	// main.go files should always be package main
-	if fileName == "main.go" {
+	// A package main main.go makes its whole directory package main
+	if g.mainDirs[dirPath] {
 		return "main"
 	}
 
-	// top-level files (Dir == ".") get main package
+	// top-level files (Dir == ".") get main package, unless the root is a
+	// library with its own package name
 	if dirPath == "." {
+		if g.RootPackage != "" && !g.isMainGo(relPath) {
+			return g.RootPackage
+		}
+		return "main"
+	}
+
+	// Go command packages live in cmd/<name>/, so every file there is main
+	if isCommandDir(dirPath) {
+		return "main"
+	}
+
+	// main.go is a program entry point unless it sits inside library code
+	if g.isMainGo(relPath) && (g.MainEverywhere || !inLibraryTree(dirPath)) {
 		return "main"
 	}
 
@@ -186,6 +588,29 @@ func inferPkg(relPath string) string {
 	return filepath.Base(dirPath)
 }
 
+// isMainGo reports whether relPath names a main.go, compared on the
+// normalized name so "MAIN.GO" counts too
+func (g *DefaultContentGenerator) isMainGo(relPath string) bool {
+	name := filepath.Base(relPath)
+	return g.extOf(relPath) == ".go" && strings.EqualFold(strings.TrimSuffix(name, filepath.Ext(name)), "main")
+}
+
+// isCommandDir reports whether dir is a top-level command directory like
+// cmd/app. Deeper cmd/ trees such as pkg/cmd/get hold library packages.
+func isCommandDir(dir string) bool {
+	return filepath.ToSlash(filepath.Dir(dir)) == "cmd"
+}
+
+// inLibraryTree reports whether dir is inside an internal/ or pkg/ tree
+func inLibraryTree(dir string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(dir), "/") {
+		if part == "internal" || part == "pkg" {
+			return true
+		}
+	}
+	return false
+}
+
 // inferModuleName derives a Go module name from the relative path of a go.mod file.
 // This is a best-effort guess based on common conventions. The VCS remote and
 // working directory are read through the injected environment, so it degrades to
@@ -224,3 +649,111 @@ func (g *DefaultContentGenerator) inferModuleName(relPath string) string {
 	// This is a simple implementation and might need to be customized
 	return "example.com/" + dir
 }
+
+// CommandRunner executes an external program with args and stdin and returns
+// its standard output.
+type CommandRunner func(name string, args []string, stdin []byte) ([]byte, error)
+
+// ExternalGenerator delegates content generation for selected extensions or
+// file names to external commands, and hands every other file to Fallback.
+// Each command is invoked as `<command...> <relPath> <comment>` with the
+// comment also written to its stdin; its stdout becomes the file content.
+type ExternalGenerator struct {
+	// Fallback generates content for files without a registered command, and
+	// for files whose command fails.
+	Fallback ContentGenerator
+
+	// Run executes the commands. It defaults to the host environment, which
+	// reports env.ErrUnsupported under WASI; tests can inject a fake runner.
+	Run CommandRunner
+
+	// Log receives a note when a command fails; nil means os.Stderr
+	Log io.Writer
+
+	commands map[string][]string
+}
+
+// NewExternalGenerator creates an external generator wrapping fallback
+func NewExternalGenerator(fallback ContentGenerator) *ExternalGenerator {
+	return &ExternalGenerator{
+		Fallback: fallback,
+		Run:      env.New().Run,
+		commands: make(map[string][]string),
+	}
+}
+
+// RegisterCommand routes files matching extOrName to command. The command is
+// split on whitespace, so it may carry its own leading arguments.
+func (g *ExternalGenerator) RegisterCommand(extOrName, command string) error {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return fmt.Errorf("empty generator command for %s", extOrName)
+	}
+	g.commands[extOrName] = fields
+	return nil
+}
+
+// PlanSpec hands the spec to the fallback when it plans
+func (g *ExternalGenerator) PlanSpec(nodes []parser.Node) {
+	if p, ok := g.Fallback.(SpecPlanner); ok {
+		p.PlanSpec(nodes)
+	}
+}
+
+// RegisterGenerator registers an in-process generator on the fallback
+func (g *ExternalGenerator) RegisterGenerator(extOrName string, generator FileGenerator) {
+	g.Fallback.RegisterGenerator(extOrName, generator)
+}
+
+// GenerateNodeContent runs the command registered for n's path, and
+// otherwise hands the whole node to a node-aware fallback
+func (g *ExternalGenerator) GenerateNodeContent(n parser.Node, comment string) string {
+	content, err := g.TryGenerateContent(n, comment)
+	if err != nil {
+		notef(g.Log, "%v; using default content", err)
+	}
+	return content
+}
+
+// TryGenerateContent is GenerateNodeContent returning a failed command as an
+// error, along with the fallback's content for the file
+func (g *ExternalGenerator) TryGenerateContent(n parser.Node, comment string) (string, error) {
+	if _, ok := g.command(n.Path); !ok {
+		return tryGenerate(g.Fallback, n, comment)
+	}
+	return g.run(n.Path, comment)
+}
+
+// command returns the command registered for relPath's file name or extension
+func (g *ExternalGenerator) command(relPath string) ([]string, bool) {
+	command, ok := g.commands[filepath.Base(relPath)]
+	if !ok {
+		command, ok = g.commands[filepath.Ext(relPath)]
+	}
+	return command, ok
+}
+
+// GenerateContent runs the command registered for relPath, preferring a file
+// name match over an extension match, and falls back when none applies.
+func (g *ExternalGenerator) GenerateContent(relPath, comment string) string {
+	if _, ok := g.command(relPath); !ok {
+		return g.Fallback.GenerateContent(relPath, comment)
+	}
+	content, err := g.run(relPath, comment)
+	if err != nil {
+		notef(g.Log, "%v; using default content", err)
+	}
+	return content
+}
+
+// run executes the command registered for relPath. On failure it returns the
+// fallback's content with the error.
+func (g *ExternalGenerator) run(relPath, comment string) (string, error) {
+	command, _ := g.command(relPath)
+	args := append(append([]string{}, command[1:]...), relPath, comment)
+	out, err := g.Run(command[0], args, []byte(comment))
+	if err != nil {
+		return g.Fallback.GenerateContent(relPath, comment), fmt.Errorf("generator %s failed for %s: %w", command[0], relPath, err)
+	}
+	return string(out), nil
+}
@@ -0,0 +1,707 @@
+package scaffold_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lancekrogers/tree2scaffold/pkg/parser"
+	"github.com/lancekrogers/tree2scaffold/pkg/scaffold"
+)
+
+func TestExternalGenerator(t *testing.T) {
+	gen := scaffold.NewExternalGenerator(scaffold.NewDefaultContentGenerator())
+	if err := gen.RegisterCommand(".rb", "ruby-scaffold-gen --style=minimal"); err != nil {
+		t.Fatalf("RegisterCommand() error = %v", err)
+	}
+
+	var gotName string
+	var gotArgs []string
+	var gotStdin string
+	gen.Run = func(name string, args []string, stdin []byte) ([]byte, error) {
+		gotName, gotArgs, gotStdin = name, args, string(stdin)
+		return []byte(fmt.Sprintf("# generated for %s\n", args[len(args)-2])), nil
+	}
+
+	content := gen.GenerateContent("lib/app.rb", "application entry")
+	if content != "# generated for lib/app.rb\n" {
+		t.Errorf("GenerateContent() = %q", content)
+	}
+	if gotName != "ruby-scaffold-gen" {
+		t.Errorf("runner called with %q, want ruby-scaffold-gen", gotName)
+	}
+	wantArgs := []string{"--style=minimal", "lib/app.rb", "application entry"}
+	if strings.Join(gotArgs, "|") != strings.Join(wantArgs, "|") {
+		t.Errorf("runner args = %q, want %q", gotArgs, wantArgs)
+	}
+	if gotStdin != "application entry" {
+		t.Errorf("runner stdin = %q, want the comment", gotStdin)
+	}
+
+	// Unregistered extensions use the fallback generator
+	if got := gen.GenerateContent("lib/util.py", "helpers"); got != "# helpers\n" {
+		t.Errorf("fallback content = %q, want %q", got, "# helpers\n")
+	}
+
+	// A failing command also falls back rather than producing an empty file
+	gen.Run = func(string, []string, []byte) ([]byte, error) {
+		return nil, errors.New("boom")
+	}
+	if got := gen.GenerateContent("lib/app.rb", "entry"); got != "# entry\n" {
+		t.Errorf("content after failure = %q, want fallback", got)
+	}
+}
+
+func TestPreviewFile(t *testing.T) {
+	goContent := scaffold.PreviewFile("internal/store/store.go", "storage layer")
+	if !strings.Contains(goContent, "// storage layer") || !strings.Contains(goContent, "package store") {
+		t.Errorf("PreviewFile(.go) = %q", goContent)
+	}
+
+	if got := scaffold.PreviewFile("tools/build.py", "build helper"); got != "# build helper\n" {
+		t.Errorf("PreviewFile(.py) = %q, want %q", got, "# build helper\n")
+	}
+
+	gen := scaffold.NewDefaultContentGenerator()
+	if gen.Preview("a/b.go", "x") != gen.GenerateContent("a/b.go", "x") {
+		t.Error("Preview() differs from GenerateContent()")
+	}
+}
+
+func TestGoPackagePlacement(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		mainEverywhere bool
+		rootPackage    string
+		wantPkg        string
+		wantMainFunc   bool
+	}{
+		{"root main.go", "main.go", false, "", "package main", true},
+		{"command main.go", "cmd/app/main.go", false, "", "package main", true},
+		{"command sibling", "cmd/app/app.go", false, "", "package main", false},
+		{"command helper", "cmd/app/helpers.go", false, "", "package main", false},
+		{"nested command helper", "tools/cmd/migrate/flags.go", false, "", "package migrate", false},
+		{"library cmd package", "pkg/cmd/get/get.go", false, "", "package get", false},
+		{"internal cmd package", "internal/cmd/root/root.go", false, "", "package root", false},
+		{"command helper with -root-package", "cmd/app/helpers.go", false, "myapp", "package main", false},
+		{"cmd main.go", "cmd/main.go", false, "", "package main", true},
+		{"service main.go", "server/main.go", false, "", "package main", true},
+		{"upper-case command main.go", "cmd/app/MAIN.GO", false, "", "package main", true},
+		{"upper-case service main.go", "server/Main.go", false, "", "package main", true},
+		{"upper-case root main.go with -root-package", "MAIN.GO", false, "myapp", "package main", true},
+		{"library main.go", "internal/worker/main.go", false, "", "package worker", false},
+		{"pkg main.go", "pkg/runner/main.go", false, "", "package runner", false},
+		{"library main.go with -main-everywhere", "internal/worker/main.go", true, "", "package main", true},
+		{"library file", "internal/worker/queue.go", false, "", "package worker", false},
+		{"root file with -root-package", "util.go", false, "myapp", "package myapp", false},
+		{"root main.go with -root-package", "main.go", false, "myapp", "package main", true},
+		{"nested file with -root-package", "internal/worker/queue.go", false, "myapp", "package worker", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gen := scaffold.NewDefaultContentGenerator()
+			gen.MainEverywhere = tt.mainEverywhere
+			gen.RootPackage = tt.rootPackage
+			content := gen.GenerateContent(tt.path, "")
+			if !strings.Contains(content, tt.wantPkg+"\n") {
+				t.Errorf("GenerateContent(%q) = %q, want %q", tt.path, content, tt.wantPkg)
+			}
+			if got := strings.Contains(content, "func main()"); got != tt.wantMainFunc {
+				t.Errorf("GenerateContent(%q) has func main() = %v, want %v", tt.path, got, tt.wantMainFunc)
+			}
+		})
+	}
+}
+
+func TestExternalTestImports(t *testing.T) {
+	gen := scaffold.NewDefaultContentGenerator()
+	gen.ModulePath = "github.com/me/app"
+	gen.RootPackage = "app"
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"internal/util/strings_test.go", "package util_test\n\nimport (\n    \"testing\"\n\n    _ \"github.com/me/app/internal/util\"\n)\n\nfunc TestStrings(t *testing.T) {"},
+		{"client_test.go", "package app_test\n\nimport (\n    \"testing\"\n\n    _ \"github.com/me/app\"\n)\n"},
+		// Commands cannot be imported, so their tests stay internal
+		{"cmd/tool/flags_test.go", "package main\n\nimport \"testing\"\n"},
+	}
+	for _, tt := range tests {
+		if got := gen.GenerateContent(tt.path, ""); !strings.HasPrefix(got, tt.want) {
+			t.Errorf("GenerateContent(%q) = %q, want it to start with %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestTestFuncNames(t *testing.T) {
+	gen := scaffold.NewDefaultContentGenerator()
+	for path, want := range map[string]string{
+		"util/user_service_test.go": "func TestUserService(t",
+		"util/émoji_test.go":        "func TestÉmoji(t",
+		"util/v1.2+beta_test.go":    "func TestV12Beta(t",
+	} {
+		if got := gen.GenerateContent(path, ""); !strings.Contains(got, want) {
+			t.Errorf("GenerateContent(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestExternalTestCompiles(t *testing.T) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	gen := scaffold.NewDefaultContentGenerator()
+	gen.ModulePath = "example.com/app"
+
+	dir := t.TempDir()
+	for _, rel := range []string{"go.mod", "internal/util/util.go", "internal/util/util_test.go"} {
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(gen.GenerateContent(rel, "")), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if mod, _ := os.ReadFile(filepath.Join(dir, "go.mod")); !strings.HasPrefix(string(mod), "module example.com/app\n") {
+		t.Errorf("go.mod = %q, want module example.com/app", mod)
+	}
+
+	goVet(t, goBin, dir)
+}
+
+func TestMainPackageDirsCompile(t *testing.T) {
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available")
+	}
+
+	nodes := []parser.Node{
+		{Path: "go.mod"},
+		{Path: "server/main.go"},
+		{Path: "server/handler.go"},
+		{Path: "server/foo_bar_test.go"},
+		{Path: "server/foo-bar_test.go"},
+		{Path: "cmd/main.go"},
+		{Path: "cmd/util.go"},
+		{Path: "worker/Main.go"},
+		{Path: "worker/jobs.go"},
+		{Path: "tools/cmd/migrate/main.go"},
+		{Path: "tools/cmd/migrate/flags.go"},
+		{Path: "internal/worker/main.go"},
+		{Path: "internal/worker/queue.go"},
+	}
+	gen := scaffold.NewDefaultContentGenerator()
+	gen.ModulePath = "example.com/app"
+	s := scaffold.NewScaffolder()
+	if err := s.SetContentGenerator(gen); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := s.Apply(dir, nodes, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	for path, want := range map[string]string{
+		"server/handler.go":          "package main\n",
+		"cmd/util.go":                "package main\n",
+		"worker/jobs.go":             "package main\n",
+		"tools/cmd/migrate/flags.go": "package main\n",
+		"internal/worker/main.go":    "package worker\n",
+		"server/foo_bar_test.go":     "func TestFooBar(t",
+		"server/foo-bar_test.go":     "func TestFooBar2(t",
+	} {
+		if data, err := os.ReadFile(filepath.Join(dir, path)); err != nil || !strings.Contains(string(data), want) {
+			t.Errorf("%s = %q, %v; want %q", path, data, err, want)
+		}
+	}
+	goVet(t, goBin, dir)
+}
+
+// goVet runs go vet on the module at dir, failing t on any complaint
+func goVet(t *testing.T, goBin, dir string) {
+	t.Helper()
+	cmd := exec.Command(goBin, "vet", "./...")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOWORK=off", "GOFLAGS=-mod=mod", "GOTOOLCHAIN=local")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("go vet on the generated packages failed: %v\n%s", err, out)
+	}
+}
+
+func TestUppercaseExtensions(t *testing.T) {
+	gen := scaffold.NewDefaultContentGenerator()
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"internal/app/MAIN.GO", "// entry\n\npackage app\n"},
+		{"scripts/Setup.PY", "# entry\n"},
+	}
+	for _, tt := range tests {
+		if got := gen.GenerateContent(tt.path, "entry"); !strings.HasPrefix(got, tt.want) {
+			t.Errorf("GenerateContent(%q) = %q, want it to start with %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestBuildTags(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"foo_windows.go", "//go:build windows\n\npackage main\n"},
+		{"internal/cpu/bar_amd64.go", "//go:build amd64\n\npackage cpu\n"},
+		{"internal/poll/fd_linux_arm64.go", "//go:build linux && arm64\n\npackage poll\n"},
+		{"internal/poll/fd_darwin_test.go", "//go:build darwin\n\npackage poll\n"},
+		{"internal/poll/windows.go", "package poll\n"},
+		{"internal/poll/fd_unix.go", "package poll\n"},
+	}
+
+	gen := scaffold.NewDefaultContentGenerator()
+	gen.BuildTags = true
+	for _, tt := range tests {
+		if got := gen.GenerateContent(tt.path, ""); !strings.HasPrefix(got, tt.want) {
+			t.Errorf("GenerateContent(%q) = %q, want it to start with %q", tt.path, got, tt.want)
+		}
+	}
+
+	// Off by default: the suffix alone constrains the build
+	if got := scaffold.NewDefaultContentGenerator().GenerateContent("foo_windows.go", ""); strings.Contains(got, "go:build") {
+		t.Errorf("GenerateContent() without BuildTags = %q", got)
+	}
+}
+
+func TestSetCommentSyntax(t *testing.T) {
+	gen := scaffold.NewDefaultContentGenerator()
+	gen.SetCommentSyntax(".lua", "-- ", "")
+	gen.SetCommentSyntax(".ml", "(* ", " *)")
+	gen.SetCommentSyntax(".md", "[//]: # (", ")")
+
+	tests := map[string]string{
+		"game/main.lua": "-- entry point\n",
+		"lib/parse.ml":  "(* entry point *)\n",
+		"docs/intro.md": "[//]: # (entry point)\n",
+	}
+	for path, want := range tests {
+		if got := gen.GenerateContent(path, "entry point"); got != want {
+			t.Errorf("GenerateContent(%q) = %q, want %q", path, got, want)
+		}
+	}
+
+	// Aliases pick up the new syntax too
+	gen.AliasExtension(".luau", ".lua")
+	if got := gen.GenerateContent("game/ui.luau", "hud"); got != "-- hud\n" {
+		t.Errorf("aliased .luau = %q, want Lua-style comment", got)
+	}
+}
+
+func TestFallbackCommentSyntax(t *testing.T) {
+	gen := scaffold.NewDefaultContentGenerator()
+
+	tests := map[string]string{
+		"game/main.lua":   "-- entry\n",
+		"db/schema.sql":   "-- entry\n",
+		"Dockerfile":      "# entry\n",
+		"build/Makefile":  "# entry\n",
+		"config.json":     "",
+		"assets/data.bin": "",
+	}
+	for path, want := range tests {
+		if got := gen.GenerateContent(path, "entry"); got != want {
+			t.Errorf("GenerateContent(%q) = %q, want %q", path, got, want)
+		}
+	}
+
+	// A registered syntax wins over the fallback
+	gen.SetFallbackCommentSyntax("# ", "")
+	if got := gen.GenerateContent("game/main.lua", "entry"); strings.Contains(got, "#") {
+		t.Errorf("GenerateContent(.lua) = %q, want no # comment", got)
+	}
+	if got := gen.GenerateContent("assets/data.bin", "entry"); got != "# entry\n" {
+		t.Errorf("GenerateContent(.bin) with a fallback = %q", got)
+	}
+}
+
+func TestAliasExtension(t *testing.T) {
+	gen := scaffold.NewDefaultContentGenerator()
+
+	// Unknown extensions get no comment
+	if got := gen.GenerateContent("web/app.es6", "entry"); got != "" {
+		t.Fatalf("unaliased .es6 = %q", got)
+	}
+
+	gen.AliasExtension(".es6", ".js")
+	gen.AliasExtension(".gotmpl", ".go")
+
+	if got := gen.GenerateContent("web/app.es6", "entry"); got != "// entry\n" {
+		t.Errorf("aliased .es6 = %q, want JS-style comment", got)
+	}
+	if got := gen.GenerateContent("tmpl/page.gotmpl", "page"); !strings.Contains(got, "package tmpl") {
+		t.Errorf("aliased .gotmpl = %q, want a Go stub", got)
+	}
+}
+
+func TestCommentFromFilename(t *testing.T) {
+	nodes := []parser.Node{
+		{Path: "services/", IsDir: true},
+		{Path: "services/user_service.go", IsDir: false},
+		{Path: "services/rate-limit.py", IsDir: false},
+		{Path: "services/auth.go", IsDir: false, Comment: "explicit"},
+	}
+
+	gen := scaffold.NewDefaultContentGenerator()
+	gen.CommentFromFilename = true
+	s := scaffold.NewScaffolder()
+	s.ContentProvider = gen
+
+	root := t.TempDir()
+	if err := s.Apply(root, nodes, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	want := map[string]string{
+		"user_service.go": "// user service\n",
+		"rate-limit.py":   "# rate limit\n",
+		"auth.go":         "// explicit\n",
+	}
+	for name, prefix := range want {
+		data, err := os.ReadFile(filepath.Join(root, "services", name))
+		if err != nil {
+			t.Fatalf("ReadFile(%s) error = %v", name, err)
+		}
+		if !strings.HasPrefix(string(data), prefix) {
+			t.Errorf("%s = %q, want it to start with %q", name, data, prefix)
+		}
+	}
+
+	// Off by default
+	if got := scaffold.PreviewFile("user_service.py", ""); got != "" {
+		t.Errorf("default generator invented a comment: %q", got)
+	}
+}
+
+func TestTemplateGenerator(t *testing.T) {
+	dir := t.TempDir()
+	templates := map[string]string{
+		"go.tmpl":           "// {{.Comment}}\npackage {{.Base}} // by {{.Vars.author}}\n",
+		"grpc-service.tmpl": "// {{.Comment}}\n// grpc service {{.Name}}\n",
+		"notes.txt":         "not a template",
+	}
+	for name, text := range templates {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var log strings.Builder
+	gen := scaffold.NewTemplateGenerator(scaffold.NewDefaultContentGenerator())
+	gen.Log = &log
+	gen.Vars["author"] = "jane"
+	if err := gen.LoadTemplates(dir); err != nil {
+		t.Fatalf("LoadTemplates() error = %v", err)
+	}
+
+	nodes := []parser.Node{
+		{Path: "api/", IsDir: true},
+		{Path: "api/server.go", Comment: "http server", Attrs: map[string]string{"template": "grpc-service"}},
+		{Path: "api/client.go", Comment: "http client", Attrs: map[string]string{"template": "missing"}},
+		{Path: "api/router.go", Comment: "routes"},
+		{Path: "api/README.md", Comment: "docs"},
+	}
+	s := scaffold.NewScaffolder()
+	s.ContentProvider = gen
+	root := t.TempDir()
+	if err := s.Apply(root, nodes, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	want := map[string]string{
+		"server.go": "// http server\n// grpc service server.go\n", // named template
+		"client.go": "// http client\npackage client // by jane\n", // missing name falls back to go.tmpl
+		"router.go": "// routes\npackage router // by jane\n",
+		"README.md": "<!-- docs -->\n", // no template: default generator
+	}
+	for name, content := range want {
+		data, err := os.ReadFile(filepath.Join(root, "api", name))
+		if err != nil {
+			t.Fatalf("ReadFile(%s) error = %v", name, err)
+		}
+		if string(data) != content {
+			t.Errorf("%s = %q, want %q", name, data, content)
+		}
+	}
+
+	if !strings.Contains(log.String(), `Template "missing" not found for api/client.go`) {
+		t.Errorf("expected a warning about the missing template, got %q", log.String())
+	}
+}
+
+func TestBuiltinTemplates(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "py.tmpl"), []byte("# {{.Comment}} (ours)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := []parser.Node{
+		{Path: "internal/api/", IsDir: true},
+		{Path: "internal/api/doc.go", Comment: "serves the HTTP API"},
+		{Path: "app.py", Comment: "entry point"},
+		{Path: "setup.PY", Comment: "installs the package"},
+		{Path: "tools/", IsDir: true},
+		{Path: "tools/user_service.py"},
+	}
+	tests := []struct {
+		name   string
+		user   bool
+		derive bool
+		want   map[string]string
+	}{
+		{"builtin", false, false, map[string]string{
+			"internal/api/doc.go":   "// Package api: serves the HTTP API\npackage api\n",
+			"app.py":                "\"\"\"entry point\"\"\"\n",
+			"setup.PY":              "\"\"\"installs the package\"\"\"\n",
+			"tools/user_service.py": "",
+		}},
+		{"user override", true, false, map[string]string{
+			"internal/api/doc.go": "// Package api: serves the HTTP API\npackage api\n",
+			"app.py":              "# entry point (ours)\n",
+		}},
+		{"comment from filename", false, true, map[string]string{
+			"app.py":                "\"\"\"entry point\"\"\"\n",
+			"tools/user_service.py": "\"\"\"user service\"\"\"\n",
+		}},
+	}
+
+	for _, tt := range tests {
+		def := scaffold.NewDefaultContentGenerator()
+		def.CommentFromFilename = tt.derive
+		gen := scaffold.NewTemplateGenerator(def)
+		if err := gen.LoadBuiltinTemplates(); err != nil {
+			t.Fatalf("LoadBuiltinTemplates() error = %v", err)
+		}
+		if tt.user {
+			if err := gen.LoadTemplates(dir); err != nil {
+				t.Fatalf("LoadTemplates() error = %v", err)
+			}
+		}
+		s := scaffold.NewScaffolder()
+		s.ContentProvider = gen
+		root := t.TempDir()
+		if err := s.Apply(root, nodes, nil); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+		for name, content := range tt.want {
+			data, err := os.ReadFile(filepath.Join(root, name))
+			if err != nil {
+				t.Fatalf("ReadFile(%s) error = %v", name, err)
+			}
+			if string(data) != content {
+				t.Errorf("%s: %s = %q, want %q", tt.name, name, data, content)
+			}
+		}
+	}
+}
+
+func TestDotfileGenerators(t *testing.T) {
+	tests := []struct {
+		path    string
+		comment string
+		want    []string
+	}{
+		{".editorconfig", "", []string{"root = true\n", "\n[*]\n", "charset = utf-8\n", "indent_style = space\n"}},
+		{".editorconfig", "editor settings", []string{"# editor settings\n\nroot = true\n"}},
+		{"server/.dockerignore", "", []string{".git\n", "node_modules\n"}},
+		{"server/.dockerignore", "keep the context small", []string{"# keep the context small\n\n.git\n"}},
+		{".gitattributes", "", []string{"* text=auto\n"}},
+	}
+
+	for _, tt := range tests {
+		got := scaffold.PreviewFile(tt.path, tt.comment)
+		for _, want := range tt.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("PreviewFile(%s, %q) = %q, want it to contain %q", tt.path, tt.comment, got, want)
+			}
+		}
+	}
+	if got := scaffold.PreviewFile(".editorconfig", ""); !strings.HasPrefix(got, "root = true\n") {
+		t.Errorf(".editorconfig = %q, want it to start with root = true", got)
+	}
+}
+
+func TestTemplateData(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"data.yaml": `# project settings
+project:
+  name: orders
+  owner: "Jane Doe" # lead
+  ports: [8080, 9090]
+services:
+- name: api
+  replicas: 2
+- name: worker
+debug: false
+`,
+		"data.json":         `{"project": {"name": "orders"}, "author": "json"}`,
+		"templates/go.tmpl": "// {{.Data.project.name}} by {{.Data.author}}\npackage {{.Base}}\n",
+	}
+	for name, text := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := scaffold.LoadTemplateData(filepath.Join(dir, "data.yaml"))
+	if err != nil {
+		t.Fatalf("LoadTemplateData(yaml) error = %v", err)
+	}
+	want := map[string]any{
+		"project": map[string]any{"name": "orders", "owner": "Jane Doe", "ports": []any{8080, 9090}},
+		"services": []any{
+			map[string]any{"name": "api", "replicas": 2},
+			map[string]any{"name": "worker"},
+		},
+		"debug": false,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadTemplateData(yaml) = %#v, want %#v", got, want)
+	}
+
+	// A template reads nested values, and -var replaces top-level ones
+	data, err := scaffold.LoadTemplateData(filepath.Join(dir, "data.json"))
+	if err != nil {
+		t.Fatalf("LoadTemplateData(json) error = %v", err)
+	}
+	for _, tt := range []struct {
+		vars map[string]string
+		want string
+	}{
+		{nil, "// orders by json\npackage server\n"},
+		{map[string]string{"author": "jane"}, "// orders by jane\npackage server\n"},
+	} {
+		gen := scaffold.NewTemplateGenerator(scaffold.NewDefaultContentGenerator())
+		if err := gen.LoadTemplates(filepath.Join(dir, "templates")); err != nil {
+			t.Fatalf("LoadTemplates() error = %v", err)
+		}
+		gen.Data = data
+		for k, v := range tt.vars {
+			gen.Vars[k] = v
+		}
+		if got := gen.GenerateContent("api/server.go", ""); got != tt.want {
+			t.Errorf("vars %v: GenerateContent() = %q, want %q", tt.vars, got, tt.want)
+		}
+	}
+
+	bad := filepath.Join(dir, "bad.yaml")
+	if err := os.WriteFile(bad, []byte("name: orders\nlist: {a: 1}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scaffold.LoadTemplateData(bad); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("LoadTemplateData(flow mapping) error = %v, want one for line 2", err)
+	}
+}
+
+func TestGoSum(t *testing.T) {
+	if got := scaffold.PreviewFile("go.sum", "Checksums for dependencies"); strings.Contains(got, "//") {
+		t.Errorf("go.sum = %q, want no comments", got)
+	}
+
+	for _, tt := range []struct {
+		mode string
+		want string
+	}{
+		{"empty", ""},
+		{"comment", "// Checksums\n// This file will be automatically populated when dependencies are added to go.mod\n"},
+	} {
+		mode, err := scaffold.ParseGoSumMode(tt.mode)
+		if err != nil {
+			t.Fatalf("ParseGoSumMode(%q) error = %v", tt.mode, err)
+		}
+		gen := scaffold.NewDefaultContentGenerator()
+		gen.GoSum = mode
+		if got := gen.GenerateContent("api/go.sum", "Checksums"); got != tt.want {
+			t.Errorf("-gosum %s: go.sum = %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+	if _, err := scaffold.ParseGoSumMode("none"); err == nil {
+		t.Error("ParseGoSumMode accepted an unknown mode")
+	}
+
+	nodes := []parser.Node{
+		{Path: "go.mod"},
+		{Path: "go.sum"},
+		{Path: "tools/", IsDir: true},
+		{Path: "tools/go.sum", Content: []byte("example.com/x v1.0.0 h1:abc=\n")},
+	}
+	var paths []string
+	for _, n := range scaffold.SkipGoSum(nodes) {
+		paths = append(paths, n.Path)
+	}
+	if got, want := strings.Join(paths, " "), "go.mod tools/ tools/go.sum"; got != want {
+		t.Errorf("SkipGoSum() = %q, want %q", got, want)
+	}
+}
+
+func TestEnvGenerator(t *testing.T) {
+	tests := []struct {
+		name    string
+		comment string
+		want    string
+	}{
+		{"names", "DB_URL, API_KEY", "# DB_URL, API_KEY\nDB_URL=\nAPI_KEY=\n"},
+		{"names in prose", "settings: DB_URL and PORT (required)", "# settings: DB_URL and PORT (required)\nDB_URL=\nPORT=\n"},
+		{"plain", "local secrets, never commit", "# local secrets, never commit\n"},
+		{"no comment", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scaffold.PreviewFile("deploy/.env", tt.comment); got != tt.want {
+				t.Errorf("PreviewFile(.env, %q) = %q, want %q", tt.comment, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadGoWork(t *testing.T) {
+	dir := t.TempDir()
+	if work, err := scaffold.ReadGoWork(dir); err != nil || !reflect.DeepEqual(work, scaffold.GoWork{}) {
+		t.Fatalf("ReadGoWork(no go.work) = %+v, %v; want zero value", work, err)
+	}
+
+	content := "// workspace\ngo 1.21\n\nuse (\n\t./api\n\t\"./web\" // frontend\n)\n\nuse ./tools\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.work"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	work, err := scaffold.ReadGoWork(dir)
+	if err != nil {
+		t.Fatalf("ReadGoWork() error = %v", err)
+	}
+	want := scaffold.GoWork{Go: "1.21", Use: []string{"./api", "./web", "./tools"}}
+	if !reflect.DeepEqual(work, want) {
+		t.Errorf("ReadGoWork() = %+v, want %+v", work, want)
+	}
+
+	gen := scaffold.NewDefaultContentGenerator()
+	gen.GoVersion = work.Go
+	for _, file := range []string{"svc/go.mod", "go.work"} {
+		if got := gen.GenerateContent(file, ""); !strings.Contains(got, "\ngo 1.21\n") && !strings.HasPrefix(got, "go 1.21\n") {
+			t.Errorf("%s = %q, want go 1.21 from go.work", file, got)
+		}
+	}
+}
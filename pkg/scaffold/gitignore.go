@@ -0,0 +1,152 @@
+package scaffold
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/lancekrogers/tree2scaffold/pkg/parser"
+)
+
+// Gitignore holds the rules of a .gitignore file
+type Gitignore struct {
+	rules []ignoreRule
+}
+
+// ignoreRule is one pattern line of a .gitignore
+type ignoreRule struct {
+	pattern  string
+	negate   bool // "!pattern" re-includes what earlier rules ignored
+	dirOnly  bool // "pattern/" matches only directories
+	anchored bool // a "/" before the end ties the pattern to the file's directory
+}
+
+// ParseGitignore reads .gitignore rules from r: blank lines and "#" comments
+// are skipped, "!" negates, a trailing "/" matches only directories, a
+// pattern with another "/" is relative to the root, and "*", "?", "[...]"
+// and "**" are wildcards.
+func ParseGitignore(r io.Reader) (*Gitignore, error) {
+	g := &Gitignore{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var rule ignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		g.rules = append(g.rules, rule)
+	}
+	return g, scanner.Err()
+}
+
+// LoadGitignore reads the .gitignore at the top of root. A root without one
+// ignores nothing.
+func LoadGitignore(root string) (*Gitignore, error) {
+	f, err := os.Open(filepath.Join(root, ".gitignore"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Gitignore{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	g, err := ParseGitignore(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", f.Name(), err)
+	}
+	return g, nil
+}
+
+// Ignored reports whether git would ignore the file or directory at rel, a
+// slash-separated path relative to the root. Like git, it never looks inside
+// an ignored directory, so no rule can re-include a file within one.
+func (g *Gitignore) Ignored(rel string, isDir bool) bool {
+	rel = strings.Trim(rel, "/")
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		if g.match(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+	return g.match(rel, isDir)
+}
+
+// match applies the rules to rel alone; the last rule matching it decides
+func (g *Gitignore) match(rel string, isDir bool) bool {
+	ignored := false
+	for _, r := range g.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		var ok bool
+		if r.anchored {
+			ok = globMatch(strings.Split(r.pattern, "/"), strings.Split(rel, "/"))
+		} else {
+			ok, _ = path.Match(r.pattern, path.Base(rel))
+		}
+		if ok {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// globMatch matches path segments against pattern segments, where a "**"
+// segment stands for any number of segments
+func globMatch(pattern, segs []string) bool {
+	if len(pattern) == 0 {
+		return len(segs) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segs); i++ {
+			if globMatch(pattern[1:], segs[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segs) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], segs[0])
+	return ok && globMatch(pattern[1:], segs[1:])
+}
+
+// FilterGitignored drops the nodes g ignores, directly or through an ignored
+// parent directory, and returns the paths it dropped
+func FilterGitignored(nodes []parser.Node, g *Gitignore) (kept []parser.Node, dropped []string) {
+	for _, n := range nodes {
+		if g.Ignored(n.Path, n.IsDir) {
+			dropped = append(dropped, n.Path)
+			continue
+		}
+		kept = append(kept, n)
+	}
+	return kept, dropped
+}
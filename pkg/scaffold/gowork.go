@@ -0,0 +1,81 @@
+package scaffold
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// GoWork is what a go.work file declares about the workspace
+type GoWork struct {
+	// Go is the version of the go directive, e.g. "1.22"; empty when the
+	// file has none
+	Go string
+
+	// Use lists the module directories of the use directives, in file order
+	Use []string
+}
+
+// ReadGoWork parses the go.work file in dir. A dir without one yields a zero
+// GoWork and no error.
+func ReadGoWork(dir string) (GoWork, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "go.work"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return GoWork{}, nil
+	}
+	if err != nil {
+		return GoWork{}, err
+	}
+	return parseGoWork(string(data))
+}
+
+// parseGoWork reads the go and use directives of a go.work file, in both
+// their single-line and parenthesized block forms
+func parseGoWork(content string) (GoWork, error) {
+	var work GoWork
+	block := "" // directive of the open ( block, if any
+	for i, line := range strings.Split(content, "\n") {
+		if at := strings.Index(line, "//"); at >= 0 {
+			line = line[:at]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if block != "" {
+			if fields[0] == ")" {
+				block = ""
+				continue
+			}
+			if block == "use" {
+				work.Use = append(work.Use, unquoteGoWork(fields[0]))
+			}
+			continue
+		}
+
+		switch {
+		case len(fields) == 2 && fields[1] == "(":
+			block = fields[0]
+		case fields[0] == "go":
+			if len(fields) != 2 {
+				return work, fmt.Errorf("go.work:%d: malformed go directive", i+1)
+			}
+			work.Go = fields[1]
+		case fields[0] == "use" && len(fields) >= 2:
+			work.Use = append(work.Use, unquoteGoWork(fields[1]))
+		}
+	}
+	return work, nil
+}
+
+// unquoteGoWork strips the quotes a go.work path may be written with
+func unquoteGoWork(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '`') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,85 @@
+package scaffold
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lancekrogers/tree2scaffold/pkg/parser"
+)
+
+// CheckLinks rejects symlink nodes whose own path or target is absolute or
+// resolves outside the scaffold root, so a spec cannot plant links in, or to,
+// arbitrary places.
+func CheckLinks(nodes []parser.Node) error {
+	for _, n := range nodes {
+		if n.LinkTarget == "" {
+			continue
+		}
+		if own := filepath.Clean(cleanNodePath(n.Path)); filepath.IsAbs(own) || own == ".." || strings.HasPrefix(own, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid link %s: the link must be inside the scaffold root", describeNode(n))
+		}
+		if filepath.IsAbs(n.LinkTarget) {
+			return fmt.Errorf("invalid link %s: target %q must be relative", describeNode(n), n.LinkTarget)
+		}
+		resolved := filepath.Join(filepath.Dir(cleanNodePath(n.Path)), n.LinkTarget)
+		if resolved == ".." || strings.HasPrefix(resolved, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("invalid link %s: target %q points outside the scaffold root", describeNode(n), n.LinkTarget)
+		}
+	}
+	return nil
+}
+
+// applyLink creates the symlink for n at full. An existing path follows the
+// same conflict policy as a file: it is left alone under ConflictSkip or when
+// OverwriteOnly does not list it, backed up or removed first when it may be
+// overwritten, and kept next to a link under a numbered name under
+// ConflictRename. An existing directory is never replaced.
+func (s *DefaultScaffolder) applyLink(full string, n parser.Node, onCreate CreationCallback, res *ApplyResult) error {
+	action, detail := ActionCreated, "-> "+n.LinkTarget
+	if fi, err := os.Lstat(full); err == nil {
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if target, err := os.Readlink(full); err == nil && target == n.LinkTarget {
+				res.add(n.Path, ActionExists, false, detail)
+				return nil // already the link we want
+			}
+		}
+		switch {
+		case fi.IsDir():
+			s.notef("Skipping existing path for link: %s", full)
+			res.add(n.Path, ActionSkipped, false, "a directory is in the way")
+			return nil
+		case s.overwrites(n.Path):
+			action = ActionOverwritten
+			if s.Backup {
+				if err := backupFile(full); err != nil {
+					return err
+				}
+			} else if err := os.Remove(full); err != nil {
+				return fmt.Errorf("cannot replace %s with a link: %w", full, err)
+			}
+		case s.OnConflict == ConflictRename && s.OverwriteOnly == nil:
+			if full, err = freeName(full); err != nil {
+				return err
+			}
+			action, detail = ActionRenamed, "as "+filepath.Base(full)+" "+detail
+		default:
+			s.notef("Skipping existing path for link: %s", full)
+			res.add(n.Path, ActionSkipped, false, "already exists")
+			return nil
+		}
+	}
+
+	if onCreate != nil {
+		onCreate(full, false)
+	}
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		return err
+	}
+	if err := os.Symlink(n.LinkTarget, full); err != nil {
+		return err
+	}
+	res.add(n.Path, action, false, detail)
+	return nil
+}
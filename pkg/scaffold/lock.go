@@ -0,0 +1,93 @@
+package scaffold
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lancekrogers/tree2scaffold/pkg/parser"
+)
+
+// LockFileName is the file, relative to the scaffold root, that records the
+// checksum of every scaffolded file
+const LockFileName = ".tree2scaffold.lock"
+
+// WriteLockFile hashes every file node under root and records the sums in
+// root/LockFileName, one "<sha256>  <path>" line per file sorted by path.
+// Symlinks are not recorded.
+func WriteLockFile(root string, nodes []parser.Node) error {
+	var paths []string
+	for _, n := range nodes {
+		if !n.IsDir && n.LinkTarget == "" {
+			paths = append(paths, n.Path)
+		}
+	}
+	sort.Strings(paths)
+
+	var b strings.Builder
+	for _, p := range paths {
+		sum, err := hashFile(filepath.Join(root, p))
+		if err != nil {
+			return fmt.Errorf("cannot hash %s: %w", p, err)
+		}
+		fmt.Fprintf(&b, "%s  %s\n", sum, filepath.ToSlash(p))
+	}
+
+	return os.WriteFile(filepath.Join(root, LockFileName), []byte(b.String()), 0o644)
+}
+
+// VerifyLockFile re-hashes the files recorded in root/LockFileName and reports
+// every file that changed or disappeared since the lock was written.
+func VerifyLockFile(root string) error {
+	f, err := os.Open(filepath.Join(root, LockFileName))
+	if err != nil {
+		return fmt.Errorf("cannot read lock file: %w", err)
+	}
+	defer f.Close()
+
+	var drift []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		want, path, ok := strings.Cut(line, "  ")
+		if !ok {
+			return fmt.Errorf("malformed lock file line: %q", line)
+		}
+
+		got, err := hashFile(filepath.Join(root, filepath.FromSlash(path)))
+		switch {
+		case os.IsNotExist(err):
+			drift = append(drift, "missing: "+path)
+		case err != nil:
+			return fmt.Errorf("cannot hash %s: %w", path, err)
+		case got != want:
+			drift = append(drift, "modified: "+path)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if len(drift) > 0 {
+		return fmt.Errorf("scaffold drift detected in %d files:\n  %s", len(drift), strings.Join(drift, "\n  "))
+	}
+	return nil
+}
+
+// hashFile returns the hex-encoded SHA-256 of the file at path
+func hashFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
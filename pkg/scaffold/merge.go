@@ -0,0 +1,49 @@
+package scaffold
+
+import (
+	"strings"
+)
+
+// contentMergers combine an existing file with freshly generated content for
+// file names whose structure is understood well enough to merge safely
+var contentMergers = map[string]func(existing, generated string) string{
+	"go.mod": mergeGoMod,
+}
+
+// mergeGoMod keeps an existing go.mod intact, including its module path and
+// requirements, and only adds the generated go directive when it has none
+func mergeGoMod(existing, generated string) string {
+	if goModDirective(existing, "go") != "" {
+		return existing
+	}
+	goLine := goModDirective(generated, "go")
+	if goLine == "" {
+		return existing
+	}
+
+	lines := strings.Split(existing, "\n")
+	at := 0 // insert at the top unless there is a module line
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "module ") {
+			at = i + 1
+			break
+		}
+	}
+
+	merged := append([]string{}, lines[:at]...)
+	merged = append(merged, "", goLine)
+	merged = append(merged, lines[at:]...)
+	return strings.Join(merged, "\n")
+}
+
+// goModDirective returns the first line of a go.mod that starts with the
+// directive name, or "" when there is none
+func goModDirective(content, name string) string {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, name+" ") {
+			return line
+		}
+	}
+	return ""
+}
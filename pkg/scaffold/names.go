@@ -0,0 +1,161 @@
+package scaffold
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/lancekrogers/tree2scaffold/pkg/parser"
+)
+
+// DirCase is a naming convention applied to directory names
+type DirCase int
+
+const (
+	// DirCasePreserve keeps directory names as written
+	DirCasePreserve DirCase = iota
+	// DirCaseKebab turns "MyService" into "my-service"
+	DirCaseKebab
+	// DirCaseSnake turns "MyService" into "my_service"
+	DirCaseSnake
+	// DirCaseLower turns "MyService" into "myservice"
+	DirCaseLower
+)
+
+// ParseDirCase maps a command-line value to a DirCase
+func ParseDirCase(s string) (DirCase, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "preserve":
+		return DirCasePreserve, nil
+	case "kebab":
+		return DirCaseKebab, nil
+	case "snake":
+		return DirCaseSnake, nil
+	case "lower":
+		return DirCaseLower, nil
+	default:
+		return DirCasePreserve, fmt.Errorf("unknown directory case %q (want kebab, snake, lower or preserve)", s)
+	}
+}
+
+// NormalizeDirCase renames every directory component of the node paths to
+// style. File names are left alone, so "MyService/Handler.go" becomes
+// "my-service/Handler.go" in kebab case. Two directories that end up with
+// the same name, such as FooBar/ and foo_bar/, are an error rather than
+// merged.
+func NormalizeDirCase(nodes []parser.Node, style DirCase) ([]parser.Node, error) {
+	if style == DirCasePreserve {
+		return nodes, nil
+	}
+
+	out := make([]parser.Node, len(nodes))
+	renamed := make(map[string]string) // new directory path -> original
+	for i, n := range nodes {
+		orig := strings.Split(cleanNodePath(n.Path), "/")
+		parts := slices.Clone(orig)
+		dirs := len(parts) - 1 // the last part is a file name
+		if n.IsDir {
+			dirs = len(parts)
+		}
+		for j := 0; j < dirs; j++ {
+			parts[j] = convertCase(parts[j], style)
+			dir, was := path.Join(parts[:j+1]...), path.Join(orig[:j+1]...)
+			if prev, ok := renamed[dir]; ok && prev != was {
+				return nil, fmt.Errorf("directories %s/ and %s/ would both be renamed %s/", prev, was, dir)
+			}
+			renamed[dir] = was
+		}
+
+		n.Path = path.Join(parts...)
+		if n.IsDir {
+			n.Path += "/"
+		}
+		out[i] = n
+	}
+	return out, nil
+}
+
+// LowercaseExtensions lowercases the extension of every file node, so
+// "cmd/Main.GO" becomes "cmd/Main.go" and is built by the Go toolchain. The
+// rest of the name, directories and symlinks are left alone, though a link to
+// a renamed file is pointed at its new name.
+func LowercaseExtensions(nodes []parser.Node) []parser.Node {
+	out := make([]parser.Node, len(nodes))
+	renamed := make(map[string]bool) // cleaned paths whose extension changed
+	for i, n := range nodes {
+		if ext := path.Ext(n.Path); !n.IsDir && n.LinkTarget == "" && ext != strings.ToLower(ext) {
+			renamed[path.Clean(n.Path)] = true
+			n.Path = strings.TrimSuffix(n.Path, ext) + strings.ToLower(ext)
+		}
+		out[i] = n
+	}
+
+	for i, n := range out {
+		if n.LinkTarget == "" {
+			continue
+		}
+		target := path.Join(path.Dir(path.Clean(n.Path)), n.LinkTarget)
+		if renamed[target] {
+			ext := path.Ext(n.LinkTarget)
+			out[i].LinkTarget = strings.TrimSuffix(n.LinkTarget, ext) + strings.ToLower(ext)
+		}
+	}
+	return out
+}
+
+// convertCase rewrites one name in style, keeping a leading dot
+func convertCase(name string, style DirCase) string {
+	prefix := ""
+	if strings.HasPrefix(name, ".") {
+		prefix, name = ".", name[1:]
+	}
+
+	words := splitWords(name)
+	for i, w := range words {
+		words[i] = strings.ToLower(w)
+	}
+
+	switch style {
+	case DirCaseKebab:
+		return prefix + strings.Join(words, "-")
+	case DirCaseSnake:
+		return prefix + strings.Join(words, "_")
+	default:
+		return prefix + strings.Join(words, "")
+	}
+}
+
+// splitWords breaks a name into words at separators ("-", "_", space) and at
+// case changes, treating an upper-case run as one word: "HTTPServer" is
+// "HTTP" and "Server". Dots are kept, so "v1.2.0" stays one word.
+func splitWords(name string) []string {
+	runes := []rune(name)
+	var words []string
+	start := -1
+	for i, r := range runes {
+		if r == '-' || r == '_' || unicode.IsSpace(r) {
+			if start >= 0 {
+				words = append(words, string(runes[start:i]))
+				start = -1
+			}
+			continue
+		}
+		if start >= 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				words = append(words, string(runes[start:i]))
+				start = i
+			}
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
@@ -0,0 +1,84 @@
+package scaffold
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/lancekrogers/tree2scaffold/pkg/parser"
+)
+
+// SortOrder is the order Apply creates nodes in, and the order SortNodes
+// puts them in for a preview
+type SortOrder int
+
+const (
+	// SortType puts directories first, then files, each alphabetically by
+	// path (the default)
+	SortType SortOrder = iota
+	// SortName orders directories and files together alphabetically by path
+	SortName
+	// SortInput keeps the order of the spec
+	SortInput
+)
+
+// ParseSortOrder maps a command-line value ("type", "name", "input") to a
+// SortOrder
+func ParseSortOrder(s string) (SortOrder, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "type":
+		return SortType, nil
+	case "name":
+		return SortName, nil
+	case "input":
+		return SortInput, nil
+	default:
+		return SortType, fmt.Errorf("unknown sort order %q (want type, name or input)", s)
+	}
+}
+
+// SortNodes returns a copy of nodes in the given order, adding a node for
+// every directory a path only implies; SortInput appends those at the end. A
+// directory always sorts before the nodes inside it.
+func SortNodes(nodes []parser.Node, order SortOrder) []parser.Node {
+	out := withImpliedDirs(slices.Clone(nodes))
+	switch order {
+	case SortType:
+		slices.SortStableFunc(out, func(a, b parser.Node) int {
+			if a.IsDir != b.IsDir {
+				if a.IsDir {
+					return -1
+				}
+				return 1
+			}
+			return strings.Compare(cleanNodePath(a.Path), cleanNodePath(b.Path))
+		})
+	case SortName:
+		slices.SortStableFunc(out, func(a, b parser.Node) int {
+			return strings.Compare(cleanNodePath(a.Path), cleanNodePath(b.Path))
+		})
+	}
+	return out
+}
+
+// withImpliedDirs appends a node for every directory a path implies but no
+// node declares, so it sorts among the other directories
+func withImpliedDirs(nodes []parser.Node) []parser.Node {
+	declared := make(map[string]bool)
+	for _, n := range nodes {
+		if n.IsDir {
+			declared[cleanNodePath(n.Path)] = true
+		}
+	}
+	out := nodes
+	for _, n := range nodes {
+		for dir := filepath.Dir(cleanNodePath(n.Path)); dir != "." && dir != "/"; dir = filepath.Dir(dir) {
+			if !declared[dir] {
+				declared[dir] = true
+				out = append(out, parser.Node{Path: dir + "/", IsDir: true})
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,62 @@
+package scaffold
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+
+	"github.com/lancekrogers/tree2scaffold/pkg/parser"
+)
+
+// applyOwnership gives the file or directory at full the owner of n's @owner
+// directive. Changing the owner usually needs root, so a failure is noted
+// rather than returned.
+func (s *DefaultScaffolder) applyOwnership(full string, n parser.Node) {
+	owner, ok := n.Attrs["owner"]
+	if !ok {
+		return
+	}
+	uid, gid, err := lookupOwner(owner)
+	if err == nil {
+		err = os.Chown(full, uid, gid)
+	}
+	if err != nil {
+		s.notef("cannot set the owner of %s to %s: %v", full, owner, err)
+	}
+}
+
+// lookupOwner resolves an @owner value of the form user, user:group or
+// :group, by name or numeric id, to the ids os.Chown takes; -1 leaves one
+// unchanged
+func lookupOwner(owner string) (uid, gid int, err error) {
+	name, group, _ := strings.Cut(owner, ":")
+	if name == "" && group == "" {
+		return 0, 0, fmt.Errorf("no user or group given")
+	}
+	uid, gid = -1, -1
+	if name != "" {
+		if uid, err = strconv.Atoi(name); err != nil {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return 0, 0, err
+			}
+			if uid, err = strconv.Atoi(u.Uid); err != nil {
+				return 0, 0, fmt.Errorf("user %s has non-numeric id %s", name, u.Uid)
+			}
+		}
+	}
+	if group != "" {
+		if gid, err = strconv.Atoi(group); err != nil {
+			g, err := user.LookupGroup(group)
+			if err != nil {
+				return 0, 0, err
+			}
+			if gid, err = strconv.Atoi(g.Gid); err != nil {
+				return 0, 0, fmt.Errorf("group %s has non-numeric id %s", group, g.Gid)
+			}
+		}
+	}
+	return uid, gid, nil
+}
@@ -0,0 +1,72 @@
+package scaffold
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lancekrogers/tree2scaffold/pkg/parser"
+)
+
+// windowsReserved are the device names Windows reserves with any extension,
+// compared case-insensitively
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// windowsInvalidChars may not appear in a Windows file name
+const windowsInvalidChars = `<>:"\|?*`
+
+// maxNameLen is the longest name, in bytes, that common file systems such as
+// ext4, APFS and NTFS accept
+const maxNameLen = 255
+
+// CheckNames reports the first node with a path element that is not a usable
+// file name on every common operating system: a name Windows reserves such
+// as CON or nul.txt, a character Windows rejects such as : or *, a trailing
+// dot or space, a control character, or more than 255 bytes. A spec that
+// passes can be created and checked out on Linux, macOS and Windows alike.
+func CheckNames(nodes []parser.Node) error {
+	for _, n := range nodes {
+		for _, name := range strings.Split(cleanNodePath(n.Path), "/") {
+			if name == "" || name == "." || name == ".." {
+				continue
+			}
+			if problem := nameProblem(name); problem != "" {
+				return fmt.Errorf("%s: %s", describeNode(n), problem)
+			}
+		}
+	}
+	return nil
+}
+
+// nameProblem explains what makes name unusable and how to fix it, or
+// returns "" when it is fine everywhere
+func nameProblem(name string) string {
+	if len(name) > maxNameLen {
+		return fmt.Sprintf("%q is %d bytes long, more than the %d most file systems allow; shorten it", name, len(name), maxNameLen)
+	}
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f {
+			return fmt.Sprintf("%q contains the control character %U; remove it", name, r)
+		}
+		if strings.ContainsRune(windowsInvalidChars, r) {
+			return fmt.Sprintf("%q contains %q, which Windows does not allow in file names; remove or replace it", name, r)
+		}
+	}
+	if strings.HasSuffix(name, ".") || strings.HasSuffix(name, " ") {
+		return fmt.Sprintf("%q ends in a dot or space, which Windows drops from file names; remove it", name)
+	}
+	stem, ext, _ := strings.Cut(name, ".")
+	if windowsReserved[strings.ToUpper(strings.TrimRight(stem, " "))] {
+		suggestion := stem + "_"
+		if ext != "" {
+			suggestion += "." + ext
+		}
+		return fmt.Sprintf("%q is a device name Windows reserves, even with an extension; rename it, e.g. to %q", name, suggestion)
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package scaffold
+
+import (
+	"fmt"
+	"io"
+)
+
+// Action is what Apply did with one path
+type Action string
+
+const (
+	// ActionCreated means the path did not exist and was created
+	ActionCreated Action = "created"
+	// ActionExists means a directory or link was already in place
+	ActionExists Action = "exists"
+	// ActionSkipped means an existing path was left untouched
+	ActionSkipped Action = "skipped"
+	// ActionOverwritten means an existing file was replaced
+	ActionOverwritten Action = "overwritten"
+	// ActionRenamed means an existing file was kept and the new one written
+	// under a numbered name, given in the detail
+	ActionRenamed Action = "renamed"
+	// ActionMerged means new content was merged into an existing file
+	ActionMerged Action = "merged"
+	// ActionConverted means a file was removed to make room for a directory
+	ActionConverted Action = "converted"
+	// ActionError records the error that stopped Apply
+	ActionError Action = "error"
+)
+
+// ResultEntry is one action taken by Apply
+type ResultEntry struct {
+	Path   string `json:"path,omitempty"` // relative to the scaffold root
+	Action Action `json:"action"`
+	IsDir  bool   `json:"dir,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// ApplyResult lists the actions of one Apply run in the order they happened
+type ApplyResult struct {
+	Entries []ResultEntry `json:"entries"`
+}
+
+// add records an action
+func (r *ApplyResult) add(path string, action Action, isDir bool, detail string) {
+	r.Entries = append(r.Entries, ResultEntry{Path: path, Action: action, IsDir: isDir, Detail: detail})
+}
+
+// Count returns how many entries have the given action
+func (r *ApplyResult) Count(action Action) int {
+	n := 0
+	for _, e := range r.Entries {
+		if e.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteText writes one "<action> <path>" line per entry, directories with a
+// trailing slash and any detail in parentheses
+func (r *ApplyResult) WriteText(w io.Writer) error {
+	for _, e := range r.Entries {
+		path := e.Path
+		if e.IsDir {
+			path += "/"
+		}
+		line := fmt.Sprintf("%-11s %s", e.Action, path)
+		if e.Detail != "" {
+			line += " (" + e.Detail + ")"
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
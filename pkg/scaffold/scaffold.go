@@ -2,9 +2,16 @@
 package scaffold
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
 
 	"github.com/lancekrogers/tree2scaffold/pkg/parser"
 )
@@ -33,10 +40,163 @@ type ContentGenerator interface {
 	RegisterGenerator(extOrName string, generator FileGenerator)
 }
 
+// NodeContentGenerator is implemented by content generators that need the
+// whole node, e.g. to honor its directives. Apply prefers it when available.
+type NodeContentGenerator interface {
+	GenerateNodeContent(n parser.Node, comment string) string
+}
+
+// FallibleContentGenerator is implemented by content generators that can
+// fail, e.g. by running a command or a template. Apply prefers it over the
+// other interfaces. On failure it returns the content it fell back to along
+// with the error, and Apply writes that content unless StrictGenerate is set.
+type FallibleContentGenerator interface {
+	TryGenerateContent(n parser.Node, comment string) (string, error)
+}
+
+// SpecPlanner is implemented by content generators that look at the whole
+// spec before generating, e.g. to give every Go file of a directory the same
+// package. Apply calls PlanSpec with all nodes before generating any file.
+type SpecPlanner interface {
+	PlanSpec(nodes []parser.Node)
+}
+
+// ConflictPolicy decides what Apply does when a file it would write already exists
+type ConflictPolicy int
+
+const (
+	// ConflictSkip leaves existing files untouched (the default)
+	ConflictSkip ConflictPolicy = iota
+	// ConflictOverwrite replaces existing files with freshly generated content
+	ConflictOverwrite
+	// ConflictRename keeps existing files and writes the new one under the
+	// first free numbered name, e.g. a.1.go next to a.go
+	ConflictRename
+)
+
+// ParseConflictPolicy maps a command-line value ("skip", "overwrite",
+// "rename") to a ConflictPolicy
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "skip":
+		return ConflictSkip, nil
+	case "overwrite":
+		return ConflictOverwrite, nil
+	case "rename":
+		return ConflictRename, nil
+	default:
+		return ConflictSkip, fmt.Errorf("unknown conflict policy %q (want skip, overwrite or rename)", s)
+	}
+}
+
+// LineEnding is the newline sequence Apply writes in generated files
+type LineEnding int
+
+const (
+	// LineEndingLF ends lines with "\n" (the default)
+	LineEndingLF LineEnding = iota
+	// LineEndingCRLF ends lines with "\r\n", for Windows-targeted projects
+	LineEndingCRLF
+)
+
+// ParseLineEnding maps a command-line value ("lf", "crlf") to a LineEnding
+func ParseLineEnding(s string) (LineEnding, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "lf":
+		return LineEndingLF, nil
+	case "crlf":
+		return LineEndingCRLF, nil
+	default:
+		return LineEndingLF, fmt.Errorf("unknown line ending %q (want lf or crlf)", s)
+	}
+}
+
+// normalize rewrites every newline in content, "\n" or "\r\n", to e
+func (e LineEnding) normalize(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	if e == LineEndingCRLF {
+		return strings.ReplaceAll(content, "\n", "\r\n")
+	}
+	return content
+}
+
 // DefaultScaffolder implements the Scaffolder interface with default behavior
 type DefaultScaffolder struct {
 	ForceMode       bool
 	ContentProvider ContentGenerator
+
+	// OnConflict controls what happens to files that already exist on disk
+	OnConflict ConflictPolicy
+
+	// Backup renames an existing file to <name>.bak before it is overwritten
+	Backup bool
+
+	// WriteLock records file checksums in LockFileName after a successful Apply
+	WriteLock bool
+
+	// Log receives notes about skipped or converted paths; nil means os.Stderr
+	Log io.Writer
+
+	// MergeExisting merges freshly generated content into existing files of
+	// a known structure (go.mod) instead of skipping or overwriting them
+	MergeExisting bool
+
+	// NoComments hands generators an empty comment for every file, keeping
+	// the spec's comments out of the generated content
+	NoComments bool
+
+	// DedupComments drops a file's comment when it only repeats the comment
+	// of the directory it would inherit one from. Such a file gets no header
+	// at all; it does not fall back to inheriting the same text. Files
+	// without a comment of their own still inherit the directory's.
+	DedupComments bool
+
+	// Replacements are old, new string pairs substituted in generated
+	// content before it is written, e.g. "__MODULE__", "github.com/me/app".
+	// Literal content from the spec is written as is.
+	Replacements []string
+
+	// Order is the order nodes are created and reported in: SortType (the
+	// zero value) creates every directory before any file
+	Order SortOrder
+
+	// EOL is the line ending of generated files. Literal content from the
+	// spec is written as is.
+	EOL LineEnding
+
+	// MaxCommentLength shortens comments longer than this many characters,
+	// ending them in an ellipsis, before generators see them. Zero means no
+	// limit.
+	MaxCommentLength int
+
+	// StrictGenerate aborts Apply when a content generator fails, instead
+	// of writing the content the generator fell back to
+	StrictGenerate bool
+
+	// OverwriteOnly, when non-nil, lists the spec paths whose existing files
+	// are overwritten; every other existing file is skipped, whatever
+	// OnConflict says
+	OverwriteOnly []string
+
+	// ContentByPath holds the content of files, keyed by their spec path
+	// ("cmd/main.go"), for callers that build it themselves. It wins over
+	// generated content and the spec's literal content, and is written as
+	// is.
+	ContentByPath map[string][]byte
+
+	// RequireParents makes Apply refuse a spec that leaves the parent
+	// directory of a node undeclared, instead of creating it
+	RequireParents bool
+
+	// ValidateNames makes Validate and Apply refuse a spec with a name that
+	// is unusable on some operating system, such as CON or "a:b.go"; see
+	// CheckNames
+	ValidateNames bool
+
+	// Protected names directories no node may be or lie inside, regardless
+	// of ForceMode. Nil means DefaultProtectedPaths; an empty slice protects
+	// nothing.
+	Protected []string
 }
 
 // NewScaffolder creates a new default scaffolder
@@ -55,49 +215,142 @@ func NewScaffolderWithForce() *DefaultScaffolder {
 	}
 }
 
+// SetContentGenerator replaces the generator that writes new files' content.
+// A nil generator, including a nil pointer of a generator type, is rejected
+// and leaves the current one in place.
+func (s *DefaultScaffolder) SetContentGenerator(g ContentGenerator) error {
+	if isNilGenerator(g) {
+		return errors.New("content generator must not be nil")
+	}
+	s.ContentProvider = g
+	return nil
+}
+
+// isNilGenerator reports whether g is nil or holds a nil pointer, map or
+// func, whose methods would panic when Apply calls them
+func isNilGenerator(g ContentGenerator) bool {
+	if g == nil {
+		return true
+	}
+	switch v := reflect.ValueOf(g); v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Func, reflect.Slice, reflect.Chan, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+// GetContentGenerator returns the generator that writes new files' content
+func (s *DefaultScaffolder) GetContentGenerator() ContentGenerator {
+	return s.ContentProvider
+}
+
+// protected returns the directories this scaffolder must not touch
+func (s *DefaultScaffolder) protected() []string {
+	if s.Protected == nil {
+		return DefaultProtectedPaths
+	}
+	return s.Protected
+}
+
+// overwrites reports whether an existing file at the spec path may be replaced
+func (s *DefaultScaffolder) overwrites(path string) bool {
+	if s.OverwriteOnly == nil {
+		return s.OnConflict == ConflictOverwrite
+	}
+	return slices.Contains(s.OverwriteOnly, cleanNodePath(path))
+}
+
 // ForceMode is no longer used - it's handled in the DefaultScaffolder struct
 // No global variable needed
 
 // Validate performs a dry-run check to see if the scaffold operation would succeed
 func (s *DefaultScaffolder) Validate(root string, nodes []parser.Node) error {
+	if problems := s.ValidationProblems(root, nodes); len(problems) > 0 {
+		return problems[0]
+	}
+	return nil
+}
+
+// ValidationProblems is Validate reporting every problem instead of the
+// first: the first error of each spec check, then one error per existing
+// file in the way of a directory
+func (s *DefaultScaffolder) ValidationProblems(root string, nodes []parser.Node) []error {
+	// Reject specs that contradict themselves before touching the file system
+	checks := []func([]parser.Node) error{
+		CheckConsistency,
+		CheckLinks,
+		func(nodes []parser.Node) error { return CheckProtected(nodes, s.protected()) },
+	}
+	if s.RequireParents {
+		checks = append(checks, CheckParents)
+	}
+	if s.ValidateNames {
+		checks = append(checks, CheckNames)
+	}
+
+	var problems []error
+	for _, check := range checks {
+		if err := check(nodes); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	for _, dir := range FileConflicts(root, nodes) {
+		problems = append(problems, fmt.Errorf("cannot create directory %s: a file with the same name already exists", filepath.Join(root, dir)))
+	}
+	return problems
+}
+
+// FileConflicts returns the directories of nodes, explicit or implied by a
+// path, that exist under root as files, sorted. Apply removes such files
+// when it creates the directory.
+func FileConflicts(root string, nodes []parser.Node) []string {
 	// First generate all directory paths that will need to be created
 	paths := make(map[string]bool) // path -> isDir
 
 	// Mark all explicit directories
 	for _, n := range nodes {
 		if n.IsDir {
-			paths[n.Path] = true
+			paths[cleanNodePath(n.Path)] = true
 		}
 	}
 
-	// Mark all parent directories of files
+	// Mark all parent directories
 	for _, n := range nodes {
-		if !n.IsDir {
-			// Extract all parent directories
-			dir := filepath.Dir(n.Path)
-			for dir != "." {
-				paths[dir] = true
-				dir = filepath.Dir(dir)
-			}
+		dir := filepath.Dir(cleanNodePath(n.Path))
+		for dir != "." && dir != "/" {
+			paths[dir] = true
+			dir = filepath.Dir(dir)
 		}
 	}
 
 	// Check for files that would need to be converted to directories
+	var conflicts []string
 	for dir := range paths {
-		dirPath := filepath.Join(root, dir)
-
 		// Check if the path exists but is a file
-		fileInfo, err := os.Stat(dirPath)
+		fileInfo, err := os.Stat(filepath.Join(root, dir))
 		if err == nil && !fileInfo.IsDir() {
-			return fmt.Errorf("cannot create directory %s: a file with the same name already exists", dirPath)
+			conflicts = append(conflicts, dir)
 		}
 	}
-
-	return nil
+	sort.Strings(conflicts)
+	return conflicts
 }
 
 // VerifyStructure ensures the directory structure matches the specification after creation
 func (s *DefaultScaffolder) VerifyStructure(root string, nodes []parser.Node) error {
+	missingPaths := MissingPaths(root, nodes)
+
+	// If any paths are missing, report the error
+	if len(missingPaths) > 0 {
+		return fmt.Errorf("structure verification failed: missing %d paths including %v",
+			len(missingPaths), missingPaths[:min(3, len(missingPaths))])
+	}
+
+	return nil
+}
+
+// MissingPaths returns the node paths that do not exist under root, sorted
+func MissingPaths(root string, nodes []parser.Node) []string {
 	// Map of all expected paths
 	expectedPaths := make(map[string]bool)
 
@@ -106,109 +359,176 @@ func (s *DefaultScaffolder) VerifyStructure(root string, nodes []parser.Node) er
 		expectedPaths[n.Path] = true
 	}
 
-	// Use a file system walker to verify all expected paths exist
-	missingPaths := []string{}
-
 	// Check each expected path
+	missingPaths := []string{}
 	for path := range expectedPaths {
 		fullPath := filepath.Join(root, path)
-		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+		if _, err := os.Lstat(fullPath); os.IsNotExist(err) {
 			missingPaths = append(missingPaths, path)
 		}
 	}
 
-	// If any paths are missing, report the error
-	if len(missingPaths) > 0 {
-		return fmt.Errorf("structure verification failed: missing %d paths including %v",
-			len(missingPaths), missingPaths[:min(3, len(missingPaths))])
+	sort.Strings(missingPaths)
+	return missingPaths
+}
+
+// ExtraPaths walks root and returns, sorted, every path the nodes do not
+// account for. Directories are reported with a trailing slash and their
+// contents are not listed separately. The lock file and .git are ignored.
+func ExtraPaths(root string, nodes []parser.Node) ([]string, error) {
+	// Every node and every ancestor of a node is expected
+	expected := make(map[string]bool)
+	for _, n := range nodes {
+		for p := cleanNodePath(n.Path); p != "." && p != "/"; p = filepath.Dir(p) {
+			expected[filepath.ToSlash(p)] = true
+		}
 	}
 
-	return nil
+	var extra []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == LockFileName || rel == ".git" {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if expected[rel] {
+			return nil
+		}
+		if d.IsDir() {
+			extra = append(extra, rel+"/")
+			return filepath.SkipDir
+		}
+		extra = append(extra, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(extra)
+	return extra, nil
 }
 
 // Apply walks nodes, creating directories and files under root.
 func (s *DefaultScaffolder) Apply(root string, nodes []parser.Node, onCreate CreationCallback) error {
-	var stack []parser.Node
-	// Process nodes in a structured way
+	_, err := s.ApplyWithResult(root, nodes, onCreate)
+	return err
+}
 
-	// Process nodes in two phases: first directories, then files
-	// First: Create a map to deduplicate paths and identify directories
-	paths := make(map[string]bool) // path -> isDir
+// ApplyWithResult is Apply that also returns what happened to every path. The
+// result is returned even when Apply fails, ending in an ActionError entry.
+func (s *DefaultScaffolder) ApplyWithResult(root string, nodes []parser.Node, onCreate CreationCallback) (*ApplyResult, error) {
+	res := &ApplyResult{}
+	if err := s.apply(root, nodes, onCreate, res); err != nil {
+		res.add("", ActionError, false, err.Error())
+		return res, err
+	}
+	return res, nil
+}
 
-	// Mark all explicit directories
-	for _, n := range nodes {
-		if n.IsDir {
-			paths[n.Path] = true
+// apply does the work of Apply, recording each action in res
+func (s *DefaultScaffolder) apply(root string, nodes []parser.Node, onCreate CreationCallback, res *ApplyResult) error {
+	// Refuse to start on a self-contradicting spec rather than half-applying it
+	if err := CheckConsistency(nodes); err != nil {
+		return err
+	}
+	if err := CheckLinks(nodes); err != nil {
+		return err
+	}
+	if err := CheckProtected(nodes, s.protected()); err != nil {
+		return err
+	}
+	if s.RequireParents {
+		if err := CheckParents(nodes); err != nil {
+			return err
 		}
 	}
+	if s.ValidateNames {
+		if err := CheckNames(nodes); err != nil {
+			return err
+		}
+	}
+	if len(s.Replacements)%2 != 0 {
+		return fmt.Errorf("replacements must be old, new pairs; got %d strings", len(s.Replacements))
+	}
+	if isNilGenerator(s.ContentProvider) {
+		return errors.New("no content generator set: use NewScaffolder or SetContentGenerator")
+	}
+	if p, ok := s.ContentProvider.(SpecPlanner); ok {
+		p.PlanSpec(nodes)
+	}
 
-	// Mark all parent directories of files
+	// Files without a comment inherit the one of the last commented
+	// directory before them in the input, whatever order they are created in
+	dirComments := make(map[string]string) // file path -> inherited comment
+	var lastComment string
 	for _, n := range nodes {
 		if !n.IsDir {
-			// Extract all parent directories
-			dir := filepath.Dir(n.Path)
-			for dir != "." {
-				paths[dir] = true
-				dir = filepath.Dir(dir)
-			}
+			dirComments[n.Path] = lastComment
+		} else if n.Comment != "" {
+			lastComment = n.Comment
 		}
 	}
 
-	// First create all directories
-	for dir, isDir := range paths {
-		if isDir {
-			dirPath := filepath.Join(root, dir)
-
-			// Special handling for hidden directories which often exist as files first
-			isHidden := len(dir) > 0 && dir[0] == '.'
-
-			// Check if path exists and is a file
-			fileInfo, err := os.Stat(dirPath)
-			if err == nil && !fileInfo.IsDir() {
-				// Path exists but is a file - remove it before creating directory
-				if err := os.Remove(dirPath); err != nil {
-					if s.ForceMode {
-						// In force mode, try more aggressively to remove the file
-						if removeErr := os.RemoveAll(dirPath); removeErr != nil {
-							return fmt.Errorf("cannot convert file to directory even in force mode: %s: %w", dirPath, removeErr)
-						}
-						// For hidden directories, we log this as it's a common source of issues
-						if isHidden {
-							fmt.Fprintf(os.Stderr, "Note: Force converted file to directory: %s\n", dirPath)
-						}
-					} else {
-						return fmt.Errorf("cannot convert file to directory: %s: %w", dirPath, err)
-					}
-				} else {
-					// Successfully removed the file
-					// For hidden directories, we log this as it's a common source of issues
-					if isHidden {
-						fmt.Fprintf(os.Stderr, "Note: Converting file to directory: %s\n", dirPath)
-					}
-				}
-			}
-
-			if onCreate != nil {
-				onCreate(dirPath, true)
+	// Create the nodes in s.Order, each directory, declared or only implied
+	// by a path, before the first node inside it, so callbacks always see a
+	// parent first
+	created := make(map[string]bool)
+	mkdirs := func(dir string, self bool) error {
+		var dirs []string
+		if self {
+			dirs = append(dirs, dir)
+		}
+		for d := filepath.Dir(dir); d != "." && d != "/"; d = filepath.Dir(d) {
+			dirs = append(dirs, d)
+		}
+		for i := len(dirs) - 1; i >= 0; i-- {
+			if created[dirs[i]] {
+				continue
 			}
-
-			// Create the directory
-			if err := os.MkdirAll(dirPath, 0o755); err != nil {
+			created[dirs[i]] = true
+			if err := s.applyDir(root, dirs[i], onCreate, res); err != nil {
 				return err
 			}
 		}
+		return nil
 	}
 
-	// Now process file nodes
-	for _, n := range nodes {
+	for _, n := range SortNodes(nodes, s.Order) {
+		if err := mkdirs(cleanNodePath(n.Path), n.IsDir); err != nil {
+			return err
+		}
 		if n.IsDir {
-			stack = append(stack, n)
+			s.applyOwnership(filepath.Join(root, n.Path), n)
 			continue
 		}
 
 		full := filepath.Join(root, n.Path)
 
+		// Symlinks have their own conflict handling and no content
+		if n.LinkTarget != "" {
+			if err := s.applyLink(full, n, onCreate, res); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Content supplied by the caller is written like literal content
+		if data, ok := s.ContentByPath[cleanNodePath(n.Path)]; ok {
+			n.Content = append([]byte{}, data...)
+		}
+
 		// Check if the path exists and handle conflicts
+		action, detail := ActionCreated, ""
 		fileInfo, err := os.Stat(full)
 		if err == nil {
 			// Path exists, check if it's already the correct type
@@ -216,6 +536,7 @@ func (s *DefaultScaffolder) Apply(root string, nodes []parser.Node, onCreate Cre
 			if existingIsDir && !n.IsDir {
 				// It's a directory but we want to create a file
 				// This is a conflict, better skip it
+				res.add(n.Path, ActionSkipped, false, "a directory is in the way")
 				continue
 			} else if !existingIsDir && n.IsDir {
 				// It's a file but we want to create a directory
@@ -232,23 +553,62 @@ func (s *DefaultScaffolder) Apply(root string, nodes []parser.Node, onCreate Cre
 				continue
 			} else if !existingIsDir && !n.IsDir {
 				// It's a file and we want to create a file
-				// Skip - don't overwrite existing files
-				fmt.Fprintf(os.Stderr, "Note: Skipping existing file: %s\n", full)
-				continue
+				// Merge files we understand, when asked to
+				if merge, ok := contentMergers[filepath.Base(n.Path)]; ok && s.MergeExisting && n.Content == nil {
+					if err := s.mergeFile(full, n, merge, res); err != nil {
+						return err
+					}
+					continue
+				}
+				// Skip unless the conflict policy allows overwriting or
+				// writing next to the existing file
+				switch {
+				case s.overwrites(n.Path):
+					action = ActionOverwritten
+				case s.OnConflict == ConflictRename && s.OverwriteOnly == nil:
+					if full, err = freeName(full); err != nil {
+						return err
+					}
+					action, detail = ActionRenamed, "as "+filepath.Base(full)
+				default:
+					s.notef("Skipping existing file: %s", full)
+					res.add(n.Path, ActionSkipped, false, "already exists")
+					continue
+				}
 			}
 		}
 
-		// Determine which comment to use
+		// Determine which comment to use: the file's own, else the nearest
+		// directory's
+		inherited := dirComments[n.Path]
 		comment := n.Comment
 		if comment == "" {
-			for i := len(stack) - 1; i >= 0; i-- {
-				if stack[i].Comment != "" {
-					comment = stack[i].Comment
-					break
-				}
+			comment = inherited
+		} else if s.DedupComments && comment == inherited {
+			comment = "" // dropped, not inherited back
+		}
+		if s.NoComments {
+			comment = ""
+		}
+		comment = truncateComment(comment, s.MaxCommentLength)
+
+		// Literal content from the spec wins over generated content
+		var content []byte
+		if n.Content != nil {
+			content = n.Content
+		} else {
+			generated, err := s.generate(n, comment)
+			if err != nil {
+				return err
 			}
+			content = []byte(generated)
 		}
 
+		if action == ActionOverwritten && s.Backup {
+			if err := backupFile(full); err != nil {
+				return err
+			}
+		}
 		if onCreate != nil {
 			onCreate(full, false)
 		}
@@ -256,17 +616,204 @@ func (s *DefaultScaffolder) Apply(root string, nodes []parser.Node, onCreate Cre
 			return err
 		}
 
-		// Generate content using the content provider
-		// The provider already handles main.go files correctly
-		content := s.ContentProvider.GenerateContent(n.Path, comment)
+		// Scripts marked @executable get an interpreter line and the x bit
+		perm := os.FileMode(0o644)
+		if isExecutable(n) {
+			if n.Content == nil {
+				content = []byte(withShebang(n.Path, string(content)))
+			}
+			perm = 0o755
+		}
+		if n.Content == nil {
+			content = []byte(s.EOL.normalize(string(content)))
+		}
 
-		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+		if err := os.WriteFile(full, content, perm); err != nil {
 			return err
 		}
+		// WriteFile keeps the mode of a file it overwrites
+		if isExecutable(n) {
+			if err := os.Chmod(full, perm); err != nil {
+				return err
+			}
+		}
+		s.applyOwnership(full, n)
+		res.add(n.Path, action, false, detail)
 	}
 
 	// Optional: Verify the scaffolded structure matches the specification
-	return s.VerifyStructure(root, nodes)
+	if err := s.VerifyStructure(root, nodes); err != nil {
+		return err
+	}
+
+	// Record checksums so a later run can detect drift
+	if s.WriteLock {
+		return WriteLockFile(root, nodes)
+	}
+	return nil
+}
+
+// applyDir creates the directory dir under root, converting a file in its
+// way, and records what happened in res
+func (s *DefaultScaffolder) applyDir(root, dir string, onCreate CreationCallback, res *ApplyResult) error {
+	dirPath := filepath.Join(root, dir)
+
+	// Special handling for hidden directories which often exist as files first
+	isHidden := len(dir) > 0 && dir[0] == '.'
+
+	// Check if path exists and is a file
+	fileInfo, err := os.Stat(dirPath)
+	action := ActionCreated
+	if err == nil && fileInfo.IsDir() {
+		action = ActionExists
+	}
+	if err == nil && !fileInfo.IsDir() {
+		action = ActionConverted
+		// Path exists but is a file - remove it before creating directory
+		if err := os.Remove(dirPath); err != nil {
+			if s.ForceMode {
+				// In force mode, try more aggressively to remove the file
+				if removeErr := os.RemoveAll(dirPath); removeErr != nil {
+					return fmt.Errorf("cannot convert file to directory even in force mode: %s: %w", dirPath, removeErr)
+				}
+				// For hidden directories, we log this as it's a common source of issues
+				if isHidden {
+					s.notef("Force converted file to directory: %s", dirPath)
+				}
+			} else {
+				return fmt.Errorf("cannot convert file to directory: %s: %w", dirPath, err)
+			}
+		} else {
+			// Successfully removed the file
+			// For hidden directories, we log this as it's a common source of issues
+			if isHidden {
+				s.notef("Converting file to directory: %s", dirPath)
+			}
+		}
+	}
+
+	if onCreate != nil {
+		onCreate(dirPath, true)
+	}
+
+	// Create the directory
+	if err := os.MkdirAll(dirPath, 0o755); err != nil {
+		return err
+	}
+	res.add(filepath.ToSlash(dir), action, true, "")
+	return nil
+}
+
+// mergeFile combines the existing file at full with freshly generated content
+// and rewrites it only when the merge changed something
+func (s *DefaultScaffolder) mergeFile(full string, n parser.Node, merge func(existing, generated string) string, res *ApplyResult) error {
+	existing, err := os.ReadFile(full)
+	if err != nil {
+		return err
+	}
+	generated, err := s.generate(n, truncateComment(n.Comment, s.MaxCommentLength))
+	if err != nil {
+		return err
+	}
+	merged := merge(string(existing), generated)
+	if merged == string(existing) {
+		res.add(n.Path, ActionExists, false, "nothing to merge")
+		return nil
+	}
+	// The merged lines come from both sides, so give them one line ending
+	merged = s.EOL.normalize(merged)
+	if err := os.WriteFile(full, []byte(merged), 0o644); err != nil {
+		return err
+	}
+	res.add(n.Path, ActionMerged, false, "")
+	return nil
+}
+
+// truncateComment shortens comment to max characters, the last of them an
+// ellipsis. A max of zero or less keeps the comment whole.
+func truncateComment(comment string, max int) string {
+	runes := []rune(comment)
+	if max <= 0 || len(runes) <= max {
+		return comment
+	}
+	return strings.TrimRight(string(runes[:max-1]), " \t") + "…"
+}
+
+// generate produces the content for file node n, passing the whole node to
+// generators that accept it, with Replacements applied. A failing generator
+// is an error under StrictGenerate; otherwise it is noted and the content it
+// fell back to is used.
+func (s *DefaultScaffolder) generate(n parser.Node, comment string) (string, error) {
+	content, err := tryGenerate(s.ContentProvider, n, comment)
+	if err != nil && s.StrictGenerate {
+		return "", fmt.Errorf("cannot generate %s: %w", n.Path, err)
+	}
+	if err != nil {
+		s.notef("%v; writing fallback content to %s", err, n.Path)
+	}
+	return s.replace(content), nil
+}
+
+// tryGenerate asks g for n's content through the most capable interface it
+// implements: FallibleContentGenerator, NodeContentGenerator, then
+// ContentGenerator
+func tryGenerate(g ContentGenerator, n parser.Node, comment string) (string, error) {
+	if fg, ok := g.(FallibleContentGenerator); ok {
+		return fg.TryGenerateContent(n, comment)
+	}
+	if ng, ok := g.(NodeContentGenerator); ok {
+		return ng.GenerateNodeContent(n, comment), nil
+	}
+	return g.GenerateContent(n.Path, comment), nil
+}
+
+// replace applies Replacements to generated content
+func (s *DefaultScaffolder) replace(content string) string {
+	if len(s.Replacements) == 0 {
+		return content
+	}
+	return strings.NewReplacer(s.Replacements...).Replace(content)
+}
+
+// notef writes a "Note:" line to the scaffolder's log
+func (s *DefaultScaffolder) notef(format string, args ...any) {
+	notef(s.Log, format, args...)
+}
+
+// notef writes a "Note:" line to w, or to os.Stderr when w is nil
+func notef(w io.Writer, format string, args ...any) {
+	if w == nil {
+		w = os.Stderr
+	}
+	fmt.Fprintf(w, "Note: "+format+"\n", args...)
+}
+
+// freeName returns the first of path's numbered variants, "a.1.go",
+// "a.2.go" and so on, that does not exist
+func freeName(path string) (string, error) {
+	dir, name := filepath.Split(path)
+	ext := filepath.Ext(name)
+	if ext == name {
+		ext = "" // a dotfile such as .env is all name
+	}
+	stem := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		candidate := filepath.Join(dir, fmt.Sprintf("%s.%d%s", stem, i, ext))
+		if _, err := os.Lstat(candidate); errors.Is(err, fs.ErrNotExist) {
+			return candidate, nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+}
+
+// backupFile moves an existing file aside to <path>.bak so it can be recovered
+// after an overwrite. An older backup with the same name is replaced.
+func backupFile(path string) error {
+	if err := os.Rename(path, path+".bak"); err != nil {
+		return fmt.Errorf("cannot back up %s: %w", path, err)
+	}
+	return nil
 }
 
 // min returns the minimum of two integers
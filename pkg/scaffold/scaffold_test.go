@@ -1,8 +1,12 @@
 package scaffold_test
 
 import (
+	"bytes"
+	"encoding/base64"
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -89,6 +93,32 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestValidationProblems(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"conf", "docs"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("blocker"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	nodes := []parser.Node{
+		{Path: "docs/", IsDir: true},
+		{Path: "conf/app.yaml"},
+		{Path: "passwd", LinkTarget: "/etc/passwd"},
+	}
+
+	s := scaffold.NewScaffolder()
+	problems := s.ValidationProblems(root, nodes)
+	if len(problems) != 3 {
+		t.Fatalf("ValidationProblems() = %v, want the link and both conflicts", problems)
+	}
+	if err := s.Validate(root, nodes); err == nil || err.Error() != problems[0].Error() {
+		t.Errorf("Validate() = %v, want the first problem %v", err, problems[0])
+	}
+	if got := scaffold.FileConflicts(root, nodes); !reflect.DeepEqual(got, []string{"conf", "docs"}) {
+		t.Errorf("FileConflicts() = %v, want [conf docs]", got)
+	}
+}
+
 func TestApply(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -146,3 +176,1460 @@ func TestApply(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyBackup(t *testing.T) {
+	root := t.TempDir()
+	target := filepath.Join(root, "notes.md")
+	if err := os.WriteFile(target, []byte("original\n"), 0644); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	s := scaffold.NewScaffolder()
+	s.OnConflict = scaffold.ConflictOverwrite
+	s.Backup = true
+
+	nodes := []parser.Node{{Path: "notes.md", IsDir: false, Comment: "regenerated"}}
+	if err := s.Apply(root, nodes, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	backup, err := os.ReadFile(target + ".bak")
+	if err != nil {
+		t.Fatalf("expected backup file: %v", err)
+	}
+	if string(backup) != "original\n" {
+		t.Errorf("backup content = %q, want %q", backup, "original\n")
+	}
+
+	data, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatalf("expected target file: %v", err)
+	}
+	if !strings.Contains(string(data), "regenerated") {
+		t.Errorf("target was not overwritten, got %q", data)
+	}
+}
+
+func TestCheckConsistency(t *testing.T) {
+	nodes := []parser.Node{
+		{Path: "config", IsDir: false, Comment: "settings", Line: 2},
+		{Path: "config/app.yaml", IsDir: false, Comment: "", Line: 5},
+	}
+
+	err := scaffold.CheckConsistency(nodes)
+	if err == nil {
+		t.Fatal("expected an error for a path used as both file and directory")
+	}
+	for _, want := range []string{`"config" (line 2)`, `"config/app.yaml" (line 5)`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+
+	// Apply must refuse the same spec without creating anything
+	root := t.TempDir()
+	if err := scaffold.NewScaffolder().Apply(root, nodes, nil); err == nil {
+		t.Error("Apply() accepted an inconsistent spec")
+	}
+	if entries, _ := os.ReadDir(root); len(entries) != 0 {
+		t.Errorf("Apply() created %d entries for an inconsistent spec", len(entries))
+	}
+
+	// A well-formed spec passes
+	ok := []parser.Node{
+		{Path: "config/", IsDir: true},
+		{Path: "config/app.yaml", IsDir: false},
+	}
+	if err := scaffold.CheckConsistency(ok); err != nil {
+		t.Errorf("CheckConsistency() unexpected error: %v", err)
+	}
+}
+
+func TestLockFileDetectsDrift(t *testing.T) {
+	root := t.TempDir()
+	s := scaffold.NewScaffolder()
+	s.WriteLock = true
+
+	nodes := []parser.Node{
+		{Path: "svc/", IsDir: true},
+		{Path: "svc/api.go", IsDir: false, Comment: "api"},
+		{Path: "README.md", IsDir: false, Comment: "docs"},
+	}
+	if err := s.Apply(root, nodes, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if err := scaffold.VerifyLockFile(root); err != nil {
+		t.Fatalf("VerifyLockFile() on a fresh scaffold error = %v", err)
+	}
+
+	// Edit one file and delete another
+	if err := os.WriteFile(filepath.Join(root, "svc/api.go"), []byte("package svc\n// edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(root, "README.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	err := scaffold.VerifyLockFile(root)
+	if err == nil {
+		t.Fatal("VerifyLockFile() did not detect drift")
+	}
+	for _, want := range []string{"modified: svc/api.go", "missing: README.md"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("drift error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	nodes := []parser.Node{
+		{Path: "src/", IsDir: true},
+		{Path: "src/app/", IsDir: true},
+		{Path: "src/app/main.go", IsDir: false, Comment: "entry"},
+		{Path: "docs/guide.md", IsDir: false},
+	}
+
+	flat, err := scaffold.Flatten(nodes)
+	if err != nil {
+		t.Fatalf("Flatten() error = %v", err)
+	}
+	if len(flat) != 2 || flat[0].Path != "main.go" || flat[1].Path != "guide.md" {
+		t.Fatalf("Flatten() = %+v, want main.go and guide.md", flat)
+	}
+	if flat[0].Comment != "entry" {
+		t.Errorf("Flatten() lost the comment: %+v", flat[0])
+	}
+
+	root := t.TempDir()
+	if err := scaffold.NewScaffolder().Apply(root, flat, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	for _, name := range []string{"main.go", "guide.md"} {
+		if _, err := os.Stat(filepath.Join(root, name)); err != nil {
+			t.Errorf("expected %s at the root: %v", name, err)
+		}
+	}
+
+	// Duplicate base names are reported
+	dup := []parser.Node{
+		{Path: "a/util.go", IsDir: false, Line: 2},
+		{Path: "b/util.go", IsDir: false, Line: 4},
+	}
+	if _, err := scaffold.Flatten(dup); err == nil || !strings.Contains(err.Error(), `"a/util.go" (line 2)`) {
+		t.Errorf("expected a duplicate name error, got %v", err)
+	}
+}
+
+func TestForceSemantics(t *testing.T) {
+	nodes := []parser.Node{
+		{Path: "conf/", IsDir: true},
+		{Path: "conf/app.yaml", IsDir: false, Comment: "generated"},
+		{Path: "keep.md", IsDir: false, Comment: "generated"},
+	}
+
+	setup := func(t *testing.T) string {
+		root := t.TempDir()
+		// A file blocking a directory, and an existing file the spec also names
+		if err := os.WriteFile(filepath.Join(root, "conf"), []byte("blocker"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, "keep.md"), []byte("mine"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return root
+	}
+
+	t.Run("force converts blocking files but keeps contents", func(t *testing.T) {
+		root := setup(t)
+		if err := scaffold.NewScaffolderWithForce().Apply(root, nodes, nil); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+		if info, err := os.Stat(filepath.Join(root, "conf")); err != nil || !info.IsDir() {
+			t.Errorf("conf was not converted to a directory: %v", err)
+		}
+		if data, _ := os.ReadFile(filepath.Join(root, "keep.md")); string(data) != "mine" {
+			t.Errorf("keep.md was overwritten under -force: %q", data)
+		}
+	})
+
+	t.Run("overwrite policy replaces file contents", func(t *testing.T) {
+		root := setup(t)
+		s := scaffold.NewScaffolder()
+		s.OnConflict = scaffold.ConflictOverwrite
+		if err := s.Apply(root, nodes, nil); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+		if data, _ := os.ReadFile(filepath.Join(root, "keep.md")); !strings.Contains(string(data), "generated") {
+			t.Errorf("keep.md was not overwritten: %q", data)
+		}
+	})
+}
+
+func TestApplyConflictRename(t *testing.T) {
+	root := t.TempDir()
+	for name, data := range map[string]string{"a.go": "package mine\n", ".env": "KEY=1\n", "b.go": "b", "b.1.go": "b1"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := scaffold.NewScaffolder()
+	s.OnConflict = scaffold.ConflictRename
+	var written []string
+	res, err := s.ApplyWithResult(root, []parser.Node{{Path: "a.go"}, {Path: ".env"}, {Path: "b.go"}}, func(path string, isDir bool) {
+		written = append(written, filepath.Base(path))
+	})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	if want := []string{".env.1", "a.1.go", "b.2.go"}; !reflect.DeepEqual(written, want) {
+		t.Errorf("Apply() wrote %q, want %q", written, want)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "a.go")); string(data) != "package mine\n" {
+		t.Errorf("a.go = %q, want it untouched", data)
+	}
+	if data, err := os.ReadFile(filepath.Join(root, "a.1.go")); err != nil || !strings.HasPrefix(string(data), "package main\n") {
+		t.Errorf("a.1.go = %q, %v; want generated content", data, err)
+	}
+	want := []scaffold.ResultEntry{
+		{Path: ".env", Action: scaffold.ActionRenamed, Detail: "as .env.1"},
+		{Path: "a.go", Action: scaffold.ActionRenamed, Detail: "as a.1.go"},
+		{Path: "b.go", Action: scaffold.ActionRenamed, Detail: "as b.2.go"},
+	}
+	if !reflect.DeepEqual(res.Entries, want) {
+		t.Errorf("result = %+v, want %+v", res.Entries, want)
+	}
+}
+
+func TestApplySimpleNestedPaths(t *testing.T) {
+	nodes, err := parser.Parse(strings.NewReader("internal/config/\nsrc/app/handlers/user.go\n"))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	root := t.TempDir()
+	if err := scaffold.NewScaffolder().Apply(root, nodes, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	for _, dir := range []string{"internal", "internal/config", "src", "src/app", "src/app/handlers"} {
+		if info, err := os.Stat(filepath.Join(root, dir)); err != nil || !info.IsDir() {
+			t.Errorf("expected directory %s: %v", dir, err)
+		}
+	}
+}
+
+func TestSeedEntries(t *testing.T) {
+	nodes := []parser.Node{
+		{Path: "app/", IsDir: true},
+		{Path: "app/models/", IsDir: true},
+		{Path: "app/views/", IsDir: true},
+		{Path: "app/views/home.py", IsDir: false},
+	}
+
+	paths := func(ns []parser.Node) []string {
+		var out []string
+		for _, n := range ns {
+			out = append(out, n.Path)
+		}
+		return out
+	}
+
+	tests := []struct {
+		lang string
+		want []string
+	}{
+		{"python", []string{"app/", "app/__init__.py", "app/models/", "app/models/__init__.py", "app/views/", "app/views/home.py"}},
+		{"rust", []string{"app/", "app/mod.rs", "app/models/", "app/models/mod.rs", "app/views/", "app/views/home.py"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			seeded, err := scaffold.SeedEntries(nodes, tt.lang)
+			if err != nil {
+				t.Fatalf("SeedEntries() error = %v", err)
+			}
+			if got := paths(seeded); strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("SeedEntries() = %v, want %v", got, tt.want)
+			}
+
+			root := t.TempDir()
+			if err := scaffold.NewScaffolder().Apply(root, seeded, nil); err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			entry := tt.want[1]
+			if _, err := os.Stat(filepath.Join(root, entry)); err != nil {
+				t.Errorf("expected %s on disk: %v", entry, err)
+			}
+		})
+	}
+
+	if _, err := scaffold.SeedEntries(nodes, "cobol"); err == nil {
+		t.Error("expected an error for an unknown language")
+	}
+}
+
+func TestApplySymlink(t *testing.T) {
+	nodes := []parser.Node{
+		{Path: "v1.2.0/", IsDir: true},
+		{Path: "v1.2.0/notes.md", IsDir: false},
+		{Path: "latest", IsDir: false, LinkTarget: "v1.2.0"},
+	}
+
+	root := t.TempDir()
+	if err := scaffold.NewScaffolder().Apply(root, nodes, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	target, err := os.Readlink(filepath.Join(root, "latest"))
+	if err != nil {
+		t.Fatalf("latest is not a symlink: %v", err)
+	}
+	if target != "v1.2.0" {
+		t.Errorf("latest -> %q, want v1.2.0", target)
+	}
+	if _, err := os.Stat(filepath.Join(root, "latest", "notes.md")); err != nil {
+		t.Errorf("link does not resolve: %v", err)
+	}
+
+	// An existing link is kept under the default policy and replaced on overwrite
+	nodes[2].LinkTarget = "v2.0.0"
+	if err := scaffold.NewScaffolder().Apply(root, nodes[2:], nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if target, _ := os.Readlink(filepath.Join(root, "latest")); target != "v1.2.0" {
+		t.Errorf("skip policy replaced the link: latest -> %q", target)
+	}
+	s := scaffold.NewScaffolder()
+	s.OnConflict = scaffold.ConflictOverwrite
+	if err := s.Apply(root, nodes[2:], nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if target, _ := os.Readlink(filepath.Join(root, "latest")); target != "v2.0.0" {
+		t.Errorf("overwrite policy kept the old link: latest -> %q", target)
+	}
+
+	// Links follow the rename policy and the overwrite list like files do
+	nodes[2].LinkTarget = "v3.0.0"
+	s = scaffold.NewScaffolder()
+	s.OnConflict = scaffold.ConflictRename
+	res, err := s.ApplyWithResult(root, nodes[2:], nil)
+	if err != nil {
+		t.Fatalf("ApplyWithResult() error = %v", err)
+	}
+	if target, _ := os.Readlink(filepath.Join(root, "latest")); target != "v2.0.0" {
+		t.Errorf("rename policy replaced the link: latest -> %q", target)
+	}
+	if target, _ := os.Readlink(filepath.Join(root, "latest.1")); target != "v3.0.0" {
+		t.Errorf("rename policy wrote latest.1 -> %q, want v3.0.0", target)
+	}
+	if want := "as latest.1 -> v3.0.0"; len(res.Entries) != 1 || res.Entries[0].Action != scaffold.ActionRenamed || res.Entries[0].Detail != want {
+		t.Errorf("result = %+v, want latest renamed %q", res.Entries, want)
+	}
+	for _, only := range [][]string{{}, {"latest"}} {
+		s = scaffold.NewScaffolder()
+		s.OnConflict = scaffold.ConflictOverwrite
+		s.OverwriteOnly = only
+		if err := s.Apply(root, nodes[2:], nil); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+		want := "v2.0.0" // not listed, so kept
+		if len(only) > 0 {
+			want = "v3.0.0"
+		}
+		if target, _ := os.Readlink(filepath.Join(root, "latest")); target != want {
+			t.Errorf("OverwriteOnly=%v: latest -> %q, want %q", only, target, want)
+		}
+	}
+
+	// Targets may not escape the root
+	for _, bad := range []string{"../../outside", "/etc/passwd", "x/../../../outside"} {
+		escape := []parser.Node{{Path: "a/link", LinkTarget: bad}}
+		if err := scaffold.NewScaffolder().Validate(t.TempDir(), escape); err == nil {
+			t.Errorf("Validate() accepted link target %q", bad)
+		}
+	}
+
+	// And neither may the links themselves
+	for _, bad := range []string{"../outside", "/tmp/link", "a/../../outside"} {
+		escape := []parser.Node{{Path: bad, LinkTarget: "x"}}
+		if err := scaffold.NewScaffolder().Validate(t.TempDir(), escape); err == nil {
+			t.Errorf("Validate() accepted link path %q", bad)
+		}
+	}
+}
+
+func TestApplyExecutableShebang(t *testing.T) {
+	exec := map[string]string{"executable": ""}
+	nodes := []parser.Node{
+		{Path: "scripts/", IsDir: true},
+		{Path: "scripts/deploy.sh", Comment: "deploy to staging", Attrs: exec},
+		{Path: "scripts/migrate.py", Comment: "run migrations", Attrs: exec},
+		{Path: "scripts/helpers.sh", Comment: "sourced helpers"},
+	}
+
+	root := t.TempDir()
+	if err := scaffold.NewScaffolder().Apply(root, nodes, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		want string
+		exec bool
+	}{
+		{"deploy.sh", "#!/usr/bin/env bash\n# deploy to staging\n", true},
+		{"migrate.py", "#!/usr/bin/env python3\n# run migrations\n", true},
+		{"helpers.sh", "# sourced helpers\n", false},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(root, "scripts", tt.name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("ReadFile(%s) error = %v", tt.name, err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, data, tt.want)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm()&0o100 != 0; got != tt.exec {
+			t.Errorf("%s executable = %v, want %v", tt.name, got, tt.exec)
+		}
+	}
+}
+
+func TestApplyOwner(t *testing.T) {
+	const spec = `app/
+├── secrets/
+│   └── token.txt  # api token @owner=no-such-user-t2s
+└── run.sh         # @owner=root:root
+`
+	nodes, err := parser.Parse(strings.NewReader(spec))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := []map[string]string{
+		nil,
+		{"owner": "no-such-user-t2s"},
+		{"owner": "root:root"},
+	}
+	for i, n := range nodes {
+		if !reflect.DeepEqual(n.Attrs, want[i]) {
+			t.Errorf("%s Attrs = %v, want %v", n.Path, n.Attrs, want[i])
+		}
+	}
+
+	root := t.TempDir()
+	var log strings.Builder
+	s := scaffold.NewScaffolder()
+	s.Log = &log
+	if err := s.Apply(root, nodes, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	// An owner that cannot be set is noted, not fatal
+	token := filepath.Join(root, "secrets", "token.txt")
+	if !strings.Contains(log.String(), "Note: cannot set the owner of "+token+" to no-such-user-t2s") {
+		t.Errorf("log = %q, want a note about the unknown user", log.String())
+	}
+	if os.Geteuid() != 0 {
+		if !strings.Contains(log.String(), "Note: cannot set the owner of "+filepath.Join(root, "run.sh")) {
+			t.Errorf("log = %q, want a note about chown to root failing", log.String())
+		}
+	}
+}
+
+func TestApplyLogsToInjectedWriter(t *testing.T) {
+	root := t.TempDir()
+	existing := filepath.Join(root, "notes.md")
+	if err := os.WriteFile(existing, []byte("keep me\n"), 0644); err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+
+	var log strings.Builder
+	s := scaffold.NewScaffolder()
+	s.Log = &log
+
+	nodes := []parser.Node{{Path: "notes.md", IsDir: false, Comment: "regenerated"}}
+	if err := s.Apply(root, nodes, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	want := "Note: Skipping existing file: " + existing + "\n"
+	if log.String() != want {
+		t.Errorf("log = %q, want %q", log.String(), want)
+	}
+}
+
+func TestApplyLiteralContent(t *testing.T) {
+	nodes := []parser.Node{
+		{Path: "config.yaml", Comment: "service settings", Content: []byte("port: 8080\n")},
+		{Path: "empty.txt", Content: []byte{}},
+		{Path: "notes.md", Comment: "generated"},
+	}
+
+	root := t.TempDir()
+	if err := scaffold.NewScaffolder().Apply(root, nodes, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	want := map[string]string{
+		"config.yaml": "port: 8080\n",
+		"empty.txt":   "",
+		"notes.md":    "<!-- generated -->\n",
+	}
+	for name, content := range want {
+		data, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			t.Fatalf("ReadFile(%s) error = %v", name, err)
+		}
+		if string(data) != content {
+			t.Errorf("%s = %q, want %q", name, data, content)
+		}
+	}
+}
+
+func TestApplyContentByPath(t *testing.T) {
+	nodes := []parser.Node{
+		{Path: "cmd/", IsDir: true},
+		{Path: "cmd/main.go", Comment: "entry point"},
+		{Path: "config.yaml", Content: []byte("port: 8080\n")},
+		{Path: "deploy.sh", Attrs: map[string]string{"executable": ""}},
+		{Path: "notes.md", Comment: "generated"},
+	}
+
+	root := t.TempDir()
+	s := scaffold.NewScaffolder()
+	s.EOL = scaffold.LineEndingCRLF
+	s.ContentByPath = map[string][]byte{
+		"cmd/main.go": []byte("package main\n\nfunc main() {}\n"),
+		"config.yaml": []byte("port: 9090\n"),
+		"deploy.sh":   nil,
+		"missing.txt": []byte("not in the spec"),
+	}
+	if err := s.Apply(root, nodes, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	for name, want := range map[string]string{
+		"cmd/main.go": "package main\n\nfunc main() {}\n", // no CRLF: written as is
+		"config.yaml": "port: 9090\n",                     // wins over the spec's content
+		"deploy.sh":   "",                                 // nil is empty, not generated
+		"notes.md":    "<!-- generated -->\r\n",
+	} {
+		data, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			t.Fatalf("ReadFile(%s) error = %v", name, err)
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", name, data, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "missing.txt")); !os.IsNotExist(err) {
+		t.Errorf("Apply() created a path only ContentByPath names: %v", err)
+	}
+}
+
+func TestApplyBase64Content(t *testing.T) {
+	icon := []byte{0x00, 0x00, 0x01, 0x00, 0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, 0xff}
+	spec := "web/\n└── favicon.ico # @base64=" + base64.StdEncoding.EncodeToString(icon) + "\n"
+	nodes, err := parser.Parse(strings.NewReader(spec))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	root := t.TempDir()
+	if err := scaffold.NewScaffolder().Apply(root, nodes, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, "favicon.ico"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, icon) {
+		t.Errorf("favicon.ico = %x, want %x", data, icon)
+	}
+}
+
+func TestApplyCallsBackParentsFirst(t *testing.T) {
+	nodes := []parser.Node{
+		{Path: "z/y/x/deep.go", IsDir: false},
+		{Path: "a-b/", IsDir: true},
+		{Path: "a/b/c/", IsDir: true},
+		{Path: "a/", IsDir: true},
+		{Path: ".github/workflows/ci.yml", IsDir: false},
+		{Path: "m/n/file.txt", IsDir: false},
+	}
+
+	for i := 0; i < 20; i++ { // map order varies between runs
+		root := t.TempDir()
+		seen := make(map[string]bool)
+		err := scaffold.NewScaffolder().Apply(root, nodes, func(path string, isDir bool) {
+			rel, _ := filepath.Rel(root, path)
+			if parent := filepath.Dir(rel); parent != "." && !seen[parent] {
+				t.Errorf("%s reported before its parent %s", rel, parent)
+			}
+			if isDir {
+				seen[rel] = true
+			}
+		})
+		if err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+	}
+}
+
+func TestApplyNoComments(t *testing.T) {
+	nodes := []parser.Node{
+		{Path: "internal/", IsDir: true, Comment: "secret roadmap"},
+		{Path: "internal/billing/", IsDir: true},
+		{Path: "internal/billing/invoice.go", Comment: "ask finance before shipping"},
+		{Path: "internal/billing/README.md"},
+		{Path: "deploy.sh", Comment: "uses the prod key"},
+	}
+
+	s := scaffold.NewScaffolder()
+	s.NoComments = true
+	root := t.TempDir()
+	if err := s.Apply(root, nodes, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	want := map[string]string{
+		"internal/billing/invoice.go": "package billing\n\n// TODO: implement invoice.go\n",
+		"internal/billing/README.md":  "",
+		"deploy.sh":                   "",
+	}
+	for path, content := range want {
+		data, err := os.ReadFile(filepath.Join(root, path))
+		if err != nil {
+			t.Fatalf("ReadFile(%s) error = %v", path, err)
+		}
+		if string(data) != content {
+			t.Errorf("%s = %q, want %q", path, data, content)
+		}
+		for _, n := range nodes {
+			if n.Comment != "" && strings.Contains(string(data), n.Comment) {
+				t.Errorf("%s leaks comment %q", path, n.Comment)
+			}
+		}
+	}
+}
+
+func TestApplyDedupComments(t *testing.T) {
+	nodes := []parser.Node{
+		{Path: "api/", IsDir: true, Comment: "public HTTP API"},
+		{Path: "api/routes.go", Comment: "public HTTP API"},
+		{Path: "api/auth.go", Comment: "token checks"},
+		{Path: "api/doc.md"},
+	}
+
+	s := scaffold.NewScaffolder()
+	s.DedupComments = true
+	root := t.TempDir()
+	if err := s.Apply(root, nodes, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	// The repeated comment is dropped, not inherited back, while doc.md,
+	// which has no comment of its own, still inherits the directory's
+	want := map[string]string{
+		"api/routes.go": "package api\n\n// TODO: implement routes.go\n",
+		"api/auth.go":   "// token checks\n\npackage api\n\n// TODO: implement auth.go\n",
+		"api/doc.md":    "<!-- public HTTP API -->\n",
+	}
+	for path, content := range want {
+		data, err := os.ReadFile(filepath.Join(root, path))
+		if err != nil {
+			t.Fatalf("ReadFile(%s) error = %v", path, err)
+		}
+		if string(data) != content {
+			t.Errorf("%s = %q, want %q", path, data, content)
+		}
+	}
+
+	// Without the flag the repeat is written like the inherited comment
+	root = t.TempDir()
+	if err := scaffold.NewScaffolder().Apply(root, nodes, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "api", "routes.go")); !strings.HasPrefix(string(data), "// public HTTP API\n") {
+		t.Errorf("api/routes.go without -dedup-comments = %q, want the comment", data)
+	}
+}
+
+func TestContentGeneratorNilGuard(t *testing.T) {
+	s := scaffold.NewScaffolder()
+	gen := s.GetContentGenerator()
+	if gen == nil {
+		t.Fatal("NewScaffolder() has no content generator")
+	}
+
+	if err := s.SetContentGenerator(nil); err == nil {
+		t.Error("SetContentGenerator(nil) succeeded")
+	}
+	if s.GetContentGenerator() != gen {
+		t.Error("SetContentGenerator(nil) replaced the generator")
+	}
+	var typedNil *scaffold.TemplateGenerator
+	if err := s.SetContentGenerator(typedNil); err == nil {
+		t.Error("SetContentGenerator() of a nil *TemplateGenerator succeeded")
+	}
+	if s.GetContentGenerator() != gen {
+		t.Error("SetContentGenerator() of a nil pointer replaced the generator")
+	}
+
+	custom := scaffold.NewTemplateGenerator(gen)
+	if err := s.SetContentGenerator(custom); err != nil {
+		t.Fatalf("SetContentGenerator() error = %v", err)
+	}
+	if s.GetContentGenerator() != scaffold.ContentGenerator(custom) {
+		t.Error("GetContentGenerator() did not return the new generator")
+	}
+
+	// A provider cleared through the field is an error, not a panic
+	s.ContentProvider = nil
+	err := s.Apply(t.TempDir(), []parser.Node{{Path: "main.go"}}, nil)
+	if err == nil || !strings.Contains(err.Error(), "no content generator") {
+		t.Errorf("Apply() with a nil generator error = %v", err)
+	}
+	s.ContentProvider = (*scaffold.DefaultContentGenerator)(nil)
+	err = s.Apply(t.TempDir(), []parser.Node{{Path: "main.go"}}, nil)
+	if err == nil || !strings.Contains(err.Error(), "no content generator") {
+		t.Errorf("Apply() with a nil pointer generator error = %v", err)
+	}
+}
+
+func TestApplyRefusesProtectedPaths(t *testing.T) {
+	root := t.TempDir()
+	head := filepath.Join(root, ".git", "HEAD")
+	if err := os.MkdirAll(filepath.Dir(head), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(head, []byte("ref: refs/heads/main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	specs := [][]parser.Node{
+		{{Path: ".git", Line: 1}, {Path: "main.go", Line: 2}},
+		{{Path: ".git/hooks/", IsDir: true}, {Path: ".git/hooks/pre-commit", Line: 3}},
+		{{Path: "vendor/lib/.hg/", IsDir: true, Line: 4}},
+	}
+	for _, nodes := range specs {
+		s := scaffold.NewScaffolderWithForce()
+		s.OnConflict = scaffold.ConflictOverwrite
+		if err := s.Validate(root, nodes); err == nil || !strings.Contains(err.Error(), "is protected") {
+			t.Errorf("Validate(%v) error = %v, want a protected path error", nodes, err)
+		}
+		if err := s.Apply(root, nodes, nil); err == nil || !strings.Contains(err.Error(), "is protected") {
+			t.Errorf("Apply(%v) error = %v, want a protected path error", nodes, err)
+		}
+	}
+
+	if data, err := os.ReadFile(head); err != nil || string(data) != "ref: refs/heads/main\n" {
+		t.Errorf(".git/HEAD = %q, %v; want it untouched", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "main.go")); !os.IsNotExist(err) {
+		t.Errorf("Apply() created main.go from a refused spec: %v", err)
+	}
+
+	// The protected set is configurable
+	s := scaffold.NewScaffolder()
+	s.Protected = []string{".jj"}
+	if err := s.Apply(root, []parser.Node{{Path: ".hg/", IsDir: true}}, nil); err != nil {
+		t.Errorf("Apply() with .hg unprotected error = %v", err)
+	}
+	if err := s.Apply(root, []parser.Node{{Path: ".jj/", IsDir: true}}, nil); err == nil {
+		t.Error("Apply() created a custom protected path")
+	}
+}
+
+func TestApplyRequireParents(t *testing.T) {
+	nodes := []parser.Node{
+		{Path: "cmd/", IsDir: true, Line: 1},
+		{Path: "cmd/main.go", Line: 2},
+		{Path: "internal/db/store.go", Line: 3},
+	}
+
+	root := t.TempDir()
+	s := scaffold.NewScaffolder()
+	s.RequireParents = true
+	want := `"internal/db/store.go" (line 3) is inside internal/db/, which the spec does not declare`
+	if err := s.Validate(root, nodes); err == nil || err.Error() != want {
+		t.Errorf("Validate() error = %v, want %q", err, want)
+	}
+	if err := s.Apply(root, nodes, nil); err == nil || err.Error() != want {
+		t.Errorf("Apply() error = %v, want %q", err, want)
+	}
+	if _, err := os.Stat(filepath.Join(root, "cmd")); !os.IsNotExist(err) {
+		t.Errorf("Apply() created cmd/ from a refused spec: %v", err)
+	}
+
+	// Declaring every directory satisfies it
+	nodes = append(nodes, parser.Node{Path: "internal/", IsDir: true}, parser.Node{Path: "internal/db/", IsDir: true})
+	if err := s.Apply(root, nodes, nil); err != nil {
+		t.Errorf("Apply() with all directories declared error = %v", err)
+	}
+
+	// By default missing parents are created
+	root = t.TempDir()
+	if err := scaffold.NewScaffolder().Apply(root, nodes[1:3], nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "internal", "db", "store.go")); err != nil {
+		t.Errorf("Apply() did not create the missing parents: %v", err)
+	}
+}
+
+func TestCheckNames(t *testing.T) {
+	tests := []struct {
+		path string
+		want string // substring of the error; "" means valid
+	}{
+		{"cmd/app/main.go", ""},
+		{"console.go", ""},
+		{".github/", ""},
+		{"CON", `"CON" is a device name Windows reserves, even with an extension; rename it, e.g. to "CON_"`},
+		{"docs/nul.txt", `"nul.txt" is a device name Windows reserves, even with an extension; rename it, e.g. to "nul_.txt"`},
+		{"lpt1.tar.gz", `e.g. to "lpt1_.tar.gz"`},
+		{"com9/", "device name"},
+		{"notes:draft.md", `"notes:draft.md" contains ':', which Windows does not allow in file names`},
+		{"glob*.go", `contains '*'`},
+		{"what?/readme.md", `contains '?'`},
+		{`a\b.txt`, `contains '\\'`},
+		{"tab\there.go", "control character U+0009"},
+		{"final.", "ends in a dot or space"},
+		{"dir /x.go", "ends in a dot or space"},
+		{strings.Repeat("a", 256) + ".go", "259 bytes long"},
+	}
+	for _, tt := range tests {
+		err := scaffold.CheckNames([]parser.Node{{Path: tt.path, IsDir: strings.HasSuffix(tt.path, "/"), Line: 2}})
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("CheckNames(%q) error = %v", tt.path, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("CheckNames(%q) error = %v, want it to contain %s", tt.path, err, tt.want)
+		}
+	}
+
+	nodes := []parser.Node{{Path: "src/", IsDir: true, Line: 1}, {Path: "src/aux.go", Line: 2}}
+	root := t.TempDir()
+	s := scaffold.NewScaffolder()
+	s.ValidateNames = true
+	want := `"src/aux.go" (line 2): "aux.go" is a device name Windows reserves`
+	if err := s.Validate(root, nodes); err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("Validate() error = %v, want %q...", err, want)
+	}
+	if err := s.Apply(root, nodes, nil); err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("Apply() error = %v, want %q...", err, want)
+	}
+	if _, err := os.Stat(filepath.Join(root, "src")); !os.IsNotExist(err) {
+		t.Errorf("Apply() created src/ from a refused spec: %v", err)
+	}
+
+	// Names are only checked when asked
+	if err := scaffold.NewScaffolder().Validate(root, nodes); err != nil {
+		t.Errorf("Validate() without ValidateNames error = %v", err)
+	}
+}
+
+func TestApplyReplacements(t *testing.T) {
+	nodes := []parser.Node{
+		{Path: "go.mod"},
+		{Path: "README.md", Comment: "__NAME__ by __AUTHOR__"},
+		{Path: "cmd/__NAME__/main.go", Comment: "entry point for __NAME__"},
+		{Path: "notes.txt", Content: []byte("__NAME__ stays literal\n")},
+	}
+
+	tg := scaffold.NewTemplateGenerator(scaffold.NewDefaultContentGenerator())
+	if err := tg.AddTemplate("go.mod", "module __MODULE__\n"); err != nil {
+		t.Fatal(err)
+	}
+	s := scaffold.NewScaffolder()
+	s.ContentProvider = tg
+	s.Replacements = []string{"__MODULE__", "github.com/me/app", "__NAME__", "app", "__AUTHOR__", "Jane"}
+
+	root := t.TempDir()
+	if err := s.Apply(root, nodes, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	want := map[string]string{
+		"go.mod":               "module github.com/me/app\n",
+		"README.md":            "<!-- app by Jane -->\n",
+		"cmd/__NAME__/main.go": "// entry point for app\n",
+		"notes.txt":            "__NAME__ stays literal\n",
+	}
+	for path, prefix := range want {
+		data, err := os.ReadFile(filepath.Join(root, path))
+		if err != nil {
+			t.Fatalf("ReadFile(%s) error = %v", path, err)
+		}
+		if !strings.HasPrefix(string(data), prefix) {
+			t.Errorf("%s = %q, want it to start with %q", path, data, prefix)
+		}
+	}
+
+	s.Replacements = []string{"__NAME__"}
+	if err := s.Apply(t.TempDir(), nodes, nil); err == nil {
+		t.Error("Apply() accepted an odd number of replacement strings")
+	}
+}
+
+func TestApplyStrictGenerate(t *testing.T) {
+	gen := scaffold.NewExternalGenerator(scaffold.NewDefaultContentGenerator())
+	if err := gen.RegisterCommand(".rb", "ruby-scaffold-gen"); err != nil {
+		t.Fatal(err)
+	}
+	gen.Run = func(string, []string, []byte) ([]byte, error) {
+		return nil, errors.New("boom")
+	}
+	nodes := []parser.Node{{Path: "lib/", IsDir: true}, {Path: "lib/app.rb", Comment: "entry"}}
+
+	// By default the failure is noted and the fallback content written
+	root := t.TempDir()
+	var log bytes.Buffer
+	s := scaffold.NewScaffolder()
+	s.ContentProvider = gen
+	s.Log = &log
+	if err := s.Apply(root, nodes, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "lib", "app.rb")); string(data) != "# entry\n" {
+		t.Errorf("lib/app.rb = %q, want the fallback content", data)
+	}
+	if !strings.Contains(log.String(), "ruby-scaffold-gen failed for lib/app.rb: boom") {
+		t.Errorf("log = %q, want a note about the failed generator", log.String())
+	}
+
+	// Strict mode aborts before writing the file
+	root = t.TempDir()
+	s.StrictGenerate = true
+	err := s.Apply(root, nodes, nil)
+	if err == nil || !strings.Contains(err.Error(), "cannot generate lib/app.rb") {
+		t.Fatalf("Apply() error = %v, want a generation error", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "lib", "app.rb")); !os.IsNotExist(err) {
+		t.Errorf("lib/app.rb was written despite the failure: %v", err)
+	}
+}
+
+func TestApplyMaxCommentLength(t *testing.T) {
+	root := t.TempDir()
+	s := scaffold.NewScaffolder()
+	s.MaxCommentLength = 10
+	nodes := []parser.Node{
+		{Path: "fits.py", Comment: "ten chars!"},
+		{Path: "over.py", Comment: "eleven char"},
+		{Path: "spaced.py", Comment: "a b c d e f g h"},
+		{Path: "wide.py", Comment: "ünïcödé ünïcödé"},
+	}
+	if err := s.Apply(root, nodes, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	want := map[string]string{
+		"fits.py":   "# ten chars!\n",
+		"over.py":   "# eleven ch…\n",
+		"spaced.py": "# a b c d e…\n",
+		"wide.py":   "# ünïcödé ü…\n",
+	}
+	for name, content := range want {
+		data, err := os.ReadFile(filepath.Join(root, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Errorf("%s = %q, want %q", name, data, content)
+		}
+	}
+}
+
+func TestApplyLineEndings(t *testing.T) {
+	nodes := []parser.Node{
+		{Path: "app.py", Comment: "entry"},
+		{Path: "util.go", Comment: "helpers"},
+		{Path: "notes.txt", Content: []byte("kept\nas is\r\n")},
+	}
+	tests := []struct {
+		eol  string
+		want map[string]string
+	}{
+		{"lf", map[string]string{
+			"app.py":    "# entry\n",
+			"util.go":   "// helpers\n\npackage main\n\n// TODO: implement util.go\n",
+			"notes.txt": "kept\nas is\r\n",
+		}},
+		{"crlf", map[string]string{
+			"app.py":    "# entry\r\n",
+			"util.go":   "// helpers\r\n\r\npackage main\r\n\r\n// TODO: implement util.go\r\n",
+			"notes.txt": "kept\nas is\r\n",
+		}},
+	}
+
+	for _, tt := range tests {
+		eol, err := scaffold.ParseLineEnding(tt.eol)
+		if err != nil {
+			t.Fatalf("ParseLineEnding(%q) error = %v", tt.eol, err)
+		}
+		root := t.TempDir()
+		s := scaffold.NewScaffolder()
+		s.EOL = eol
+		if err := s.Apply(root, nodes, nil); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+		for name, want := range tt.want {
+			data, err := os.ReadFile(filepath.Join(root, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != want {
+				t.Errorf("-eol %s: %s = %q, want %q", tt.eol, name, data, want)
+			}
+		}
+	}
+
+	if _, err := scaffold.ParseLineEnding("cr"); err == nil {
+		t.Error("ParseLineEnding accepted an unknown line ending")
+	}
+}
+
+func TestAddRootReadme(t *testing.T) {
+	nodes := []parser.Node{
+		{Path: "cmd/", IsDir: true},
+		{Path: "cmd/README.md"},
+		{Path: "main.go"},
+	}
+	got := scaffold.AddRootReadme(nodes, "myapp")
+	want := append(nodes, parser.Node{Path: "README.md", Comment: "myapp"})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AddRootReadme() = %+v, want %+v", got, want)
+	}
+
+	// A README already in the spec is not duplicated, whatever its spelling
+	for _, name := range []string{"README.md", "readme.rst", "Readme"} {
+		with := append([]parser.Node{{Path: name}}, nodes...)
+		if got := scaffold.AddRootReadme(with, "myapp"); len(got) != len(with) {
+			t.Errorf("AddRootReadme() with %s added a node: %+v", name, got)
+		}
+	}
+}
+
+func TestAddTestFiles(t *testing.T) {
+	nodes := []parser.Node{
+		{Path: "cmd/tool/", IsDir: true},
+		{Path: "cmd/tool/main.go"},
+		{Path: "cmd/tool/flags.go"},
+		{Path: "internal/store/", IsDir: true},
+		{Path: "internal/store/user_store.go", Comment: "user persistence"},
+		{Path: "internal/store/cache.go"},
+		{Path: "internal/store/cache_test.go", Comment: "existing test"},
+		{Path: "internal/store/schema.sql"},
+	}
+
+	got := scaffold.AddTestFiles(nodes)
+	var paths []string
+	for _, n := range got {
+		paths = append(paths, n.Path)
+	}
+	want := []string{
+		"cmd/tool/", "cmd/tool/main.go", "cmd/tool/flags.go", "cmd/tool/flags_test.go",
+		"internal/store/", "internal/store/user_store.go", "internal/store/user_store_test.go",
+		"internal/store/cache.go", "internal/store/cache_test.go", "internal/store/schema.sql",
+	}
+	if strings.Join(paths, " ") != strings.Join(want, " ") {
+		t.Fatalf("AddTestFiles() = %v, want %v", paths, want)
+	}
+
+	root := t.TempDir()
+	if err := scaffold.NewScaffolder().Apply(root, got, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	tests := map[string][]string{
+		"cmd/tool/flags_test.go":            {"package main\n", "func TestFlags(t *testing.T) {"},
+		"internal/store/user_store_test.go": {"package store\n", `import "testing"`, "func TestUserStore(t *testing.T) {"},
+	}
+	for path, wants := range tests {
+		data, err := os.ReadFile(filepath.Join(root, path))
+		if err != nil {
+			t.Fatalf("ReadFile(%s) error = %v", path, err)
+		}
+		for _, w := range wants {
+			if !strings.Contains(string(data), w) {
+				t.Errorf("%s = %q, want it to contain %q", path, data, w)
+			}
+		}
+	}
+}
+
+func TestNormalizeDirCase(t *testing.T) {
+	nodes := []parser.Node{
+		{Path: "MyService/", IsDir: true},
+		{Path: "MyService/HTTPHandlers/", IsDir: true},
+		{Path: "MyService/HTTPHandlers/UserAPI.go"},
+		{Path: ".GitHub/", IsDir: true},
+		{Path: "data_Sets/v2Files/README.md"},
+		{Path: "releases/V1.2.0/", IsDir: true},
+	}
+
+	tests := []struct {
+		style scaffold.DirCase
+		want  []string
+	}{
+		{scaffold.DirCaseKebab, []string{
+			"my-service/", "my-service/http-handlers/", "my-service/http-handlers/UserAPI.go",
+			".git-hub/", "data-sets/v2-files/README.md", "releases/v1.2.0/",
+		}},
+		{scaffold.DirCaseSnake, []string{
+			"my_service/", "my_service/http_handlers/", "my_service/http_handlers/UserAPI.go",
+			".git_hub/", "data_sets/v2_files/README.md", "releases/v1.2.0/",
+		}},
+		{scaffold.DirCaseLower, []string{
+			"myservice/", "myservice/httphandlers/", "myservice/httphandlers/UserAPI.go",
+			".github/", "datasets/v2files/README.md", "releases/v1.2.0/",
+		}},
+	}
+	for _, tt := range tests {
+		got, err := scaffold.NormalizeDirCase(nodes, tt.style)
+		if err != nil {
+			t.Fatalf("NormalizeDirCase(%d) error = %v", tt.style, err)
+		}
+		var paths []string
+		for _, n := range got {
+			paths = append(paths, n.Path)
+		}
+		if strings.Join(paths, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("NormalizeDirCase(%d) = %v, want %v", tt.style, paths, tt.want)
+		}
+	}
+
+	if nodes[0].Path != "MyService/" {
+		t.Errorf("NormalizeDirCase modified its input: %+v", nodes[0])
+	}
+
+	// Directories that normalize to the same name are not merged
+	clash := []parser.Node{{Path: "FooBar/a.go"}, {Path: "foo_bar/b.go"}}
+	if _, err := scaffold.NormalizeDirCase(clash, scaffold.DirCaseKebab); err == nil || !strings.Contains(err.Error(), "FooBar/ and foo_bar/ would both be renamed foo-bar/") {
+		t.Errorf("NormalizeDirCase(clash) error = %v, want a collision error", err)
+	}
+	if _, err := scaffold.ParseDirCase("camelot"); err == nil {
+		t.Error("ParseDirCase accepted an unknown style")
+	}
+}
+
+func TestLowercaseExtensions(t *testing.T) {
+	nodes := []parser.Node{
+		{Path: "Cmd.D/", IsDir: true},
+		{Path: "Cmd.D/Main.GO"},
+		{Path: "setup.PY"},
+		{Path: "Makefile"},
+		{Path: "Latest.LNK", LinkTarget: "Cmd.D/Main.GO"},
+	}
+	want := []string{"Cmd.D/", "Cmd.D/Main.go", "setup.py", "Makefile", "Latest.LNK"}
+
+	got := scaffold.LowercaseExtensions(nodes)
+	var paths []string
+	for _, n := range got {
+		paths = append(paths, n.Path)
+	}
+	if strings.Join(paths, " ") != strings.Join(want, " ") {
+		t.Errorf("LowercaseExtensions() = %v, want %v", paths, want)
+	}
+	if got[4].LinkTarget != "Cmd.D/Main.go" {
+		t.Errorf("link target = %q, want it to follow the renamed Cmd.D/Main.go", got[4].LinkTarget)
+	}
+	if nodes[1].Path != "Cmd.D/Main.GO" {
+		t.Errorf("LowercaseExtensions modified its input: %+v", nodes[1])
+	}
+}
+
+func TestHandleEmptyDirs(t *testing.T) {
+	nodes := []parser.Node{
+		{Path: "app/", IsDir: true},
+		{Path: "app/main.go"},
+		{Path: "assets/", IsDir: true},
+		{Path: "assets/images/", IsDir: true, Line: 5},
+		{Path: "logs/", IsDir: true, Line: 6},
+	}
+
+	t.Run("create", func(t *testing.T) {
+		got, err := scaffold.HandleEmptyDirs(nodes, scaffold.EmptyDirsCreate)
+		if err != nil || len(got) != len(nodes) {
+			t.Fatalf("HandleEmptyDirs(create) = %+v, %v", got, err)
+		}
+		root := t.TempDir()
+		if err := scaffold.NewScaffolder().Apply(root, got, nil); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+		if fi, err := os.Stat(filepath.Join(root, "logs")); err != nil || !fi.IsDir() {
+			t.Errorf("empty directory logs/ not created: %v", err)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := scaffold.HandleEmptyDirs(nodes, scaffold.EmptyDirsError)
+		if err == nil {
+			t.Fatal("HandleEmptyDirs(error) accepted empty directories")
+		}
+		want := `spec has 2 empty directories: "assets/images/" (line 5), "logs/" (line 6)`
+		if err.Error() != want {
+			t.Errorf("error = %q, want %q", err, want)
+		}
+	})
+
+	t.Run("keepfile", func(t *testing.T) {
+		got, err := scaffold.HandleEmptyDirs(nodes, scaffold.EmptyDirsKeepFile)
+		if err != nil {
+			t.Fatalf("HandleEmptyDirs(keepfile) error = %v", err)
+		}
+		root := t.TempDir()
+		if err := scaffold.NewScaffolder().Apply(root, got, nil); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+		for _, keep := range []string{"assets/images/.gitkeep", "logs/.gitkeep"} {
+			data, err := os.ReadFile(filepath.Join(root, keep))
+			if err != nil || len(data) != 0 {
+				t.Errorf("%s = %q, %v; want an empty file", keep, data, err)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(root, "app", ".gitkeep")); err == nil {
+			t.Error("non-empty app/ got a .gitkeep")
+		}
+	})
+}
+
+func TestApplyMergesExistingGoMod(t *testing.T) {
+	const existing = `module github.com/acme/app
+
+require (
+	github.com/google/uuid v1.6.0
+	golang.org/x/sync v0.7.0
+)
+`
+	root := t.TempDir()
+	goMod := filepath.Join(root, "go.mod")
+	if err := os.WriteFile(goMod, []byte(existing), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := scaffold.NewScaffolder()
+	s.MergeExisting = true
+	s.OnConflict = scaffold.ConflictOverwrite // merging wins over overwriting
+	res, err := s.ApplyWithResult(root, []parser.Node{{Path: "go.mod"}}, nil)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if res.Count(scaffold.ActionMerged) != 1 {
+		t.Errorf("expected one merged entry, got %+v", res.Entries)
+	}
+
+	data, err := os.ReadFile(goMod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged := string(data)
+	for _, keep := range []string{"module github.com/acme/app\n", "github.com/google/uuid v1.6.0", "golang.org/x/sync v0.7.0"} {
+		if !strings.Contains(merged, keep) {
+			t.Errorf("merged go.mod lost %q:\n%s", keep, merged)
+		}
+	}
+	if !strings.Contains(merged, "\ngo ") {
+		t.Errorf("merged go.mod has no go directive:\n%s", merged)
+	}
+
+	// A second run has nothing left to merge
+	if err := s.Apply(root, []parser.Node{{Path: "go.mod"}}, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if again, _ := os.ReadFile(goMod); string(again) != merged {
+		t.Errorf("second merge changed go.mod:\n%s", again)
+	}
+
+	// The merged file gets one line ending throughout, whatever it had
+	for _, eol := range []scaffold.LineEnding{scaffold.LineEndingLF, scaffold.LineEndingCRLF} {
+		if err := os.WriteFile(goMod, []byte(strings.ReplaceAll(existing, "\n", "\r\n")), 0644); err != nil {
+			t.Fatal(err)
+		}
+		s.EOL = eol
+		if err := s.Apply(root, []parser.Node{{Path: "go.mod"}}, nil); err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+		data, _ := os.ReadFile(goMod)
+		lf := strings.Count(string(data), "\n")
+		crlf := strings.Count(string(data), "\r\n")
+		if !strings.Contains(string(data), "go ") || (eol == scaffold.LineEndingLF && crlf != 0) || (eol == scaffold.LineEndingCRLF && crlf != lf) {
+			t.Errorf("EOL %v: merged go.mod = %q, want one line ending throughout", eol, data)
+		}
+	}
+}
+
+func TestDiffNodes(t *testing.T) {
+	old := []parser.Node{
+		{Path: "cmd/", IsDir: true},
+		{Path: "cmd/main.go", Comment: "entry point"},
+		{Path: "docs", Comment: "notes"},
+		{Path: "legacy.go"},
+		{Path: "latest", LinkTarget: "v1"},
+	}
+	new := []parser.Node{
+		{Path: "cmd/", IsDir: true},
+		{Path: "cmd/main.go", Comment: "CLI entry point"},
+		{Path: "docs/", IsDir: true, Comment: "notes"},
+		{Path: "latest", LinkTarget: "v2"},
+		{Path: "pkg/", IsDir: true},
+		{Path: "pkg/api.go"},
+	}
+
+	added, removed, changed := scaffold.DiffNodes(old, new)
+	paths := func(nodes []parser.Node) string {
+		var ps []string
+		for _, n := range nodes {
+			ps = append(ps, n.Path)
+		}
+		return strings.Join(ps, " ")
+	}
+	if got, want := paths(added), "pkg/ pkg/api.go"; got != want {
+		t.Errorf("added = %q, want %q", got, want)
+	}
+	if got, want := paths(removed), "legacy.go"; got != want {
+		t.Errorf("removed = %q, want %q", got, want)
+	}
+	if got, want := paths(changed), "cmd/main.go docs/ latest"; got != want {
+		t.Errorf("changed = %q, want %q", got, want)
+	}
+	if changed[0].Comment != "CLI entry point" {
+		t.Errorf("changed holds %+v, want the new node", changed[0])
+	}
+
+	if a, r, c := scaffold.DiffNodes(new, new); a != nil || r != nil || c != nil {
+		t.Errorf("DiffNodes(new, new) = %v, %v, %v, want no differences", a, r, c)
+	}
+}
+
+func TestGitignore(t *testing.T) {
+	g, err := scaffold.ParseGitignore(strings.NewReader(`# deps
+node_modules/
+*.log
+!important.log
+/dist
+docs/**/*.pdf
+\#notes
+`))
+	if err != nil {
+		t.Fatalf("ParseGitignore() error = %v", err)
+	}
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"node_modules", true, true},
+		{"web/node_modules/react/index.js", false, true},
+		{"node_modules", false, false}, // a file of that name
+		{"debug.log", false, true},
+		{"logs/server.log", false, true},
+		{"important.log", false, false},
+		{"dist/app.js", false, true},
+		{"web/dist/app.js", false, false}, // "/dist" is anchored to the root
+		{"docs/manual.pdf", false, true},
+		{"docs/api/v1/ref.pdf", false, true},
+		{"docs/manual.md", false, false},
+		{"#notes", false, true},
+		{"main.go", false, false},
+	}
+	for _, tt := range tests {
+		if got := g.Ignored(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("Ignored(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestPackageDocFromDirs(t *testing.T) {
+	nodes := []parser.Node{
+		{Path: "internal/", IsDir: true},
+		{Path: "internal/api/", IsDir: true, Comment: "HTTP handlers"},
+		{Path: "internal/api/router.go"},
+		{Path: "internal/api/handler.go"},
+		{Path: "internal/api/handler_test.go"},
+		{Path: "internal/db/", IsDir: true, Comment: "storage"},
+		{Path: "internal/db/store.go", Comment: "SQL store"},
+	}
+	mode, err := scaffold.ParseDirCommentMode("first-file")
+	if err != nil || mode != scaffold.DirCommentFirstFile {
+		t.Fatalf("ParseDirCommentMode() = %v, %v", mode, err)
+	}
+	got := scaffold.PackageDocFromDirs(nodes)
+	if nodes[3].Attrs != nil {
+		t.Error("PackageDocFromDirs modified its input")
+	}
+
+	root := t.TempDir()
+	if err := scaffold.NewScaffolder().Apply(root, got, nil); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	want := map[string]string{
+		"internal/api/handler.go":      "// Package api: HTTP handlers\npackage api\n\n// TODO: implement handler.go\n",
+		"internal/api/router.go":       "// HTTP handlers\n\npackage api\n\n// TODO: implement router.go\n",
+		"internal/api/handler_test.go": "// HTTP handlers\n\npackage api\n\nimport \"testing\"\n\nfunc TestHandler(t *testing.T) {\n    // TODO: implement handler_test.go\n}\n",
+		"internal/db/store.go":         "// SQL store\n\npackage db\n\n// TODO: implement store.go\n",
+	}
+	for path, content := range want {
+		data, err := os.ReadFile(filepath.Join(root, path))
+		if err != nil {
+			t.Fatalf("ReadFile(%s) error = %v", path, err)
+		}
+		if string(data) != content {
+			t.Errorf("%s = %q, want %q", path, data, content)
+		}
+	}
+}
+
+func TestApplyOrder(t *testing.T) {
+	nodes := []parser.Node{
+		{Path: "web/", IsDir: true},
+		{Path: "web/index.html"},
+		{Path: "main.go"},
+		{Path: "api/", IsDir: true},
+		{Path: "api/server.go"},
+		{Path: "docs/guide.md"}, // docs/ is only implied
+	}
+
+	tests := []struct {
+		order string
+		want  []string
+	}{
+		{"type", []string{"api/", "docs/", "web/", "api/server.go", "docs/guide.md", "main.go", "web/index.html"}},
+		{"name", []string{"api/", "api/server.go", "docs/", "docs/guide.md", "main.go", "web/", "web/index.html"}},
+		{"input", []string{"web/", "web/index.html", "main.go", "api/", "api/server.go", "docs/", "docs/guide.md"}},
+	}
+	for _, tt := range tests {
+		order, err := scaffold.ParseSortOrder(tt.order)
+		if err != nil {
+			t.Fatalf("ParseSortOrder(%q) error = %v", tt.order, err)
+		}
+		root := t.TempDir()
+		s := scaffold.NewScaffolder()
+		s.Order = order
+		var got []string
+		err = s.Apply(root, nodes, func(path string, isDir bool) {
+			rel, _ := filepath.Rel(root, path)
+			if isDir {
+				rel += "/"
+			}
+			got = append(got, filepath.ToSlash(rel))
+		})
+		if err != nil {
+			t.Fatalf("Apply() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("-sort %s: callbacks = %v, want %v", tt.order, got, tt.want)
+		}
+	}
+
+	if _, err := scaffold.ParseSortOrder("size"); err == nil {
+		t.Error("ParseSortOrder accepted an unknown order")
+	}
+}
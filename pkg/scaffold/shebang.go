@@ -0,0 +1,36 @@
+package scaffold
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/lancekrogers/tree2scaffold/pkg/parser"
+)
+
+// shebangs maps script extensions to the interpreter line written at the top
+// of files marked @executable
+var shebangs = map[string]string{
+	".sh":   "#!/usr/bin/env bash",
+	".bash": "#!/usr/bin/env bash",
+	".zsh":  "#!/usr/bin/env zsh",
+	".py":   "#!/usr/bin/env python3",
+	".rb":   "#!/usr/bin/env ruby",
+	".pl":   "#!/usr/bin/env perl",
+	".js":   "#!/usr/bin/env node",
+}
+
+// isExecutable reports whether n carries the @executable directive
+func isExecutable(n parser.Node) bool {
+	_, ok := n.Attrs["executable"]
+	return ok
+}
+
+// withShebang puts the interpreter line for relPath's extension in front of
+// content, unless content already starts with one or the extension is unknown
+func withShebang(relPath, content string) string {
+	line, ok := shebangs[strings.ToLower(filepath.Ext(relPath))]
+	if !ok || strings.HasPrefix(content, "#!") {
+		return content
+	}
+	return line + "\n" + content
+}
@@ -0,0 +1,227 @@
+package scaffold
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/lancekrogers/tree2scaffold/pkg/parser"
+)
+
+// TemplateExt is the extension of template files loaded by LoadTemplates
+const TemplateExt = ".tmpl"
+
+// builtinTemplates are the defaults LoadBuiltinTemplates adds: doc.go,
+// Python, Dockerfile and Makefile skeletons that beat a bare comment line
+//
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+// TemplateData is the value templates are executed with
+type TemplateData struct {
+	Path    string            // relative path of the file, e.g. "internal/api/server.go"
+	Name    string            // base name, e.g. "server.go"
+	Base    string            // base name without extension, e.g. "server"
+	Dir     string            // directory, e.g. "internal/api"; "." at the root
+	Ext     string            // extension, e.g. ".go"
+	Package string            // Go package the file belongs to, e.g. "api"
+	Comment string            // the file's comment
+	Vars    map[string]string // values passed with -var
+	Data    map[string]any    // the -template-data file, with Vars laid over its top level
+}
+
+// TemplateGenerator renders file content from text/template templates. A
+// template named after a file ("Dockerfile", "go.mod") or its extension
+// without the dot ("go", "py") is used for matching files; an @template=name
+// directive selects one explicitly. Files without a template go to Fallback.
+type TemplateGenerator struct {
+	Fallback ContentGenerator
+
+	// Vars are exposed to every template as .Vars
+	Vars map[string]string
+
+	// Data is exposed to every template as .Data, e.g. from LoadTemplateData.
+	// A Vars entry replaces the top-level Data value of the same name.
+	Data map[string]any
+
+	// Log receives a warning when a template is missing or fails; nil means os.Stderr
+	Log io.Writer
+
+	templates map[string]*template.Template
+}
+
+// NewTemplateGenerator creates a template generator wrapping fallback
+func NewTemplateGenerator(fallback ContentGenerator) *TemplateGenerator {
+	return &TemplateGenerator{
+		Fallback:  fallback,
+		Vars:      make(map[string]string),
+		templates: make(map[string]*template.Template),
+	}
+}
+
+// AddTemplate parses text as the template called name
+func (g *TemplateGenerator) AddTemplate(name, text string) error {
+	t, err := template.New(name).Parse(text)
+	if err != nil {
+		return fmt.Errorf("cannot parse template %s: %w", name, err)
+	}
+	g.templates[name] = t
+	return nil
+}
+
+// LoadTemplates adds every *.tmpl file in dir, named after the file without
+// the .tmpl suffix, e.g. "go.tmpl" becomes "go". They replace templates of
+// the same name already added, including the built-in ones.
+func (g *TemplateGenerator) LoadTemplates(dir string) error {
+	return g.loadFS(os.DirFS(dir))
+}
+
+// LoadBuiltinTemplates adds the templates embedded in the binary. Load them
+// before any user templates so those take precedence.
+func (g *TemplateGenerator) LoadBuiltinTemplates() error {
+	sub, err := fs.Sub(builtinTemplates, "templates")
+	if err != nil {
+		return err
+	}
+	return g.loadFS(sub)
+}
+
+// loadFS adds every *.tmpl file at the top of fsys
+func (g *TemplateGenerator) loadFS(fsys fs.FS) error {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return fmt.Errorf("cannot read templates: %w", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), TemplateExt) {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, e.Name())
+		if err != nil {
+			return fmt.Errorf("cannot read template: %w", err)
+		}
+		if err := g.AddTemplate(strings.TrimSuffix(e.Name(), TemplateExt), string(data)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterGenerator registers an in-process generator on the fallback
+func (g *TemplateGenerator) RegisterGenerator(extOrName string, generator FileGenerator) {
+	g.Fallback.RegisterGenerator(extOrName, generator)
+}
+
+// GenerateContent renders the template for relPath's file name or extension,
+// or defers to the fallback when there is none
+func (g *TemplateGenerator) GenerateContent(relPath, comment string) string {
+	t, ok := g.lookup(relPath)
+	if !ok {
+		return g.Fallback.GenerateContent(relPath, comment)
+	}
+	content, err := g.execute(t, relPath, comment)
+	if err != nil {
+		notef(g.Log, "%v; using default content", err)
+	}
+	return content
+}
+
+// GenerateNodeContent honors an @template=name directive on n and otherwise
+// behaves like GenerateContent. A missing named template is reported and
+// the file falls back to extension-based selection.
+func (g *TemplateGenerator) GenerateNodeContent(n parser.Node, comment string) string {
+	content, err := g.TryGenerateContent(n, comment)
+	if err != nil {
+		notef(g.Log, "%v; using default content", err)
+	}
+	return content
+}
+
+// TryGenerateContent is GenerateNodeContent returning a failed template as an
+// error, along with the fallback's content for the file
+func (g *TemplateGenerator) TryGenerateContent(n parser.Node, comment string) (string, error) {
+	if name, ok := n.Attrs["template"]; ok && name != "" {
+		if t, ok := g.templates[name]; ok {
+			return g.execute(t, n.Path, comment)
+		}
+		notef(g.Log, "Template %q not found for %s, using the default for its type", name, n.Path)
+	}
+	if t, ok := g.lookup(n.Path); ok {
+		return g.execute(t, n.Path, comment)
+	}
+	return tryGenerate(g.Fallback, n, comment)
+}
+
+// lookup returns the template for relPath's file name or extension
+func (g *TemplateGenerator) lookup(relPath string) (*template.Template, bool) {
+	t, ok := g.templates[filepath.Base(relPath)]
+	if !ok {
+		t, ok = g.templates[strings.ToLower(strings.TrimPrefix(filepath.Ext(relPath), "."))]
+	}
+	return t, ok
+}
+
+// execute renders t for the file at relPath, with a comment derived from the
+// file name when the fallback's CommentFromFilename asks for one. On failure
+// it returns the fallback's content with the error.
+func (g *TemplateGenerator) execute(t *template.Template, relPath, comment string) (string, error) {
+	name := filepath.Base(relPath)
+	ext := filepath.Ext(name)
+	if d, ok := g.Fallback.(*DefaultContentGenerator); ok && comment == "" && d.CommentFromFilename {
+		comment = commentFromFilename(name)
+	}
+	data := TemplateData{
+		Path:    relPath,
+		Name:    name,
+		Base:    strings.TrimSuffix(name, ext),
+		Dir:     filepath.Dir(relPath),
+		Ext:     ext,
+		Package: g.goPackage(relPath),
+		Comment: comment,
+		Vars:    g.Vars,
+		Data:    g.data(),
+	}
+
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return g.Fallback.GenerateContent(relPath, comment), fmt.Errorf("template %s failed for %s: %w", t.Name(), relPath, err)
+	}
+	return b.String(), nil
+}
+
+// PlanSpec hands the spec to the fallback when it plans
+func (g *TemplateGenerator) PlanSpec(nodes []parser.Node) {
+	if p, ok := g.Fallback.(SpecPlanner); ok {
+		p.PlanSpec(nodes)
+	}
+}
+
+// goPackage names the Go package of relPath the way the fallback does when it
+// is a DefaultContentGenerator, and after the file's directory otherwise
+func (g *TemplateGenerator) goPackage(relPath string) string {
+	if d, ok := g.Fallback.(*DefaultContentGenerator); ok {
+		return d.inferPkg(relPath)
+	}
+	if dir := filepath.Dir(relPath); dir != "." {
+		return filepath.Base(dir)
+	}
+	return "main"
+}
+
+// data is Data with Vars laid over it
+func (g *TemplateGenerator) data() map[string]any {
+	data := make(map[string]any, len(g.Data)+len(g.Vars))
+	for k, v := range g.Data {
+		data[k] = v
+	}
+	for k, v := range g.Vars {
+		data[k] = v
+	}
+	return data
+}
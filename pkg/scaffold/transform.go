@@ -0,0 +1,274 @@
+package scaffold
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/lancekrogers/tree2scaffold/pkg/parser"
+)
+
+// Flatten drops every directory node and strips the directory components from
+// file nodes, so all files land directly in the scaffold root. Two files that
+// would share a base name are reported as an error instead of silently
+// colliding.
+func Flatten(nodes []parser.Node) ([]parser.Node, error) {
+	var flat []parser.Node
+	seen := make(map[string]parser.Node)
+
+	for _, n := range nodes {
+		if n.IsDir {
+			continue
+		}
+
+		name := filepath.Base(n.Path)
+		if prev, ok := seen[name]; ok {
+			return nil, fmt.Errorf("cannot flatten: %s and %s both become %q", describeNode(prev), describeNode(n), name)
+		}
+		seen[name] = n
+
+		n.Path, n.Depth = name, 0
+		flat = append(flat, n)
+	}
+
+	return flat, nil
+}
+
+// entryFiles maps a language name to the conventional entry file of one of
+// its package directories
+var entryFiles = map[string]string{
+	"python":     "__init__.py",
+	"py":         "__init__.py",
+	"rust":       "mod.rs",
+	"rs":         "mod.rs",
+	"typescript": "index.ts",
+	"ts":         "index.ts",
+	"javascript": "index.js",
+	"js":         "index.js",
+}
+
+// EntryLanguages lists the language names SeedEntries accepts
+func EntryLanguages() []string {
+	langs := make([]string, 0, len(entryFiles))
+	for lang := range entryFiles {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// SeedEntries adds the conventional entry file for lang ("__init__.py" for
+// python, "mod.rs" for rust, ...) to every directory that has no file
+// children in the spec. The new node follows its directory.
+func SeedEntries(nodes []parser.Node, lang string) ([]parser.Node, error) {
+	entry, ok := entryFiles[strings.ToLower(lang)]
+	if !ok {
+		return nil, fmt.Errorf("unknown entry language %q (want one of %s)", lang, strings.Join(EntryLanguages(), ", "))
+	}
+
+	hasFiles := make(map[string]bool)
+	for _, n := range nodes {
+		if !n.IsDir {
+			hasFiles[filepath.Dir(n.Path)] = true
+		}
+	}
+
+	var seeded []parser.Node
+	for _, n := range nodes {
+		seeded = append(seeded, n)
+		if dir := cleanNodePath(n.Path); n.IsDir && !hasFiles[dir] {
+			seeded = append(seeded, parser.Node{Path: filepath.ToSlash(filepath.Join(dir, entry)), Depth: n.Depth + 1})
+			hasFiles[dir] = true
+		}
+	}
+
+	return seeded, nil
+}
+
+// AddRootReadme adds a README.md at the root with the project name as its
+// comment, for the content generator to write, unless the spec already has a
+// root README of any extension or letter case (readme.rst, README).
+func AddRootReadme(nodes []parser.Node, name string) []parser.Node {
+	for _, n := range nodes {
+		base := strings.ToLower(strings.TrimSuffix(n.Path, filepath.Ext(n.Path)))
+		if !n.IsDir && base == "readme" {
+			return nodes
+		}
+	}
+	return append(nodes, parser.Node{Path: "README.md", Comment: name})
+}
+
+// AddTestFiles adds a "<name>_test.go" sibling after every .go file that is
+// not main.go, not already a test and not paired with a test in the spec.
+func AddTestFiles(nodes []parser.Node) []parser.Node {
+	declared := make(map[string]bool)
+	for _, n := range nodes {
+		declared[n.Path] = true
+	}
+
+	var out []parser.Node
+	for _, n := range nodes {
+		out = append(out, n)
+		name := filepath.Base(n.Path)
+		if n.IsDir || n.LinkTarget != "" || filepath.Ext(name) != ".go" ||
+			name == "main.go" || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		test := strings.TrimSuffix(n.Path, ".go") + "_test.go"
+		if declared[test] {
+			continue
+		}
+		declared[test] = true
+		out = append(out, parser.Node{Path: test, Depth: n.Depth})
+	}
+	return out
+}
+
+// EmptyDirPolicy decides what happens to directories that have nothing in them
+type EmptyDirPolicy int
+
+const (
+	// EmptyDirsCreate creates empty directories as they are (the default)
+	EmptyDirsCreate EmptyDirPolicy = iota
+	// EmptyDirsError rejects a spec with empty directories
+	EmptyDirsError
+	// EmptyDirsKeepFile puts an empty .gitkeep in each empty directory
+	EmptyDirsKeepFile
+)
+
+// ParseEmptyDirPolicy maps a command-line value ("create", "error", "keepfile") to an EmptyDirPolicy
+func ParseEmptyDirPolicy(s string) (EmptyDirPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "create":
+		return EmptyDirsCreate, nil
+	case "error":
+		return EmptyDirsError, nil
+	case "keepfile":
+		return EmptyDirsKeepFile, nil
+	default:
+		return EmptyDirsCreate, fmt.Errorf("unknown empty directory policy %q (want create, error or keepfile)", s)
+	}
+}
+
+// EmptyDirs returns the directory nodes that no other node lives under
+func EmptyDirs(nodes []parser.Node) []parser.Node {
+	parents := make(map[string]bool)
+	for _, n := range nodes {
+		for dir := filepath.Dir(cleanNodePath(n.Path)); dir != "." && dir != "/"; dir = filepath.Dir(dir) {
+			parents[filepath.ToSlash(dir)] = true
+		}
+	}
+
+	var empty []parser.Node
+	for _, n := range nodes {
+		if n.IsDir && !parents[cleanNodePath(n.Path)] {
+			empty = append(empty, n)
+		}
+	}
+	return empty
+}
+
+// HandleEmptyDirs applies policy to the empty directories in nodes
+func HandleEmptyDirs(nodes []parser.Node, policy EmptyDirPolicy) ([]parser.Node, error) {
+	empty := EmptyDirs(nodes)
+	if len(empty) == 0 || policy == EmptyDirsCreate {
+		return nodes, nil
+	}
+
+	if policy == EmptyDirsError {
+		names := make([]string, len(empty))
+		for i, n := range empty {
+			names[i] = describeNode(n)
+		}
+		return nil, fmt.Errorf("spec has %d empty directories: %s", len(empty), strings.Join(names, ", "))
+	}
+
+	isEmpty := make(map[string]bool)
+	for _, n := range empty {
+		isEmpty[n.Path] = true
+	}
+	var out []parser.Node
+	for _, n := range nodes {
+		out = append(out, n)
+		if n.IsDir && isEmpty[n.Path] {
+			out = append(out, parser.Node{Path: cleanNodePath(n.Path) + "/.gitkeep", Depth: n.Depth + 1, Content: []byte{}})
+		}
+	}
+	return out, nil
+}
+
+// SkipGoSum drops the go.sum files the spec leaves to the generator, for
+// GoSumSkip: `go mod tidy` writes them. A go.sum with literal content is kept.
+func SkipGoSum(nodes []parser.Node) []parser.Node {
+	var out []parser.Node
+	for _, n := range nodes {
+		if !n.IsDir && n.Content == nil && filepath.Base(n.Path) == "go.sum" {
+			continue
+		}
+		out = append(out, n)
+	}
+	return out
+}
+
+// DirCommentMode selects what becomes of a directory's comment
+type DirCommentMode int
+
+const (
+	// DirCommentInherit heads every file without a comment of its own with
+	// the directory's comment (the default)
+	DirCommentInherit DirCommentMode = iota
+	// DirCommentFirstFile also makes it the package doc of the directory's
+	// first Go file; see PackageDocFromDirs
+	DirCommentFirstFile
+)
+
+// ParseDirCommentMode maps a command-line value ("inherit", "first-file") to
+// a DirCommentMode
+func ParseDirCommentMode(s string) (DirCommentMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "inherit":
+		return DirCommentInherit, nil
+	case "first-file":
+		return DirCommentFirstFile, nil
+	default:
+		return DirCommentInherit, fmt.Errorf("unknown directory comment mode %q (want inherit or first-file)", s)
+	}
+}
+
+// PackageDocFromDirs turns the comment of every directory holding Go files
+// into the package doc of its alphabetically first non-test Go file, by giving
+// that file the @package-doc directive, so the description survives without
+// a doc.go. A first file with a comment of its own is left alone.
+func PackageDocFromDirs(nodes []parser.Node) []parser.Node {
+	out := slices.Clone(nodes)
+	first := make(map[string]int) // directory -> index of its first Go file
+	for i, n := range out {
+		name := filepath.Base(n.Path)
+		if n.IsDir || n.LinkTarget != "" || n.Content != nil ||
+			strings.ToLower(filepath.Ext(name)) != ".go" || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		dir := filepath.Dir(n.Path)
+		if j, ok := first[dir]; !ok || name < filepath.Base(out[j].Path) {
+			first[dir] = i
+		}
+	}
+
+	for _, d := range out {
+		i, ok := first[cleanNodePath(d.Path)]
+		if !d.IsDir || d.Comment == "" || !ok || out[i].Comment != "" {
+			continue
+		}
+		// The file inherits the comment; the directive makes it a doc comment
+		attrs := maps.Clone(out[i].Attrs)
+		if attrs == nil {
+			attrs = make(map[string]string)
+		}
+		attrs["package-doc"] = ""
+		out[i].Attrs = attrs
+	}
+	return out
+}
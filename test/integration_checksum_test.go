@@ -41,8 +41,8 @@ func normalize(raw string) string {
 			continue
 		}
 
-		// Drop the temporary directory path prefix that might appear
-		if strings.Contains(line, "var/folders") || strings.Contains(line, "tmp/") {
+		// Drop the absolute root line `tree` prints for the temporary directory
+		if filepath.IsAbs(strings.TrimSpace(line)) {
 			continue
 		}
 
@@ -311,12 +311,11 @@ demo-app/
 	findOut, err := findCmd.CombinedOutput()
 	t.Logf("Created files: \n%s", findOut)
 
-	// Based on the actual behavior of tree2scaffold, files are not created in as deep a structure
-	// as the ASCII tree might suggest. Let's check the files we actually expect to be created.
+	// Files must be created at the full depth the ASCII tree describes
 	expectedPaths := []string{
-		"cmd/main.go", // NOT cmd/demo-app/main.go
-		"pkg/util.go", // NOT pkg/util/util.go
-		"README.md",   // This is at the root as expected
+		"cmd/demo-app/main.go",
+		"pkg/util/util.go",
+		"README.md",
 	}
 
 	for _, path := range expectedPaths {
@@ -327,10 +326,10 @@ demo-app/
 	}
 
 	// Check content for main.go
-	mainGoPath := filepath.Join(tmp, "cmd/main.go")
+	mainGoPath := filepath.Join(tmp, "cmd/demo-app/main.go")
 	content, err := os.ReadFile(mainGoPath)
 	if err != nil {
-		t.Errorf("Failed to read cmd/main.go: %v", err)
+		t.Errorf("Failed to read cmd/demo-app/main.go: %v", err)
 	} else {
 		mainGoContent := string(content)
 		// Just log the content - don't fail the test since package names vary
@@ -343,10 +342,10 @@ demo-app/
 	}
 
 	// Check util.go content
-	utilGoPath := filepath.Join(tmp, "pkg/util.go")
+	utilGoPath := filepath.Join(tmp, "pkg/util/util.go")
 	content, err = os.ReadFile(utilGoPath)
 	if err != nil {
-		t.Errorf("Failed to read pkg/util.go: %v", err)
+		t.Errorf("Failed to read pkg/util/util.go: %v", err)
 	} else {
 		utilGoContent := string(content)
 		// Just log the content - don't fail the test since package names vary
@@ -609,51 +608,35 @@ project/
 		t.Fatalf("scaffold failed: %v\n%s", err, out)
 	}
 
-	// List all files to see what was created
-	findCmd := exec.Command("find", tmp, "-type", "f")
-	findOut, err := findCmd.CombinedOutput()
-	t.Logf("Created files: \n%s", findOut)
-
-	// 2) Directly check a few key files that should exist
-	// Based on the flattened structure we've observed
+	// 2) Every file lands at its full depth, hidden directories included
 	expectedFiles := []string{
 		".env",
-		"src/main.go",
-		".github/build.yml", // Note: Flattened from workflows/
 		".vscode/settings.json",
+		".vscode/extensions.json",
+		".github/ISSUE_TEMPLATE/bug_report.md",
+		".github/ISSUE_TEMPLATE/feature_request.md",
+		".github/workflows/build.yml",
+		".github/workflows/release.yml",
+		"src/.internal/secrets.go",
+		"src/main.go",
 	}
-
 	for _, path := range expectedFiles {
-		fullPath := filepath.Join(tmp, path)
-		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-			t.Logf("Note: Expected file %s does not exist, but might be flattened", path)
-		} else {
-			// File exists, read its content
-			content, err := os.ReadFile(fullPath)
-			if err == nil && len(content) < 500 {
-				t.Logf("File %s exists with content: \n%s", path, string(content))
-			}
+		if _, err := os.Stat(filepath.Join(tmp, path)); err != nil {
+			t.Errorf("expected file %s: %v", path, err)
 		}
 	}
 
-	// 3) Check that the .github directory was created
-	githubDir := filepath.Join(tmp, ".github")
-	if info, err := os.Stat(githubDir); err == nil && info.IsDir() {
-		t.Logf(".github directory created successfully")
-	} else {
-		t.Logf("Note: .github directory not created as expected")
+	// 3) Nothing is flattened into the hidden directories' roots
+	for _, path := range []string{".github/build.yml", ".github/release.yml", "src/secrets.go"} {
+		if _, err := os.Stat(filepath.Join(tmp, path)); err == nil {
+			t.Errorf("%s exists; its file belongs in a subdirectory", path)
+		}
 	}
 
-	// 4) Check if src directory was created with main.go
-	srcDir := filepath.Join(tmp, "src")
-	if info, err := os.Stat(srcDir); err == nil && info.IsDir() {
-		mainGoPath := filepath.Join(srcDir, "main.go")
-		if _, err := os.Stat(mainGoPath); err == nil {
-			content, _ := os.ReadFile(mainGoPath)
-			if strings.Contains(string(content), "Main entry point") {
-				t.Logf("src/main.go exists with expected content")
-			}
-		}
+	// 4) Comments reach the generated files
+	content, err := os.ReadFile(filepath.Join(tmp, "src", "main.go"))
+	if err != nil || !strings.Contains(string(content), "Main entry point") {
+		t.Errorf("src/main.go = %q, %v; want its comment", content, err)
 	}
 }
 
@@ -719,15 +702,15 @@ crossplatform/
 	findOut, err := findCmd.CombinedOutput()
 	t.Logf("Created files: \n%s", findOut)
 
-	// 2) Check for some key files in the flattened structure
+	// 2) Check for some key files in the nested structure
 	expectedFiles := []string{
-		"cmd/main.go",         // Flattened structure
-		"cmd/main_windows.go", // Flattened structure
-		"cmd/main_linux.go",   // Flattened structure
-		"cmd/main_darwin.go",  // Flattened structure
+		"cmd/app/main.go",
+		"cmd/app/main_windows.go",
+		"cmd/app/main_linux.go",
+		"cmd/app/main_darwin.go",
 		"scripts/build.sh",
 		"scripts/build.bat",
-		"internal/platform.go", // Flattened structure
+		"internal/platform/platform.go",
 		"README.md",
 	}
 
@@ -735,7 +718,7 @@ crossplatform/
 	for _, path := range expectedFiles {
 		fullPath := filepath.Join(tmp, path)
 		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-			t.Logf("Note: Expected file %s might be flattened differently", path)
+			t.Logf("Note: Expected file %s does not exist", path)
 		} else {
 			filesFound++
 			// File exists, read its content for platforms